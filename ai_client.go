@@ -1,86 +1,143 @@
-package main
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/invopop/jsonschema"
-	"github.com/joho/godotenv"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
-)
-
-var client *openai.Client
-
-// --- 初期化処理 ---
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println(".env ファイルの読み込みに失敗しましたが、環境変数を使用して続行します")
-	}
-
-	// 環境変数からAPIキーを取得
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY not set in .env")
-	}
-
-	// OpenAIクライアントの初期化
-	client = openai.NewClient(option.WithAPIKey(apiKey))
-}
-
-// SchemaGenerator は任意の構造体からJSONスキーマを生成します
-func SchemaGenerator[T any]() interface{} {
-	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
-	}
-	var v T
-	return reflector.Reflect(v)
-}
-
-// ChatCompletionHandler はJSONスキーマを使ってOpenAI APIの補完を処理します
-func ChatCompletionHandler[T any](ctx context.Context, model string, prompt string) (*T, error) {
-	// JSONスキーマ生成
-	schema := SchemaGenerator[T]()
-
-	// スキーマパラメータ設定
-	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        openai.F("response_schema"),
-		Description: openai.F("Structured response based on JSON schema"),
-		Schema:      openai.F(schema),
-		Strict:      openai.Bool(true),
-	}
-
-	// OpenAI APIを呼び出し
-	chat, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		}),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONSchemaParam{
-				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
-				JSONSchema: openai.F(schemaParam),
-			},
-		),
-		Model: openai.F(model),
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Println(prompt)
-	fmt.Println(chat.Choices[0].Message.Content)
-
-	// 応答を構造体にデコード
-	var result T
-	err = json.Unmarshal([]byte(chat.Choices[0].Message.Content), &result)
-	if err != nil {
-		return nil, err
-	}
-
-	return &result, nil
-}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/invopop/jsonschema"
+	"github.com/joho/godotenv"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+var (
+	client     *openai.Client
+	clientErr  error
+	clientOnce sync.Once
+)
+
+// getClient は OpenAI クライアントを初回の呼び出し時に初期化して返します。
+// プロンプトの確認だけなど、モデルを呼び出さないコマンドでは APIキーを必要としません。
+func getClient() (*openai.Client, error) {
+	clientOnce.Do(func() {
+		if err := godotenv.Load(); err != nil {
+			slog.Debug(".env not loaded, using environment variables", "error", err)
+		}
+
+		// 環境変数からAPIキーを取得
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			clientErr = errors.New("OPENAI_API_KEY not set in .env")
+			return
+		}
+
+		// OpenAIクライアントの初期化
+		// 再試行は modelCalls が行うので、SDK 側では再試行しない
+		client = openai.NewClient(option.WithAPIKey(apiKey), option.WithMaxRetries(0))
+	})
+	return client, clientErr
+}
+
+// SchemaGenerator は任意の構造体からJSONスキーマを生成します
+func SchemaGenerator[T any]() interface{} {
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+	}
+	var v T
+	return reflector.Reflect(v)
+}
+
+// ChatCompletionHandler はJSONスキーマを使ってOpenAI APIの補完を処理します
+func ChatCompletionHandler[T any](ctx context.Context, model string, prompt string) (*T, error) {
+	client, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// JSONスキーマ生成
+	schema := SchemaGenerator[T]()
+
+	// スキーマパラメータ設定
+	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:        openai.F("response_schema"),
+		Description: openai.F("Structured response based on JSON schema"),
+		Schema:      openai.F(schema),
+		Strict:      openai.Bool(true),
+	}
+
+	logger := scopeLogger(ctx)
+	logger.Debug("calling model", "model", model, "estimated_tokens", estimateTokens(prompt))
+	seq := runArtifacts.Next()
+	runArtifacts.Save(ctx, seq, "prompt", "md", prompt)
+
+	// OpenAI APIを呼び出し（一時的な失敗は modelCalls が再試行する）
+	var content string
+	err = modelCalls.Do(ctx, logger, func(callCtx context.Context) error {
+		start := time.Now()
+		chat, err := client.Chat.Completions.New(callCtx, openai.ChatCompletionNewParams{
+			Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+				openai.UserMessage(prompt),
+			}),
+			ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
+				openai.ResponseFormatJSONSchemaParam{
+					Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+					JSONSchema: openai.F(schemaParam),
+				},
+			),
+			Model: openai.F(model),
+		})
+		if err != nil {
+			return err
+		}
+
+		// 使用量を記録する（記録に失敗しても生成は続ける）
+		usage := Usage{
+			Time:             start,
+			Model:            defaultString(chat.Model, model),
+			PromptTokens:     chat.Usage.PromptTokens,
+			CachedTokens:     chat.Usage.PromptTokensDetails.CachedTokens,
+			CompletionTokens: chat.Usage.CompletionTokens,
+			Latency:          time.Since(start),
+		}
+		if err := runUsage.Record(ctx, usage); err != nil {
+			logger.Warn("could not record usage", "error", err)
+		}
+		logger.Info("model call finished", "model", usage.Model, "prompt_tokens", usage.PromptTokens,
+			"completion_tokens", usage.CompletionTokens, "latency", usage.Latency.Round(time.Millisecond))
+
+		if len(chat.Choices) == 0 {
+			return errEmptyResponse
+		}
+		choice := chat.Choices[0]
+		if choice.Message.Refusal != "" {
+			return &RefusalError{Refusal: choice.Message.Refusal}
+		}
+		switch choice.FinishReason {
+		case openai.ChatCompletionChoicesFinishReasonLength:
+			return errors.New("the response was truncated because it reached the token limit")
+		case openai.ChatCompletionChoicesFinishReasonContentFilter:
+			return errors.New("the response was blocked by the content filter")
+		}
+		content = choice.Message.Content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	runArtifacts.Save(ctx, seq, "response", "json", content)
+
+	// 応答を構造体にデコード
+	var result T
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode the model response: %w", err)
+	}
+
+	return &result, nil
+}
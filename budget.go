@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// defaultModel はモデルを指定しなかったときに使うモデルです。
+const defaultModel = "gpt-4.1-mini"
+
+// defaultTokenBudgets はモデル名の接頭辞ごとの、プロンプトに使ってよい既定のトークン数です。
+// コンテキストウィンドウから応答の分を差し引いた値にしています。
+var defaultTokenBudgets = map[string]int{
+	"gpt-4.1": 1000000,
+	"gpt-4o":  120000,
+	"o1":      190000,
+	"o3":      190000,
+	"o4":      190000,
+}
+
+// fallbackTokenBudget は defaultTokenBudgets にないモデルのトークン数です。
+const fallbackTokenBudget = 120000
+
+// model は使用するモデル名を返します。
+func (c *Config) model() string {
+	return defaultString(c.Model, defaultModel)
+}
+
+// tokenBudget は model のプロンプトに使ってよいトークン数を返します。
+// llm-sqlc.yml の budgets が優先され、なければ接頭辞が最も長く一致する既定値を使います。
+func (c *Config) tokenBudget(model string) int {
+	if budget, ok := c.Budgets[model]; ok {
+		return budget
+	}
+	budget, matched := fallbackTokenBudget, ""
+	for prefix, b := range defaultTokenBudgets {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			budget, matched = b, prefix
+		}
+	}
+	return budget
+}
+
+// estimateTokens は s のトークン数を概算します。
+// 英数字や記号はおよそ4文字で1トークン、日本語などの非ASCII文字は1文字で1トークンとして数えます。
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// SectionTokens はプロンプトのセクション1つ分の推定トークン数です。
+type SectionTokens struct {
+	Name   string
+	Tokens int
+}
+
+// SectionTokens は top のテンプレートが直接呼び出しているセクションを1つずつ展開し、推定トークン数を返します。
+// セクションの構成はテンプレートから読み取るので、上書きで構成を変えても対応します。
+func (p *Prompts) SectionTokens(top string, data interface{}) ([]SectionTokens, error) {
+	t := p.tmpl.Lookup(top)
+	if t == nil || t.Tree == nil {
+		return nil, fmt.Errorf("prompt template %q not defined", top)
+	}
+	clone, err := p.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	var result []SectionTokens
+	for i, node := range t.Tree.Root.Nodes {
+		tn, ok := node.(*parse.TemplateNode)
+		if !ok {
+			continue
+		}
+		section, err := clone.New(fmt.Sprintf("section.%d", i)).Parse(tn.String())
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		if err := section.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render prompt section %q: %w", tn.Name, err)
+		}
+		// 該当するものがなく空になった任意のセクション（Bulk Operations など）は報告しない
+		if strings.TrimSpace(b.String()) == "" {
+			continue
+		}
+		result = append(result, SectionTokens{Name: tn.Name, Tokens: estimateTokens(b.String())})
+	}
+	return result, nil
+}
+
+// promptTrimmer はプロンプトを予算に収めるための削減1段階分です。
+// 実行するとプロンプトのデータを書き換え、削ったものの説明を返します。
+type promptTrimmer func() []string
+
+// fitPrompt は top を data で展開した結果が budget に収まるまで trimmers を順に適用します。
+// 予算を超えていた場合は、セクションごとのトークン数と削ったものを報告します。
+// すべて適用しても収まらない場合は、警告を出したうえでそのまま返します。
+func (p *Prompts) fitPrompt(top string, logger *slog.Logger, model string, budget int, data interface{}, trimmers []promptTrimmer) (string, error) {
+	prompt, err := p.Render(top, data)
+	if err != nil {
+		return "", err
+	}
+	tokens := estimateTokens(prompt)
+	if budget <= 0 || tokens <= budget {
+		return prompt, nil
+	}
+
+	logger.Warn("prompt is over the token budget", "prompt", top, "tokens", tokens, "budget", budget, "model", model)
+	if sections, err := p.SectionTokens(top, data); err == nil {
+		for _, s := range sections {
+			logger.Info("prompt section", "section", s.Name, "tokens", s.Tokens)
+		}
+	}
+
+	for _, trim := range trimmers {
+		removed := trim()
+		if len(removed) == 0 {
+			continue
+		}
+		prompt, err = p.Render(top, data)
+		if err != nil {
+			return "", err
+		}
+		after := estimateTokens(prompt)
+		logger.Info("trimmed prompt", "prompt", top, "tokens", tokens-after, "removed", strings.Join(removed, ", "))
+		tokens = after
+		if tokens <= budget {
+			return prompt, nil
+		}
+	}
+	logger.Warn("prompt is still over the token budget after trimming, sending it anyway", "prompt", top, "tokens", tokens)
+	return prompt, nil
+}
+
+// typeDeclPattern はエンティティのコードから型名を拾います。
+var typeDeclPattern = regexp.MustCompile(`(?m)^type\s+(\w+)`)
+
+// entityTypeNames はエンティティのコードで定義されている型名を返します。
+func entityTypeNames(entities []PromptEntity) []string {
+	var names []string
+	for _, e := range entities {
+		for _, m := range typeDeclPattern.FindAllStringSubmatch(e.Code, -1) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// trimEntities は referenced（インターフェースのソースなど）から参照されているエンティティと、
+// それらのエンティティから参照されているエンティティだけを残します。
+func trimEntities(entities []PromptEntity, referenced string) (kept []PromptEntity, removed []string) {
+	patterns := make([]*regexp.Regexp, len(entities))
+	for i, e := range entities {
+		var names []string
+		for _, m := range typeDeclPattern.FindAllStringSubmatch(e.Code, -1) {
+			names = append(names, regexp.QuoteMeta(m[1]))
+		}
+		if len(names) > 0 {
+			patterns[i] = regexp.MustCompile(`\b(?:` + strings.Join(names, "|") + `)\b`)
+		}
+	}
+
+	used := make([]bool, len(entities))
+	queue := []string{referenced}
+	for len(queue) > 0 {
+		text := queue[0]
+		queue = queue[1:]
+		for i, e := range entities {
+			if !used[i] && patterns[i] != nil && patterns[i].MatchString(text) {
+				used[i] = true
+				queue = append(queue, e.Code)
+			}
+		}
+	}
+	for i, e := range entities {
+		if used[i] {
+			kept = append(kept, e)
+		} else {
+			removed = append(removed, "entity "+e.Path)
+		}
+	}
+	return kept, removed
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenBudget(t *testing.T) {
+	cfg := &Config{Budgets: map[string]int{"gpt-4.1-mini": 5000}}
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"gpt-4.1-mini", 5000},
+		{"gpt-4.1", 1000000},
+		{"gpt-4o-mini", 120000},
+		{"o3-mini", 190000},
+		{"unknown-model", fallbackTokenBudget},
+	}
+	for _, tt := range tests {
+		if got := cfg.tokenBudget(tt.model); got != tt.want {
+			t.Errorf("tokenBudget(%s) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+	if got := (&Config{}).model(); got != defaultModel {
+		t.Errorf("model() = %s, want %s", got, defaultModel)
+	}
+}
+
+func TestTrimEntities(t *testing.T) {
+	entities := []PromptEntity{
+		{Path: "pkg/domain/entity/user.go", Code: "type UserID int64\n\ntype User struct {\n\tID UserID\n\tProfile *Profile\n}"},
+		{Path: "pkg/domain/entity/profile.go", Code: "type Profile struct {\n\tBio string\n}"},
+		{Path: "pkg/domain/entity/invoice.go", Code: "type Invoice struct {\n\tTotal int\n}"},
+	}
+	kept, removed := trimEntities(entities, "FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)")
+	var keptPaths []string
+	for _, e := range kept {
+		keptPaths = append(keptPaths, e.Path)
+	}
+	// Profile は User から参照されているので残る
+	if got := strings.Join(keptPaths, ","); got != "pkg/domain/entity/user.go,pkg/domain/entity/profile.go" {
+		t.Errorf("kept = %s", got)
+	}
+	if got := strings.Join(removed, ","); got != "entity pkg/domain/entity/invoice.go" {
+		t.Errorf("removed = %s", got)
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		"pkg/domain/entity/invoice.go":    "package entity\n\ntype Invoice struct {\n\tTotal int\n}\n\nfunc NewInvoice(total int) *Invoice {\n\treturn &Invoice{Total: total}\n}\n",
+		"pkg/infra/sql/schema/schema.sql": "CREATE TABLE users (id BIGINT PRIMARY KEY, name TEXT NOT NULL);\n\nCREATE TABLE invoices (id BIGINT PRIMARY KEY, total INT NOT NULL);\n",
+		configPath:                        "budgets:\n  gpt-4.1-mini: 10\n",
+	})
+
+	prompt, err := DumpPrompt("sql", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(sql) error: %v", err)
+	}
+	if strings.Contains(prompt, "invoice") {
+		t.Errorf("expected the unrelated entity and table to be trimmed:\n%s", prompt)
+	}
+	for _, want := range []string{"CREATE TABLE users", "## pkg/domain/entity/user.go"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected SQL prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+
+	g, err := newSQLGenerator("pkg/infra/user.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := g.prompts.SectionTokens("sql", &SQLPromptData{Interface: g.ifaceSrc, Method: "FindByID", Schema: g.schema.String(), Entities: g.entities})
+	if err != nil {
+		t.Fatalf("SectionTokens error: %v", err)
+	}
+	var names []string
+	for _, s := range sections {
+		if s.Tokens <= 0 {
+			t.Errorf("section %s has no tokens", s.Name)
+		}
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "sql.instruction,sql.function,sql.notes,sql.sqlc,sql.schema,entities,sql.output" {
+		t.Errorf("sections = %s", got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// bulkParam は、書き込みメソッドが複数の要素をまとめて受け取るスライスの引数を返します（例: users []*entity.User）。
+// 読み取りメソッドや、スライスの引数がないメソッドでは nil を返します。
+func bulkParam(method InterfaceMethod) *MethodParam {
+	if !isWriteMethod(nil, method.Name) {
+		return nil
+	}
+	for i, p := range method.Params {
+		if strings.HasPrefix(p.Type, "[]") && p.Type != "[]byte" {
+			return &method.Params[i]
+		}
+	}
+	return nil
+}
+
+// supportsPgxBatch は sqlc が :copyfrom と :batchexec などを生成できるドライバーかを返します（pgx のみ）。
+func supportsPgxBatch(pkg *DBPackage) bool {
+	return pkg != nil && pkg.Engine == "postgresql" && (pkg.Driver == pgxV5Driver || pkg.Driver == pgxV4Driver)
+}
+
+// BulkSQLGuidance は、スライスを受け取る書き込みメソッドの SQL を、要素ごとのクエリではなく
+// まとめて実行するクエリとして書かせるための説明です。対象でなければ空文字を返します。
+func BulkSQLGuidance(method InterfaceMethod, pkg *DBPackage) string {
+	param := bulkParam(method)
+	if param == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s receives many items at once (%s %s). Never write a query that handles a single item and is called once per item.\n",
+		method.Name, param.Name, param.Type))
+	switch {
+	case supportsPgxBatch(pkg):
+		b.WriteString(fmt.Sprintf(`The Go code uses the %s driver, so sqlc supports the following annotations:
+- :copyfrom for plain inserts. It uses the COPY protocol, so the query must be a single INSERT INTO table (columns) VALUES ($1, $2, ...) without RETURNING or ON CONFLICT.
+- :batchexec, :batchmany or :batchone to send one statement per item in a single round trip. Use them for upserts, updates, or when RETURNING is needed.
+- Array parameters with unnest for a single set-based statement:
+
+-- name: UpsertAuthors :exec
+INSERT INTO authors (id, name)
+SELECT * FROM unnest(@ids::bigint[], @names::text[])
+ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name;
+
+-- name: CreateAuthors :copyfrom
+INSERT INTO authors (name, bio) VALUES ($1, $2);
+
+-- name: UpdateAuthorNames :batchexec
+UPDATE authors SET name = @name WHERE id = @id;
+`, pkg.Driver.Name))
+	case pkg != nil && pkg.Engine == "mysql":
+		b.WriteString(`sqlc supports :copyfrom for MySQL (LOAD DATA). Use it for plain inserts, and sqlc.slice for updates and deletes by many keys:
+
+-- name: CreateAuthors :copyfrom
+INSERT INTO authors (name, bio) VALUES (?, ?);
+
+-- name: DeleteAuthors :exec
+DELETE FROM authors WHERE id IN (sqlc.slice(ids));
+`)
+	case pkg != nil && pkg.Engine == "sqlite":
+		b.WriteString(`sqlc supports neither :copyfrom, :batchexec nor array parameters for SQLite. Use sqlc.slice for updates and deletes by many keys:
+
+-- name: DeleteAuthors :exec
+DELETE FROM authors WHERE id IN (sqlc.slice(ids));
+
+-- name: UpdateAuthorsStatus :exec
+UPDATE authors SET status = @status WHERE id IN (sqlc.slice(ids));
+
+The only exception is a plain insert, which sqlc cannot expand from a slice on SQLite. Write a single-row INSERT; the program runs it for every item inside one transaction.
+`)
+	case pkg != nil && pkg.Engine == "postgresql":
+		b.WriteString(`sqlc does not support :copyfrom and :batchexec with this driver. Write a single set-based statement with array parameters instead:
+
+-- name: CreateAuthors :exec
+INSERT INTO authors (name, bio)
+SELECT * FROM unnest(@names::text[], @bios::text[]);
+
+-- name: DeleteAuthors :exec
+DELETE FROM authors WHERE id = ANY(@ids::bigint[]);
+`)
+	default:
+		b.WriteString(`Write a single set-based statement that handles all items (for example, a WHERE clause that matches a list of keys), using only syntax that the database engine and sqlc support.
+`)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// loopedInserts は、SQLite でスライスを受け取る書き込みメソッドの INSERT クエリの名前を返します。
+// sqlc は SQLite ではスライスから複数行の INSERT を作れないので、これらは要素ごとにループで呼び出し、1つのトランザクションにまとめます。
+func loopedInserts(method InterfaceMethod, m *MethodManifest, pkg *DBPackage) []string {
+	if pkg == nil || pkg.Engine != "sqlite" || m == nil || bulkParam(method) == nil {
+		return nil
+	}
+	var names []string
+	for _, q := range m.Queries {
+		if q.Verb == "INSERT" {
+			names = append(names, q.Name)
+		}
+	}
+	return names
+}
+
+// BulkProgramGuidance は、メソッドのクエリに :copyfrom や :batchexec などが含まれるとき、その呼び出し方を説明します。
+// スライスを受け取る書き込みメソッドでなく、まとめて実行するクエリもなければ空文字を返します。
+func BulkProgramGuidance(method InterfaceMethod, m *MethodManifest, pkg *DBPackage) string {
+	var lines []string
+	if m != nil {
+		for _, q := range m.Queries {
+			switch q.Kind {
+			case ":copyfrom":
+				lines = append(lines, fmt.Sprintf("- query.%[1]s(ctx, []%[1]sParams{...}) copies all rows at once and returns the number of rows (int64). Build the slice from all items first and call it once.", q.Name))
+			case ":batchexec":
+				lines = append(lines, fmt.Sprintf("- query.%[1]s(ctx, []%[1]sParams{...}) returns *%[1]sBatchResults. Call results.Exec(func(i int, err error) { ... }) to collect the error of each item, and always call results.Close().", q.Name))
+			case ":batchmany":
+				lines = append(lines, fmt.Sprintf("- query.%[1]s(ctx, []%[1]sParams{...}) returns *%[1]sBatchResults. Call results.Query(func(i int, rows []T, err error) { ... }) to read the rows of each item, and always call results.Close().", q.Name))
+			case ":batchone":
+				lines = append(lines, fmt.Sprintf("- query.%[1]s(ctx, []%[1]sParams{...}) returns *%[1]sBatchResults. Call results.QueryRow(func(i int, row T, err error) { ... }) to read the row of each item, and always call results.Close().", q.Name))
+			}
+		}
+	}
+	if bulkParam(method) != nil {
+		lines = append(lines, "- If a query takes array parameters, build one slice per column from all items and call it once.")
+	}
+	if len(lines) == 0 && bulkParam(method) == nil {
+		return ""
+	}
+	var b strings.Builder
+	if looped := loopedInserts(method, m, pkg); len(looped) > 0 {
+		b.WriteString(fmt.Sprintf("This method writes many items at once. SQLite cannot insert a slice with one query, so call %s once per item in a loop, inside the transaction described in the Transactions section. Call every other query once for all items.\n",
+			strings.Join(looped, ", ")))
+	} else {
+		b.WriteString("This method writes many items at once. Do not call a query once per item in a loop.\n")
+	}
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// batchKinds は結果を Close しなければならないクエリの種類です。
+var batchKinds = []string{":batchexec", ":batchmany", ":batchone"}
+
+// checkBulk は、:batchexec などの結果を Close していないメソッドと、結果を読み取らずに捨てているメソッドを報告します。
+func checkBulk(c *CheckContext) []Finding {
+	if c.QueryKinds == nil {
+		return nil
+	}
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		fn := c.Methods[method]
+		for _, call := range queryCalls(c, fn) {
+			name := calledName(call)
+			if !containsString(batchKinds, c.QueryKinds(name)) {
+				continue
+			}
+			calls := make(map[string]bool)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if inner, ok := n.(*ast.CallExpr); ok {
+					calls[calledName(inner)] = true
+				}
+				return true
+			})
+			if !calls["Close"] {
+				findings = append(findings, c.newFinding("bulk", method, call,
+					"the results of the batch query %s must be closed with Close()", name))
+			}
+			if !calls["Exec"] && !calls["Query"] && !calls["QueryRow"] {
+				findings = append(findings, c.newFinding("bulk", method, call,
+					"the results of the batch query %s are never read; errors of individual items are lost", name))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBulkGuidance(t *testing.T) {
+	create := InterfaceMethod{Name: "CreateAll", Params: []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "users", Type: "[]*entity.User"}}}
+	if bulkParam(InterfaceMethod{Name: "FindByIDs", Params: []MethodParam{{Name: "ids", Type: "[]int64"}}}) != nil {
+		t.Errorf("read methods must not be treated as bulk writes")
+	}
+	if bulkParam(InterfaceMethod{Name: "SaveAvatar", Params: []MethodParam{{Name: "data", Type: "[]byte"}}}) != nil {
+		t.Errorf("[]byte must not be treated as a bulk parameter")
+	}
+	if p := bulkParam(create); p == nil || p.Name != "users" {
+		t.Fatalf("bulkParam = %v, want users", p)
+	}
+
+	pgx := BulkSQLGuidance(create, &DBPackage{Engine: "postgresql", Driver: pgxV5Driver})
+	for _, want := range []string{"CreateAll receives many items at once (users []*entity.User)", ":copyfrom", ":batchexec", "unnest("} {
+		if !strings.Contains(pgx, want) {
+			t.Errorf("expected pgx guidance to contain %q:\n%s", want, pgx)
+		}
+	}
+	stdlib := BulkSQLGuidance(create, &DBPackage{Engine: "postgresql", Driver: databaseSQLDriver})
+	if strings.Contains(stdlib, "-- name: CreateAuthors :copyfrom") || !strings.Contains(stdlib, "ANY(@ids::bigint[])") {
+		t.Errorf("expected database/sql guidance to use array parameters only:\n%s", stdlib)
+	}
+	sqlite := BulkSQLGuidance(create, &DBPackage{Engine: "sqlite", Driver: databaseSQLDriver})
+	if !strings.Contains(sqlite, "IN (sqlc.slice(ids))") || strings.Contains(sqlite, "unnest(") || strings.Contains(sqlite, "ANY(") {
+		t.Errorf("expected SQLite guidance to use sqlc.slice instead of PostgreSQL arrays:\n%s", sqlite)
+	}
+	if unknown := BulkSQLGuidance(create, nil); strings.Contains(unknown, "unnest(") || strings.Contains(unknown, "ANY(") {
+		t.Errorf("expected no PostgreSQL-only SQL for an unknown engine:\n%s", unknown)
+	}
+	if got := BulkSQLGuidance(InterfaceMethod{Name: "Create"}, nil); got != "" {
+		t.Errorf("expected no guidance for a single item, got %q", got)
+	}
+
+	program := BulkProgramGuidance(create, &MethodManifest{Method: "CreateAll", Queries: []QueryManifestEntry{
+		{Name: "CreateUsers", Kind: ":copyfrom"},
+		{Name: "UpsertProfiles", Kind: ":batchexec"},
+	}}, &DBPackage{Engine: "postgresql", Driver: pgxV5Driver})
+	for _, want := range []string{
+		"query.CreateUsers(ctx, []CreateUsersParams{...}) copies all rows at once",
+		"returns *UpsertProfilesBatchResults",
+		"always call results.Close()",
+	} {
+		if !strings.Contains(program, want) {
+			t.Errorf("expected program guidance to contain %q:\n%s", want, program)
+		}
+	}
+}
+
+func TestBulkGuidanceSQLiteInsert(t *testing.T) {
+	create := InterfaceMethod{Name: "CreateAll", Params: []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "users", Type: "[]*entity.User"}}}
+	m := &MethodManifest{Method: "CreateAll", Queries: []QueryManifestEntry{
+		{Name: "CreateUser", Kind: ":exec", Verb: "INSERT"},
+		{Name: "DeleteUsers", Kind: ":exec", Verb: "DELETE"},
+	}}
+	sqlite := &DBPackage{Engine: "sqlite", Driver: databaseSQLDriver}
+	if got := loopedInserts(create, m, sqlite); strings.Join(got, ",") != "CreateUser" {
+		t.Errorf("loopedInserts = %v, want CreateUser", got)
+	}
+	if got := loopedInserts(create, m, &DBPackage{Engine: "postgresql", Driver: databaseSQLDriver}); got != nil {
+		t.Errorf("expected no looped inserts on PostgreSQL, got %v", got)
+	}
+	if got := loopedInserts(InterfaceMethod{Name: "Create"}, m, sqlite); got != nil {
+		t.Errorf("expected no looped inserts for a single item, got %v", got)
+	}
+
+	program := BulkProgramGuidance(create, m, sqlite)
+	if !strings.Contains(program, "call CreateUser once per item in a loop, inside the transaction") || strings.Contains(program, "Do not call a query once per item") {
+		t.Errorf("expected SQLite program guidance to loop over the insert:\n%s", program)
+	}
+}
+
+func TestCheckBulk(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/infra/db"
+)
+
+type UserRepositoryImpl struct {
+	DB db.DBTX
+}
+
+func (repo *UserRepositoryImpl) UpdateAll(ctx context.Context) error {
+	query := db.New(repo.DB)
+	results := query.UpdateUsers(ctx, nil)
+	defer results.Close()
+	var err error
+	results.Exec(func(i int, e error) {
+		if e != nil {
+			err = e
+		}
+	})
+	return err
+}
+
+func (repo *UserRepositoryImpl) DeleteAll(ctx context.Context) error {
+	query := db.New(repo.DB)
+	query.DeleteUsers(ctx, nil)
+	return nil
+}
+`
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, []string{"UpdateAll", "DeleteAll"}, &DBPackage{ImportPath: "example.com/app/pkg/infra/db"})
+	c.QueryKinds = func(name string) string { return ":batchexec" }
+
+	var messages []string
+	for _, f := range checkBulk(c) {
+		messages = append(messages, f.Method+": "+f.Message)
+	}
+	want := []string{
+		"DeleteAll: the results of the batch query DeleteUsers must be closed with Close()",
+		"DeleteAll: the results of the batch query DeleteUsers are never read; errors of individual items are lost",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSQLCCodeSliceBatch(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"db.go": `package db
+
+type DBTX interface{}
+
+type Queries struct {
+	db DBTX
+}
+
+func (q *Queries) unused() {}
+`,
+		"batch.go": `package db
+
+import "context"
+
+const updateUsers = ` + "`" + `-- name: UpdateUsers :batchexec
+UPDATE users SET name = $2 WHERE id = $1
+` + "`" + `
+
+type UpdateUsersBatchResults struct {
+	closed bool
+}
+
+type UpdateUsersParams struct {
+	ID   int64
+	Name string
+}
+
+func (q *Queries) UpdateUsers(ctx context.Context, arg []UpdateUsersParams) *UpdateUsersBatchResults {
+	_ = updateUsers
+	return &UpdateUsersBatchResults{}
+}
+
+func (b *UpdateUsersBatchResults) Exec(f func(int, error)) {}
+
+func (b *UpdateUsersBatchResults) Close() error {
+	b.closed = true
+	return nil
+}
+`,
+	})
+
+	pkg := &DBPackage{Dir: dir, Name: "db", DBFile: filepath.Join(dir, "db.go")}
+	code, err := LoadSQLCCode(pkg)
+	if err != nil {
+		t.Fatalf("LoadSQLCCode() error: %v", err)
+	}
+	if kind := code.QueryKind("UpdateUsers"); kind != ":batchexec" {
+		t.Errorf("QueryKind = %q, want :batchexec", kind)
+	}
+	files, err := code.Slice([]string{"UpdateUsers"})
+	if err != nil {
+		t.Fatalf("Slice() error: %v", err)
+	}
+	var all strings.Builder
+	for _, f := range files {
+		all.WriteString(f.Content)
+	}
+	for _, want := range []string{"type UpdateUsersParams struct", "func (b *UpdateUsersBatchResults) Exec(", "func (b *UpdateUsersBatchResults) Close()"} {
+		if !strings.Contains(all.String(), want) {
+			t.Errorf("expected slice to contain %q:\n%s", want, all.String())
+		}
+	}
+}
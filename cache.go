@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// キャッシュ戦略
+const (
+	CacheNone              = "none"                // キャッシュを使わない
+	CacheReadThrough       = "read-through"        // 読み取り時にキャッシュを確認し、なければ DB から読んで格納する
+	CacheWriteThrough      = "write-through"       // read-through に加え、書き込み時に新しい値をキャッシュに格納する
+	CacheInvalidateOnWrite = "invalidate-on-write" // read-through に加え、書き込み時に該当するキーを削除する
+)
+
+// CacheConfig は llm-sqlc.yml の cache セクションです。
+type CacheConfig struct {
+	File      string `yaml:"file"`      // キャッシュのインターフェースを定義したファイル
+	Interface string `yaml:"interface"` // インターフェース名（省略時はファイル内の最初のインターフェース）
+	Field     string `yaml:"field"`     // 実装 struct 上のフィールド名（repo.Cache の Cache）
+	Get       string `yaml:"get"`       // 取得メソッド名
+	Set       string `yaml:"set"`       // 格納メソッド名
+	Delete    string `yaml:"delete"`    // 削除メソッド名
+
+	Default    CachePolicy                     `yaml:"default"`
+	Interfaces map[string]InterfaceCachePolicy `yaml:"interfaces"`
+}
+
+// CachePolicy はキャッシュの使い方です。空の項目は上位（既定値 → インターフェース → メソッド）の設定を引き継ぎます。
+type CachePolicy struct {
+	Strategy string `yaml:"strategy"`
+	TTL      string `yaml:"ttl"` // time.ParseDuration の形式（例: 10m）
+	Key      string `yaml:"key"` // キーの書式の説明（例: User:{ID}）
+}
+
+// InterfaceCachePolicy はインターフェース単位のポリシーと、メソッド単位の上書きです。
+type InterfaceCachePolicy struct {
+	CachePolicy `yaml:",inline"`
+	Methods     map[string]CachePolicy `yaml:"methods"`
+}
+
+// defaultCachePolicy は設定がない場合のポリシーです。
+var defaultCachePolicy = CachePolicy{
+	Strategy: CacheInvalidateOnWrite,
+	TTL:      "10m",
+	Key:      "EntityType:{ID}", // EntityType はエンティティの型名に置き換えさせる
+}
+
+func (c *CacheConfig) file() string {
+	return defaultString(c.File, "pkg/infra/cache.go")
+}
+
+func (c *CacheConfig) field() string      { return defaultString(c.Field, "Cache") }
+func (c *CacheConfig) getName() string    { return defaultString(c.Get, "Get") }
+func (c *CacheConfig) setName() string    { return defaultString(c.Set, "Set") }
+func (c *CacheConfig) deleteName() string { return defaultString(c.Delete, "Delete") }
+
+// merge は override の空でない項目で p を上書きしたものを返します。
+func (p CachePolicy) merge(override CachePolicy) CachePolicy {
+	if override.Strategy != "" {
+		p.Strategy = override.Strategy
+	}
+	if override.TTL != "" {
+		p.TTL = override.TTL
+	}
+	if override.Key != "" {
+		p.Key = override.Key
+	}
+	return p
+}
+
+// PolicyFor は iface の method に適用するポリシーを返します。
+func (c *CacheConfig) PolicyFor(iface, method string) CachePolicy {
+	policy := defaultCachePolicy.merge(c.Default)
+	if ip, ok := c.Interfaces[iface]; ok {
+		policy = policy.merge(ip.CachePolicy)
+		if mp, ok := ip.Methods[method]; ok {
+			policy = policy.merge(mp)
+		}
+	}
+	return policy
+}
+
+// validate は設定されたポリシーがすべて解釈できるかを確認します。
+func (c *CacheConfig) validate() error {
+	check := func(where string, p CachePolicy) error {
+		switch p.Strategy {
+		case "", CacheNone, CacheReadThrough, CacheWriteThrough, CacheInvalidateOnWrite:
+		default:
+			return fmt.Errorf("cache %s: unknown strategy %q", where, p.Strategy)
+		}
+		if p.TTL != "" {
+			if _, err := time.ParseDuration(p.TTL); err != nil {
+				return fmt.Errorf("cache %s: invalid ttl %q: %w", where, p.TTL, err)
+			}
+		}
+		return nil
+	}
+	if err := check("default", c.Default); err != nil {
+		return err
+	}
+	for iface, ip := range c.Interfaces {
+		if err := check(iface, ip.CachePolicy); err != nil {
+			return err
+		}
+		for method, mp := range ip.Methods {
+			if err := check(iface+"."+method, mp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadCacheInterface はキャッシュのインターフェース定義をファイルから読み取ります。
+// ファイルがなければ fs.ErrNotExist を返し、その場合はキャッシュを使わないものとして扱います。
+func LoadCacheInterface(c *CacheConfig) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, c.file(), nil, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if _, ok := ts.Type.(*ast.InterfaceType); !ok {
+				continue
+			}
+			if c.Interface != "" && ts.Name.Name != c.Interface {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, genDecl); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		}
+	}
+	if c.Interface != "" {
+		return "", fmt.Errorf("cache interface %s not found in %s", c.Interface, c.file())
+	}
+	return "", fmt.Errorf("no interface found in %s", c.file())
+}
+
+// durationExpr は d を Go のコード上の式（例: 10*time.Minute）で表します。
+func durationExpr(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d*time.Hour", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d*time.Minute", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%d*time.Second", d/time.Second)
+	}
+	return fmt.Sprintf("time.Duration(%d)", d)
+}
+
+// CacheGuidance はメソッド1つ分のキャッシュの説明と実装パターンをプロンプト向けに組み立てます。
+// cacheSrc が空の場合（キャッシュが存在しない場合）はキャッシュを使わない実装パターンを返します。
+func CacheGuidance(c *CacheConfig, policy CachePolicy, write bool, cacheSrc string, dbPkgName string) string {
+	field := "repo." + c.field()
+	var b strings.Builder
+
+	if cacheSrc == "" || policy.Strategy == CacheNone {
+		b.WriteString("## Cache\nDo not use any cache in this method.\n\n")
+		b.WriteString("## Implementation Pattern\n")
+		b.WriteString(fmt.Sprintf("query := %s.New(tx)\n", dbPkgName))
+		b.WriteString("// Call the DB query via its function\n// For example: query.GetSomething(ctx)\n\n")
+		b.WriteString("// Convert the retrieved data to an Entity using the New function.")
+		return b.String()
+	}
+
+	ttl, _ := time.ParseDuration(policy.TTL)
+	b.WriteString("## Cache\n")
+	b.WriteString("The infrastructure implementation uses a cache to speed up access by avoiding direct DB queries.\n")
+	b.WriteString(fmt.Sprintf("The cache is defined in %s as follows:\n\n", c.file()))
+	b.WriteString(cacheSrc)
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("- The cache key should be in the format %q. Use exactly the same key format in every method of this repository.\n", policy.Key))
+	if !write {
+		b.WriteString("- For queries that retrieve a single record by ID, first check the cache, and if it is not found, then issue a DB query.\n")
+		b.WriteString(fmt.Sprintf("- Store the entity in the cache for %s after reading it from the DB.\n", policy.TTL))
+		b.WriteString("\n## Implementation Pattern\n")
+		b.WriteString(fmt.Sprintf("query := %s.New(tx)\n", dbPkgName))
+		b.WriteString(fmt.Sprintf("cacheKey := %s\nif cachedEntity, found := %s.%s(cacheKey); found {\n    // If the cache contains the entity, return it.\n}\n\n", cacheKeyExpr(policy.Key), field, c.getName()))
+		b.WriteString("// Call the DB query via its function\n// For example: query.GetSomething(ctx)\n\n")
+		b.WriteString("// Convert the retrieved data to an Entity using the New function.\n\n")
+		b.WriteString(fmt.Sprintf("%s.%s(cacheKey, entity, %s)", field, c.setName(), durationExpr(ttl)))
+		return b.String()
+	}
+
+	b.WriteString("\n## Implementation Pattern\n")
+	b.WriteString(fmt.Sprintf("query := %s.New(tx)\n", dbPkgName))
+	b.WriteString("// Call the DB query via its function\n// For example: query.UpdateSomething(ctx, params)\n\n")
+	switch policy.Strategy {
+	case CacheInvalidateOnWrite:
+		b.WriteString("// After the write succeeds, delete every cache entry that the read methods of this repository may have stored for the changed entities.\n")
+		b.WriteString(fmt.Sprintf("cacheKey := %s\n%s.%s(cacheKey)", cacheKeyExpr(policy.Key), field, c.deleteName()))
+	case CacheWriteThrough:
+		b.WriteString("// After the write succeeds, store the new state of the entity under every key that the read methods of this repository use.\n")
+		b.WriteString(fmt.Sprintf("cacheKey := %s\n%s.%s(cacheKey, entity, %s)", cacheKeyExpr(policy.Key), field, c.setName(), durationExpr(ttl)))
+	default:
+		b.WriteString("// This method does not touch the cache.")
+	}
+	return b.String()
+}
+
+// cacheKeyPlaceholderPattern はキーの書式の中の {ID} のような埋め込み箇所です。
+var cacheKeyPlaceholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// cacheKeyExpr は、実装パターンに載せるキーの式を key の書式から組み立てます。
+// "User:{ID}" なら fmt.Sprintf("User:%v", id) になります。埋め込み箇所がなければ式を決められないので、書式を示すコメントにします。
+func cacheKeyExpr(key string) string {
+	matches := cacheKeyPlaceholderPattern.FindAllStringSubmatch(key, -1)
+	if len(matches) == 0 {
+		return fmt.Sprintf("/* build the key in the format %q */", key)
+	}
+	var args []string
+	for _, m := range matches {
+		args = append(args, lowerCamel(m[1]))
+	}
+	format := cacheKeyPlaceholderPattern.ReplaceAllString(strings.ReplaceAll(key, "%", "%%"), "%v")
+	return fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(args, ", "))
+}
+
+// lowerCamel は ID や UserID のような名前を、変数名らしい id や userID にします。
+func lowerCamel(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	upper := 0
+	for upper < len(name) && name[upper] >= 'A' && name[upper] <= 'Z' {
+		upper++
+	}
+	if upper > 1 && upper < len(name) {
+		// URLPath のような先頭の略語は、次の単語の頭文字を残す
+		upper--
+	}
+	return strings.ToLower(name[:upper]) + name[upper:]
+}
+
+// cacheKeyPattern は、キャッシュのキーとして渡された式から比較用のパターンを求めます。
+// fmt.Sprintf の書式や文字列リテラルであれば最初の % までの定数部分、
+// キーを作る関数の呼び出しであれば "func:関数名" を返します。判別できなければ空文字を返します。
+func cacheKeyPattern(expr ast.Expr, body *ast.BlockStmt) string {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if s, err := strconv.Unquote(e.Value); err == nil {
+			return s
+		}
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			return cacheKeyPattern(e.X, body)
+		}
+	case *ast.CallExpr:
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "fmt" && sel.Sel.Name == "Sprintf" && len(e.Args) > 0 {
+				if format := cacheKeyPattern(e.Args[0], body); format != "" {
+					if i := strings.Index(format, "%"); i >= 0 {
+						return format[:i]
+					}
+					return format
+				}
+				return ""
+			}
+			return "func:" + sel.Sel.Name
+		}
+		if ident, ok := e.Fun.(*ast.Ident); ok {
+			return "func:" + ident.Name
+		}
+	case *ast.Ident:
+		// 同じ関数内で代入された値を辿る
+		var found ast.Expr
+		ast.Inspect(body, func(n ast.Node) bool {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || found != nil {
+				return found == nil
+			}
+			for i, lhs := range assign.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == e.Name && i < len(assign.Rhs) {
+					found = assign.Rhs[i]
+				}
+			}
+			return true
+		})
+		if found != nil {
+			return cacheKeyPattern(found, body)
+		}
+	}
+	return ""
+}
+
+// cacheCalls は fn の中で呼ばれているキャッシュのメソッド（Get, Set, Delete など）ごとに、キーのパターンを集めます。
+func cacheCalls(c *CacheConfig, fn *ast.FuncDecl) map[string][]string {
+	calls := make(map[string][]string)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		inner, ok := sel.X.(*ast.SelectorExpr)
+		if !ok || inner.Sel.Name != c.field() {
+			return true
+		}
+		calls[sel.Sel.Name] = append(calls[sel.Sel.Name], cacheKeyPattern(call.Args[0], fn.Body))
+		return true
+	})
+	return calls
+}
+
+// checkCachePolicy は、キャッシュを使わないメソッドがキャッシュに触れていないこと、
+// および書き込みメソッドが、読み取りメソッドが格納するキーを削除（write-through の場合は更新）していることを確認します。
+func checkCachePolicy(c *CheckContext) []Finding {
+	if c.Cache == nil {
+		return nil
+	}
+	cfg := c.Cache.Config
+
+	// 読み取りメソッドが格納するキー
+	populated := make(map[string]string) // パターン → 格納しているメソッド
+	for _, method := range c.sortedMethods() {
+		if c.Cache.Write[method] {
+			continue
+		}
+		for _, key := range cacheCalls(cfg, c.Methods[method])[cfg.setName()] {
+			if key != "" {
+				if _, ok := populated[key]; !ok {
+					populated[key] = method
+				}
+			}
+		}
+	}
+
+	var keys []string
+	for key := range populated {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		fn := c.Methods[method]
+		policy := c.Cache.Policies[method]
+		calls := cacheCalls(cfg, fn)
+		if policy.Strategy == CacheNone || !c.Cache.Available {
+			if len(calls) > 0 {
+				findings = append(findings, c.newFinding("cache-policy", method, fn.Name,
+					"the cache must not be used in this method (strategy %s)", CacheNone))
+			}
+			continue
+		}
+		if !c.Cache.Write[method] {
+			continue
+		}
+
+		var handled []string
+		switch policy.Strategy {
+		case CacheInvalidateOnWrite:
+			handled = calls[cfg.deleteName()]
+		case CacheWriteThrough:
+			handled = append(append(handled, calls[cfg.deleteName()]...), calls[cfg.setName()]...)
+		default:
+			continue
+		}
+		for _, key := range keys {
+			if containsString(handled, key) {
+				continue
+			}
+			reader := populated[key]
+			findings = append(findings, c.newFinding("cache-policy", method, fn.Name,
+				"%s stores cache keys starting with %q, but this write method does not invalidate them (strategy %s)",
+				reader, key, policy.Strategy))
+		}
+	}
+	return findings
+}
+
+// CacheCheckInfo は checkCachePolicy に渡すメソッドごとのポリシーです。
+type CacheCheckInfo struct {
+	Config    *CacheConfig
+	Available bool                   // キャッシュのインターフェースが存在するか
+	Policies  map[string]CachePolicy // メソッド名 → ポリシー
+	Write     map[string]bool        // メソッド名 → 書き込みを行うか
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCachePolicyFor(t *testing.T) {
+	cfg := &CacheConfig{
+		Default: CachePolicy{TTL: "5m"},
+		Interfaces: map[string]InterfaceCachePolicy{
+			"UserRepository": {
+				CachePolicy: CachePolicy{Strategy: CacheWriteThrough},
+				Methods: map[string]CachePolicy{
+					"Search": {Strategy: CacheNone},
+				},
+			},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error: %v", err)
+	}
+
+	got := cfg.PolicyFor("UserRepository", "FindByID")
+	want := CachePolicy{Strategy: CacheWriteThrough, TTL: "5m", Key: defaultCachePolicy.Key}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := cfg.PolicyFor("UserRepository", "Search"); got.Strategy != CacheNone {
+		t.Errorf("expected method override to disable cache, got %+v", got)
+	}
+	if got := cfg.PolicyFor("PostRepository", "Find"); got.Strategy != CacheInvalidateOnWrite {
+		t.Errorf("expected the default strategy for other interfaces, got %+v", got)
+	}
+
+	invalid := &CacheConfig{Default: CachePolicy{Strategy: "sometimes"}}
+	if err := invalid.validate(); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
+
+func TestCheckCachePolicy(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type UserRepositoryImpl struct{}
+
+func (repo *UserRepositoryImpl) FindByID(ctx context.Context, id int64) error {
+	cacheKey := fmt.Sprintf("User:%d", id)
+	repo.Cache.Set(cacheKey, nil, 10*time.Minute)
+	return nil
+}
+
+func (repo *UserRepositoryImpl) FindByEmail(ctx context.Context, email string) error {
+	repo.Cache.Set("UserEmail:"+email, nil, 10*time.Minute)
+	return nil
+}
+
+func (repo *UserRepositoryImpl) Save(ctx context.Context, id int64) error {
+	repo.Cache.Delete(fmt.Sprintf("User:%d", id))
+	return nil
+}
+
+func (repo *UserRepositoryImpl) Search(ctx context.Context) error {
+	repo.Cache.Get("anything")
+	return nil
+}
+`
+	cfg := &CacheConfig{}
+	info := &CacheCheckInfo{
+		Config:    cfg,
+		Available: true,
+		Policies: map[string]CachePolicy{
+			"FindByID":    cfg.PolicyFor("UserRepository", "FindByID"),
+			"FindByEmail": cfg.PolicyFor("UserRepository", "FindByEmail"),
+			"Save":        cfg.PolicyFor("UserRepository", "Save"),
+			"Search":      {Strategy: CacheNone},
+		},
+		Write: map[string]bool{"Save": true},
+	}
+	methods := []string{"FindByID", "FindByEmail", "Save", "Search"}
+	findings := runChecksOn(t, src, methods, &DBPackage{})
+	if len(findings) != 0 {
+		t.Fatalf("expected no findings without cache info, got %v", findings)
+	}
+
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, methods, &DBPackage{})
+	c.Cache = info
+	findings = checkCachePolicy(c)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d: %v", len(findings), findings)
+	}
+	if findings[0].Method != "Save" || !strings.Contains(findings[0].Message, `"UserEmail:"`) {
+		t.Errorf("expected Save to be reported for UserEmail keys, got %v", findings[0])
+	}
+	if findings[1].Method != "Search" {
+		t.Errorf("expected Search to be reported for using the cache, got %v", findings[1])
+	}
+}
+
+func TestCacheGuidance(t *testing.T) {
+	cfg := &CacheConfig{Field: "Redis", Delete: "Del"}
+	guidance := CacheGuidance(cfg, CachePolicy{Strategy: CacheInvalidateOnWrite, TTL: "90s", Key: "User:{ID}"}, true, "type Cache interface{}", "store")
+	for _, want := range []string{"query := store.New(tx)", "repo.Redis.Del(", `"User:{ID}"`} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("expected guidance to contain %q:\n%s", want, guidance)
+		}
+	}
+	// 設定したキーの書式と食い違う例を載せない
+	for _, strategy := range []string{CacheInvalidateOnWrite, CacheWriteThrough, CacheReadThrough} {
+		guidance := CacheGuidance(cfg, CachePolicy{Strategy: strategy, TTL: "90s", Key: "User:{ID}"}, strategy != CacheReadThrough, "type Cache interface{}", "store")
+		if strings.Contains(guidance, "EntityType") || !strings.Contains(guidance, `cacheKey := fmt.Sprintf("User:%v", id)`) {
+			t.Errorf("expected the %s pattern to build the configured key:\n%s", strategy, guidance)
+		}
+	}
+	// 既定のキーの書式でも、コンパイルできる式を載せる
+	guidance = CacheGuidance(cfg, defaultCachePolicy, false, "type Cache interface{}", "store")
+	if !strings.Contains(guidance, `cacheKey := fmt.Sprintf("EntityType:%v", id)`) || strings.Contains(guidance, "/* build the key") {
+		t.Errorf("expected the default key format to build the key with fmt.Sprintf:\n%s", guidance)
+	}
+	if got := cacheKeyExpr("Post:{AuthorID}:{URLPath}"); got != `fmt.Sprintf("Post:%v:%v", authorID, urlPath)` {
+		t.Errorf("unexpected key expression: %s", got)
+	}
+	guidance = CacheGuidance(cfg, CachePolicy{Strategy: CacheReadThrough, TTL: "90s"}, false, "type Cache interface{}", "store")
+	if !strings.Contains(guidance, "repo.Redis.Set(cacheKey, entity, 90*time.Second)") {
+		t.Errorf("expected read guidance to store with the configured TTL:\n%s", guidance)
+	}
+	if guidance := CacheGuidance(cfg, CachePolicy{Strategy: CacheReadThrough}, false, "", "store"); !strings.Contains(guidance, "Do not use any cache") {
+		t.Errorf("expected no-cache guidance when the cache interface is missing:\n%s", guidance)
+	}
+}
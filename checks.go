@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// Finding は生成コードの事後チェックで見つかった問題です。
+type Finding struct {
+	Rule    string
+	Method  string
+	Pos     token.Position
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: [%s] %s: %s", f.Pos, f.Rule, f.Method, f.Message)
+}
+
+// CheckContext は事後チェックに渡す生成結果と、その周辺の情報です。
+type CheckContext struct {
+	Fset      *token.FileSet
+	File      *ast.File
+	Methods   map[string]*ast.FuncDecl // インターフェースのメソッド名 → 生成された実装
+	DBPackage *DBPackage
+	Cache     *CacheCheckInfo // キャッシュのポリシー（nil ならチェックしない）
+	Tx        *TxCheckInfo    // トランザクションが必要なメソッド（nil ならチェックしない）
+	Errors    *DomainErrors   // ドメインエラーのパッケージ（nil ならチェックしない）
+	// EntityArgs はエンティティ型の引数を取る（レコードがなければエラーを返す）メソッドです。
+	EntityArgs map[string]bool
+	// LoopedQueries はメソッド名 → ループの中で呼び出してよいクエリです（SQLite で1件ずつ実行する INSERT）。
+	LoopedQueries map[string][]string
+	// QueryKinds はクエリ名から :one, :batchexec などの種類を返します（nil ならチェックしない）。
+	QueryKinds func(name string) string
+}
+
+// CodeCheck は生成された Go コードに対するチェック1つ分です。
+type CodeCheck struct {
+	Name string
+	Run  func(c *CheckContext) []Finding
+}
+
+// codeChecks は GenerateProgram の最後に実行されるチェックの一覧です。
+var codeChecks = []CodeCheck{
+	{Name: "driver-api", Run: checkDriverAPI},
+	{Name: "cache-policy", Run: checkCachePolicy},
+	{Name: "transaction", Run: checkTransactions},
+	{Name: "domain-errors", Run: checkDomainErrors},
+	{Name: "bulk", Run: checkBulk},
+	{Name: "n-plus-one", Run: checkNPlusOne},
+	{Name: "sql-concat", Run: checkSQLConcat},
+}
+
+// チェックの重大度。error の指摘があると、生成したコードは書き込んだうえでコマンドを失敗させる
+const (
+	SeverityOff   = "off"
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// defaultSeverities は設定がないときの重大度です。ここにないチェックは warn です。
+var defaultSeverities = map[string]string{
+	"n-plus-one":             SeverityError,
+	"sql-concat":             SeverityError,
+	"sql-unbounded-write":    SeverityError,
+	"sql-select-star-join":   SeverityError,
+	"sql-arg-misuse":         SeverityError,
+	"sql-duplicate-name":     SeverityError,
+	"sql-reuse":              SeverityError,
+	"convention-soft-delete": SeverityError,
+	"convention-tenant":      SeverityError,
+	"convention-audit":       SeverityError,
+}
+
+// ChecksConfig は生成コードの事後チェックの設定です。
+type ChecksConfig struct {
+	// Severity はチェック名ごとの重大度（off, warn, error）です。
+	Severity map[string]string `yaml:"severity"`
+	// Fix を有効にすると、error の指摘があったメソッドを、指摘を添えて1度だけ生成し直します。
+	Fix bool `yaml:"fix"`
+}
+
+func (c *ChecksConfig) validate() error {
+	for name, severity := range c.Severity {
+		// sql-reuse は個々のクエリではなく、メソッドのクエリ全体に対して LintQueries が実行する
+		known := name == "sql-reuse"
+		for _, check := range codeChecks {
+			known = known || check.Name == name
+		}
+		for _, check := range sqlChecks {
+			known = known || check.Name == name
+		}
+		if !known {
+			return fmt.Errorf("checks.severity: unknown check %q", name)
+		}
+		switch severity {
+		case SeverityOff, SeverityWarn, SeverityError:
+		default:
+			return fmt.Errorf("checks.severity.%s: unknown severity %q (expected off, warn or error)", name, severity)
+		}
+	}
+	return nil
+}
+
+// severity は rule の重大度を返します。
+func (c *ChecksConfig) severity(rule string) string {
+	if s, ok := c.Severity[rule]; ok {
+		return s
+	}
+	if s, ok := defaultSeverities[rule]; ok {
+		return s
+	}
+	return SeverityWarn
+}
+
+// report は findings を重大度に応じてログに出し、error の指摘の数を返します。
+func (c *ChecksConfig) report(command string, file string, findings []Finding) int {
+	errorCount := 0
+	for _, finding := range findings {
+		logger := methodLogger(command, file, finding.Method)
+		switch c.severity(finding.Rule) {
+		case SeverityOff:
+		case SeverityError:
+			logger.Error(finding.Message, "rule", finding.Rule, "pos", finding.Pos.String())
+			errorCount++
+		default:
+			logger.Warn(finding.Message, "rule", finding.Rule, "pos", finding.Pos.String())
+		}
+	}
+	return errorCount
+}
+
+// fixFeedback は、生成し直す対象の（error の）指摘をメソッドごとにまとめます。Fix が無効なら nil です。
+func (c *ChecksConfig) fixFeedback(findings []Finding) map[string][]string {
+	if !c.Fix {
+		return nil
+	}
+	feedback := make(map[string][]string)
+	for _, f := range findings {
+		if c.severity(f.Rule) == SeverityError {
+			feedback[f.Method] = append(feedback[f.Method], f.Message)
+		}
+	}
+	return feedback
+}
+
+// RunCodeChecks はすべてのチェックを実行し、見つかった問題を位置順に返します。
+func RunCodeChecks(c *CheckContext) []Finding {
+	var findings []Finding
+	for _, check := range codeChecks {
+		findings = append(findings, check.Run(c)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Pos.Offset < findings[j].Pos.Offset
+	})
+	return findings
+}
+
+// NewCheckContext は整形済みの生成コードから、methods の実装を拾い出したチェック用の情報を作ります。
+func NewCheckContext(fset *token.FileSet, file *ast.File, methods []string, dbPkg *DBPackage) *CheckContext {
+	wanted := make(map[string]bool)
+	for _, m := range methods {
+		wanted[m] = true
+	}
+	c := &CheckContext{Fset: fset, File: file, Methods: make(map[string]*ast.FuncDecl), DBPackage: dbPkg}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Body == nil || !wanted[fn.Name.Name] {
+			continue
+		}
+		c.Methods[fn.Name.Name] = fn
+	}
+	return c
+}
+
+// sortedMethods はメソッドをソース上の順に返します。
+func (c *CheckContext) sortedMethods() []string {
+	var names []string
+	for name := range c.Methods {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.Methods[names[i]].Pos() < c.Methods[names[j]].Pos()
+	})
+	return names
+}
+
+// importNames はファイル内でのパッケージの参照名 → インポートパスの対応を返します。
+func (c *CheckContext) importNames() map[string]string {
+	result := make(map[string]string)
+	for _, imp := range c.File.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(p)
+		if len(name) > 1 && name[0] == 'v' && name[1] >= '0' && name[1] <= '9' {
+			// github.com/jackc/pgx/v5 のようなメジャーバージョン付きのパス
+			name = path.Base(path.Dir(p))
+		}
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		result[name] = p
+	}
+	return result
+}
+
+// newFinding は node の位置で Finding を作ります。
+func (c *CheckContext) newFinding(rule, method string, node ast.Node, format string, args ...interface{}) Finding {
+	return Finding{
+		Rule:    rule,
+		Method:  method,
+		Pos:     c.Fset.Position(node.Pos()),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// checkDriverAPI は、sqlc.yml のドライバーとは別のドライバーの API（sql.ErrNoRows と pgx.Tx の混在など）を使っていないか、
+// また ErrNoRows を == で比較していないかを確認します。
+func checkDriverAPI(c *CheckContext) []Finding {
+	driver := c.DBPackage.Driver
+	if driver == nil {
+		return nil
+	}
+	imports := c.importNames()
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		ast.Inspect(c.Methods[method].Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				pkgIdent, ok := n.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				importPath, ok := imports[pkgIdent.Name]
+				if !ok {
+					return true
+				}
+				forbidden, ok := driver.foreignAPIs[importPath]
+				if !ok {
+					return true
+				}
+				if forbidden == nil || containsString(forbidden, n.Sel.Name) {
+					findings = append(findings, c.newFinding("driver-api", method, n,
+						"%s.%s belongs to %s, but sqlc is configured with %s (use %s and %s)",
+						pkgIdent.Name, n.Sel.Name, importPath, driver.Name, driver.ErrNoRows, driver.TxType))
+				}
+			case *ast.BinaryExpr:
+				if n.Op != token.EQL && n.Op != token.NEQ {
+					return true
+				}
+				for _, operand := range []ast.Expr{n.X, n.Y} {
+					if sel, ok := operand.(*ast.SelectorExpr); ok && sel.Sel.Name == "ErrNoRows" {
+						findings = append(findings, c.newFinding("driver-api", method, n,
+							"compare errors with errors.Is(err, %s) instead of %s", driver.ErrNoRows, n.Op))
+						break
+					}
+				}
+			}
+			return true
+		})
+	}
+	return findings
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// parseSource はテスト用のソースをパースします。
+func parseSource(t *testing.T, src string) (*token.FileSet, *ast.File) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "user.go", src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse source: %v", err)
+	}
+	return fset, file
+}
+
+// runChecksOn は src をパースし、methods の実装に対してチェックを実行します。
+func runChecksOn(t *testing.T, src string, methods []string, dbPkg *DBPackage) []Finding {
+	t.Helper()
+	fset, file := parseSource(t, src)
+	return RunCodeChecks(NewCheckContext(fset, file, methods, dbPkg))
+}
+
+func TestCheckDriverAPI(t *testing.T) {
+	src := `package infra
+
+import (
+	"go/ast"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type UserRepositoryImpl struct{}
+
+func (r *UserRepositoryImpl) FindByID(ctx context.Context, id int64) error {
+	err := errors.New("x")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	return nil
+}
+
+func (r *UserRepositoryImpl) Count(ctx context.Context) error {
+	err := errors.New("x")
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return nil
+}
+`
+	findings := runChecksOn(t, src, []string{"FindByID", "Count"}, &DBPackage{Driver: pgxV5Driver})
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d: %v", len(findings), findings)
+	}
+	if !strings.Contains(findings[0].Message, "sql.ErrNoRows belongs to database/sql") || findings[0].Method != "FindByID" {
+		t.Errorf("unexpected first finding: %v", findings[0])
+	}
+	if !strings.Contains(findings[1].Message, "errors.Is(err, pgx.ErrNoRows)") {
+		t.Errorf("unexpected second finding: %v", findings[1])
+	}
+
+	findings = runChecksOn(t, src, []string{"Count"}, &DBPackage{Driver: databaseSQLDriver})
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, "pgx.ErrNoRows belongs to github.com/jackc/pgx/v5") {
+		t.Errorf("expected pgx usage to be reported for database/sql, got %v", findings)
+	}
+}
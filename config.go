@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configPath はプロジェクトルートに置く llm-sqlc 自身の設定ファイルです。
+const configPath = "llm-sqlc.yml"
+
+// Config は llm-sqlc.yml の内容です。ファイルが存在しない場合はゼロ値を既定値として扱います。
+type Config struct {
+	// Model は生成に使うモデルです（既定: gpt-4.1-mini）。
+	Model string `yaml:"model"`
+
+	// Budgets はモデルごとの、プロンプトに使ってよいトークン数の上限です。
+	// 超える場合は関係の薄いテーブル・エンティティ・モデルを削ってから送ります。
+	Budgets map[string]int `yaml:"budgets"`
+
+	// SQLCPackages は infra 側のディレクトリと sqlc の sql ブロックの対応付けです。
+	// sqlc.yml に複数の sql ブロックがある場合、ここで明示された対応が優先されます。
+	SQLCPackages []SQLCPackageMapping `yaml:"sqlc_packages"`
+
+	// Cache はキャッシュのインターフェースの場所と、インターフェース・メソッドごとのキャッシュ戦略です。
+	Cache CacheConfig `yaml:"cache"`
+
+	// Prompts はプロジェクト固有のプロンプトテンプレートの置き場所です。
+	Prompts PromptsConfig `yaml:"prompts"`
+
+	// Examples は既存の実装を手本としてプロンプトに含める設定です。
+	Examples ExamplesConfig `yaml:"examples"`
+
+	// Usage はトークン使用量と料金の記録方法です。
+	Usage UsageConfig `yaml:"usage"`
+
+	// Requests はモデル呼び出しのタイムアウト・再試行・同時実行数です。
+	Requests RequestsConfig `yaml:"requests"`
+
+	// Transactions はトランザクションプロバイダーの場所です。
+	Transactions TransactionsConfig `yaml:"transactions"`
+
+	// Errors は DB のエラーからドメインエラーへの変換です。
+	Errors ErrorsConfig `yaml:"errors"`
+
+	// Fake はインメモリのフェイクと適合テストの生成設定です。
+	Fake FakeConfig `yaml:"fake"`
+
+	// Pagination は一覧系メソッドのページングの方式と結果の型です。
+	Pagination PaginationConfig `yaml:"pagination"`
+
+	// Checks は生成コードの事後チェックの重大度です。
+	Checks ChecksConfig `yaml:"checks"`
+
+	// Conventions は論理削除・テナント・監査用の列について、すべてのクエリが守るべき規約です。
+	Conventions ConventionsConfig `yaml:"conventions"`
+}
+
+// SQLCPackageMapping は、Infra 以下の infra ファイルを Package で指定した sql ブロックに対応付けます。
+type SQLCPackageMapping struct {
+	Infra   string `yaml:"infra"`   // 例: pkg/infra/readdb
+	Package string `yaml:"package"` // sql ブロックの name または gen.go.package
+}
+
+// LoadConfig はプロジェクトルートの llm-sqlc.yml を読み込みます。
+func LoadConfig() (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	if err := cfg.Cache.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	if err := cfg.Errors.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	if err := cfg.Requests.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	if err := cfg.Pagination.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	if err := cfg.Checks.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	return cfg, nil
+}
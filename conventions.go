@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConventionsConfig は、該当する列を持つテーブルに対するすべてのクエリが守るべき規約です。
+// 列を持たないテーブルには適用しません。
+type ConventionsConfig struct {
+	// SoftDelete は論理削除の列です（例: deleted_at）。読み取りと更新は IS NULL で絞り込み、DELETE の代わりにこの列を設定します。
+	SoftDelete string `yaml:"soft_delete"`
+	// Tenant はテナントの列と、ctx からテナントを取り出す方法です。
+	Tenant TenantConvention `yaml:"tenant"`
+	// CreatedAt と UpdatedAt は監査用の列です（例: created_at, updated_at）。
+	CreatedAt string `yaml:"created_at"`
+	UpdatedAt string `yaml:"updated_at"`
+}
+
+// TenantConvention はマルチテナントの規約です。
+type TenantConvention struct {
+	// Column はテナントの列です（例: tenant_id）。すべてのクエリをこの列で絞り込みます。
+	Column string `yaml:"column"`
+	// Context は ctx からテナントを取り出す Go の式です（例: tenant.FromContext(ctx)）。
+	Context string `yaml:"context"`
+}
+
+func (c *ConventionsConfig) enabled() bool {
+	return c != nil && (c.SoftDelete != "" || c.Tenant.Column != "" || c.CreatedAt != "" || c.UpdatedAt != "")
+}
+
+// tablesWith は column を持つテーブルの名前を返します。
+func tablesWith(schema *Schema, column string) []string {
+	var names []string
+	if schema == nil || column == "" {
+		return nil
+	}
+	for _, t := range schema.Tables {
+		for _, col := range t.Columns {
+			if col.Name == column {
+				names = append(names, t.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// WarnUnusedConventions は、規約の列がスキーマのどのテーブルにもない場合に警告します（列名の書き間違いなど）。
+func WarnUnusedConventions(c *ConventionsConfig, schema *Schema) {
+	if !c.enabled() || schema == nil || len(schema.Tables) == 0 {
+		return
+	}
+	for _, column := range []string{c.SoftDelete, c.Tenant.Column, c.CreatedAt, c.UpdatedAt} {
+		if column != "" && len(tablesWith(schema, column)) == 0 {
+			slog.Warn("convention column not found in any table of the schema", "column", column)
+		}
+	}
+}
+
+// ConventionsSQLGuidance は、規約と、それが適用されるテーブルの説明です。規約がなければ空文字を返します。
+func ConventionsSQLGuidance(c *ConventionsConfig, schema *Schema) string {
+	if !c.enabled() {
+		return ""
+	}
+	var lines []string
+	applies := func(column string) string {
+		if tables := tablesWith(schema, column); len(tables) > 0 {
+			return " This applies to the tables " + strings.Join(tables, ", ") + "."
+		}
+		return " This applies to every table that has the column."
+	}
+	if c.SoftDelete != "" {
+		lines = append(lines, fmt.Sprintf("- Soft delete: rows with %[1]s set are deleted. Every SELECT and UPDATE must filter %[1]s IS NULL for each of these tables, including joined ones (e.g. AND p.%[1]s IS NULL). Never DELETE from them; set %[1]s = now() instead.%[2]s",
+			c.SoftDelete, applies(c.SoftDelete)))
+	}
+	if c.Tenant.Column != "" {
+		lines = append(lines, fmt.Sprintf("- Tenancy: every query must be scoped by the tenant. Add %[1]s = @%[1]s to the WHERE clause for each of these tables, including joined ones, and set %[1]s = @%[1]s in every INSERT. Never take the tenant from other rows.%[2]s",
+			c.Tenant.Column, applies(c.Tenant.Column)))
+	}
+	if c.CreatedAt != "" {
+		lines = append(lines, fmt.Sprintf("- Audit: %[1]s is set once when the row is inserted (now() or the column default). Never update it.%[2]s",
+			c.CreatedAt, applies(c.CreatedAt)))
+	}
+	if c.UpdatedAt != "" {
+		lines = append(lines, fmt.Sprintf("- Audit: every UPDATE must set %[1]s = now().%[2]s", c.UpdatedAt, applies(c.UpdatedAt)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ConventionsProgramGuidance は、実装側で守るべき規約（テナントの取り出し方）の説明です。該当しなければ空文字を返します。
+func ConventionsProgramGuidance(c *ConventionsConfig) string {
+	if c == nil || c.Tenant.Column == "" {
+		return ""
+	}
+	source := "the tenant stored in ctx"
+	if c.Tenant.Context != "" {
+		source = c.Tenant.Context
+	}
+	return fmt.Sprintf("The queries are scoped by %s. Take the tenant from %s and pass it to every query that has the %s parameter. Never take the tenant from the arguments or from other rows.",
+		c.Tenant.Column, source, c.Tenant.Column)
+}
+
+// sqlTableRef はクエリが参照するテーブルと、その別名です。
+type sqlTableRef struct {
+	Name  string
+	Alias string
+}
+
+var (
+	sqlTableRefPattern = regexp.MustCompile(`(?i)\b(from|join|update|into)\s+([A-Za-z_][\w.]*)(?:\s+(?:as\s+)?([A-Za-z_]\w*))?`)
+	sqlInsertPattern   = regexp.MustCompile(`(?is)\binsert\s+into\s+[A-Za-z_][\w.]*(?:\s+(?:as\s+)?[A-Za-z_]\w*)?\s*\(([^)]*)\)`)
+	sqlSetPattern      = regexp.MustCompile(`(?is)\bset\b(.*?)(?:\bwhere\b|\breturning\b|\bfrom\b|$)`)
+)
+
+// sqlKeywords は別名と見なさない、テーブル名の後に続くキーワードです。
+var sqlKeywords = map[string]bool{
+	"where": true, "join": true, "inner": true, "left": true, "right": true, "full": true, "cross": true, "natural": true,
+	"on": true, "using": true, "set": true, "values": true, "select": true, "group": true, "order": true, "limit": true,
+	"offset": true, "returning": true, "union": true, "having": true, "for": true, "default": true, "as": true,
+	"lateral": true, "outer": true, "window": true, "except": true, "intersect": true,
+}
+
+// referencedTables は stripped（コメントと文字列を取り除いたクエリ）が参照するテーブルを出現順に返します。
+func referencedTables(stripped string) []sqlTableRef {
+	var refs []sqlTableRef
+	for _, m := range sqlTableRefPattern.FindAllStringSubmatch(stripped, -1) {
+		ref := sqlTableRef{Name: schemaObjectName(m[2])}
+		if m[3] != "" && !sqlKeywords[strings.ToLower(m[3])] {
+			ref.Alias = m[3]
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// predicateQualifiers は pattern（列名の前に修飾子を取れる述語）に一致する箇所の修飾子を返します。修飾子がなければ空文字です。
+func predicateQualifiers(stripped string, pattern *regexp.Regexp) map[string]bool {
+	qualifiers := make(map[string]bool)
+	for _, m := range pattern.FindAllStringSubmatch(stripped, -1) {
+		qualifiers[strings.ToLower(m[1])] = true
+	}
+	return qualifiers
+}
+
+// columnPredicate は "[修飾子.]column <rest>" に一致する正規表現を作ります。
+func columnPredicate(column string, rest string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:\b(\w+)\.)?\b` + regexp.QuoteMeta(column) + `\s*` + rest)
+}
+
+// missingPredicate は、column を持つ refs のテーブルのうち、述語が見つからないものを返します。
+// 修飾子のない述語は、column を持つテーブルが1つだけのときにそのテーブルのものとみなします。
+func missingPredicate(refs []sqlTableRef, withColumn []string, qualifiers map[string]bool) []string {
+	var targets []sqlTableRef
+	for _, ref := range refs {
+		if containsString(withColumn, ref.Name) {
+			targets = append(targets, ref)
+		}
+	}
+	var missing []string
+	for _, ref := range targets {
+		if qualifiers[strings.ToLower(ref.Name)] || (ref.Alias != "" && qualifiers[strings.ToLower(ref.Alias)]) {
+			continue
+		}
+		if qualifiers[""] && len(targets) == 1 {
+			continue
+		}
+		if !containsString(missing, ref.Name) {
+			missing = append(missing, ref.Name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// checkSoftDelete は、論理削除の列を持つテーブルを IS NULL で絞り込んでいない読み取り・更新と、物理削除を報告します。
+// IS NOT NULL や = @x、SET での代入など、列を明示的に扱っているクエリ（削除済みの一覧や復元）は意図したものとみなします。
+func checkSoftDelete(q NamedQuery, c *SQLCheckContext) []string {
+	if c.Conventions == nil || c.Conventions.SoftDelete == "" {
+		return nil
+	}
+	column := c.Conventions.SoftDelete
+	withColumn := tablesWith(c.Schema, column)
+	stripped := stripSQLComments(q.SQL)
+	refs := referencedTables(stripped)
+	var messages []string
+	switch sqlVerb(q.SQL) {
+	case "DELETE":
+		// DELETE FROM の対象（先頭の参照）だけが物理削除になる
+		if len(refs) > 0 && containsString(withColumn, refs[0].Name) {
+			messages = append(messages, fmt.Sprintf("deletes rows of %s, which uses soft delete; UPDATE %s SET %s = now() instead", refs[0].Name, refs[0].Name, column))
+		}
+	case "SELECT", "UPDATE":
+		qualifiers := predicateQualifiers(stripped, columnPredicate(column, `(?:is\b|=|<>|!=|<|>|in\b|between\b)`))
+		if missing := missingPredicate(refs, withColumn, qualifiers); len(missing) > 0 {
+			messages = append(messages, fmt.Sprintf("does not filter %s IS NULL for %s; deleted rows would be returned or changed", column, strings.Join(missing, ", ")))
+		}
+	}
+	return messages
+}
+
+// checkTenant は、テナントの列を持つテーブルをテナントで絞り込んでいないクエリと、テナントを設定しない INSERT を報告します。
+func checkTenant(q NamedQuery, c *SQLCheckContext) []string {
+	if c.Conventions == nil || c.Conventions.Tenant.Column == "" {
+		return nil
+	}
+	column := c.Conventions.Tenant.Column
+	withColumn := tablesWith(c.Schema, column)
+	stripped := stripSQLComments(q.SQL)
+	refs := referencedTables(stripped)
+	var messages []string
+	if sqlVerb(q.SQL) == "INSERT" && len(refs) > 0 && containsString(withColumn, refs[0].Name) {
+		m := sqlInsertPattern.FindStringSubmatch(stripped)
+		if m == nil || !regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(column)+`\b`).MatchString(m[1]) {
+			messages = append(messages, fmt.Sprintf("inserts into %s without %s", refs[0].Name, column))
+		}
+		// INSERT ... SELECT の読み取り側も絞り込む
+		refs = refs[1:]
+	}
+	// UPDATE の SET tenant_id = ... は絞り込みではない（別のテナントへ書き換える）ので、WHERE と ON だけを見る
+	scope := stripped
+	if loc := sqlSetPattern.FindStringSubmatchIndex(stripped); loc != nil && sqlVerb(q.SQL) == "UPDATE" {
+		scope = stripped[:loc[2]] + stripped[loc[3]:]
+	}
+	qualifiers := predicateQualifiers(scope, columnPredicate(column, `(?:=|in\b)`))
+	if missing := missingPredicate(refs, withColumn, qualifiers); len(missing) > 0 {
+		messages = append(messages, fmt.Sprintf("is not scoped by %s for %s; rows of other tenants would be read or changed", column, strings.Join(missing, ", ")))
+	}
+	return messages
+}
+
+// checkAudit は、更新日時の列を設定しない UPDATE と、作成日時の列を書き換える UPDATE を報告します。
+func checkAudit(q NamedQuery, c *SQLCheckContext) []string {
+	if c.Conventions == nil || sqlVerb(q.SQL) != "UPDATE" {
+		return nil
+	}
+	stripped := stripSQLComments(q.SQL)
+	refs := referencedTables(stripped)
+	if len(refs) == 0 {
+		return nil
+	}
+	table := refs[0].Name
+	set := ""
+	if m := sqlSetPattern.FindStringSubmatch(stripped); m != nil {
+		set = m[1]
+	}
+	var messages []string
+	if column := c.Conventions.UpdatedAt; column != "" && containsString(tablesWith(c.Schema, column), table) &&
+		!columnPredicate(column, `=`).MatchString(set) {
+		messages = append(messages, fmt.Sprintf("updates %s without setting %s = now()", table, column))
+	}
+	if column := c.Conventions.CreatedAt; column != "" && containsString(tablesWith(c.Schema, column), table) &&
+		columnPredicate(column, `=`).MatchString(set) {
+		messages = append(messages, fmt.Sprintf("changes %s of %s, which must keep the time the row was created", column, table))
+	}
+	return messages
+}
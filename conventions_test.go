@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleConventionSchema = `
+CREATE TABLE users (
+  id bigserial PRIMARY KEY,
+  tenant_id bigint NOT NULL,
+  name text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  deleted_at timestamptz
+);
+
+CREATE TABLE posts (
+  id bigserial PRIMARY KEY,
+  tenant_id bigint NOT NULL,
+  author_id bigint NOT NULL REFERENCES users(id),
+  deleted_at timestamptz
+);
+
+CREATE TABLE countries (
+  code text PRIMARY KEY
+);
+`
+
+func TestConventionChecks(t *testing.T) {
+	conventions := &ConventionsConfig{
+		SoftDelete: "deleted_at",
+		Tenant:     TenantConvention{Column: "tenant_id", Context: "tenant.FromContext(ctx)"},
+		CreatedAt:  "created_at",
+		UpdatedAt:  "updated_at",
+	}
+	c := &SQLCheckContext{Engine: "postgresql", Schema: ParseSchema(sampleConventionSchema), Conventions: conventions}
+
+	src := `-- name: GetUser :one
+SELECT * FROM users WHERE id = @id AND tenant_id = @tenant_id AND deleted_at IS NULL;
+
+-- name: ListPostsWithAuthor :many
+SELECT p.id, u.name FROM posts p
+JOIN users u ON u.id = p.author_id
+WHERE p.tenant_id = @tenant_id AND u.tenant_id = @tenant_id AND p.deleted_at IS NULL;
+
+-- name: CreatePost :one
+INSERT INTO posts (author_id) VALUES (@author_id) RETURNING *;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = @id AND tenant_id = @tenant_id;
+
+-- name: RenameUser :exec
+UPDATE users SET name = @name, created_at = now()
+WHERE id = @id AND tenant_id = @tenant_id AND deleted_at IS NULL;
+
+-- name: ListCountries :many
+SELECT code FROM countries;
+
+-- name: RestoreUser :exec
+UPDATE users SET deleted_at = NULL, updated_at = now()
+WHERE id = @id AND tenant_id = @tenant_id AND deleted_at IS NOT NULL;
+
+-- name: ListDeletedUsers :many
+SELECT id FROM users WHERE tenant_id = @tenant_id AND deleted_at IS NOT NULL;
+
+-- name: ListUsersDeletedSince :many
+SELECT id FROM users WHERE tenant_id = @tenant_id AND deleted_at >= @since;
+
+-- name: MoveUser :exec
+UPDATE users SET tenant_id = @tenant_id, updated_at = now()
+WHERE id = @id AND deleted_at IS NULL;
+`
+	var got []string
+	for _, f := range LintQueries("user.sql", 1, "All", src, c) {
+		got = append(got, "["+f.Rule+"] "+f.Message)
+	}
+	want := []string{
+		"[convention-soft-delete] ListPostsWithAuthor: does not filter deleted_at IS NULL for users; deleted rows would be returned or changed",
+		"[convention-tenant] CreatePost: inserts into posts without tenant_id",
+		"[convention-soft-delete] DeleteUser: deletes rows of users, which uses soft delete; UPDATE users SET deleted_at = now() instead",
+		"[convention-audit] RenameUser: updates users without setting updated_at = now()",
+		"[convention-audit] RenameUser: changes created_at of users, which must keep the time the row was created",
+		"[convention-tenant] MoveUser: is not scoped by tenant_id for users; rows of other tenants would be read or changed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	guidance := ConventionsSQLGuidance(conventions, c.Schema)
+	for _, want := range []string{
+		"filter deleted_at IS NULL for each of these tables, including joined ones",
+		"This applies to the tables users, posts.",
+		"Add tenant_id = @tenant_id to the WHERE clause",
+		"every UPDATE must set updated_at = now(). This applies to the tables users.",
+	} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("expected guidance to contain %q:\n%s", want, guidance)
+		}
+	}
+	if program := ConventionsProgramGuidance(conventions); !strings.Contains(program, "Take the tenant from tenant.FromContext(ctx)") {
+		t.Errorf("unexpected program guidance: %s", program)
+	}
+	if ConventionsSQLGuidance(&ConventionsConfig{}, c.Schema) != "" {
+		t.Errorf("expected no guidance without conventions")
+	}
+}
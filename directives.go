@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// directivePrefix はインターフェースのメソッドに付けて生成を調整するコメントの接頭辞です。
+// //go:generate と同じくスペースを空けずに書くため、go/ast の CommentGroup.Text() からは取り除かれます。
+const directivePrefix = "//llmsqlc:"
+
+// MethodDirectives はインターフェースのメソッドに付けられた //llmsqlc: ディレクティブです。
+type MethodDirectives struct {
+	Skip    bool     // skip: 生成せず、現在のクエリと実装をそのまま残す
+	NoCache bool     // nocache: キャッシュのポリシーにかかわらずキャッシュを使わない
+	Tx      bool     // tx: クエリの種類にかかわらずトランザクションの中で実行する
+	Queries []string // query=Name: 新しいクエリを作らず、sqlc が生成済みのクエリを使う
+	Model   string   // model=...: このメソッドの生成に使うモデル
+}
+
+// parseDirectives は doc と行末のコメントから //llmsqlc: ディレクティブを読み取ります。
+// 1行に空白で区切って複数書くこともでき、query は query=A,B のようにまとめても、繰り返し書いてもかまいません。
+func parseDirectives(groups ...*ast.CommentGroup) (MethodDirectives, error) {
+	var d MethodDirectives
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			if !strings.HasPrefix(comment.Text, directivePrefix) {
+				continue
+			}
+			for _, field := range strings.Fields(strings.TrimPrefix(comment.Text, directivePrefix)) {
+				name, value, hasValue := strings.Cut(field, "=")
+				switch {
+				case name == "skip" && !hasValue:
+					d.Skip = true
+				case name == "nocache" && !hasValue:
+					d.NoCache = true
+				case name == "tx" && !hasValue:
+					d.Tx = true
+				case name == "query" && value != "":
+					for _, q := range strings.Split(value, ",") {
+						if q = strings.TrimSpace(q); q != "" && !containsString(d.Queries, q) {
+							d.Queries = append(d.Queries, q)
+						}
+					}
+				case name == "model" && value != "":
+					d.Model = value
+				default:
+					return d, fmt.Errorf("unknown directive %q (expected skip, nocache, tx, query=Name or model=Name)", directivePrefix+field)
+				}
+			}
+		}
+	}
+	if d.Skip && len(d.Queries) > 0 {
+		return d, fmt.Errorf("%sskip and %squery cannot be used together", directivePrefix, directivePrefix)
+	}
+	return d, nil
+}
+
+// methodNotes は doc から、ディレクティブを除いた自由記述の説明を返します。
+func methodNotes(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+// modelFor は method の生成に使うモデルを返します。model= の指定がなければ設定のモデルです。
+func (c *Config) modelFor(method InterfaceMethod) string {
+	return defaultString(method.Directives.Model, c.model())
+}
+
+// sqlSkipReason は、SQL を生成しないメソッドについて、その理由となるディレクティブを返します。生成するなら空文字です。
+func (d MethodDirectives) sqlSkipReason() string {
+	switch {
+	case d.Skip:
+		return directivePrefix + "skip"
+	case len(d.Queries) > 0:
+		return directivePrefix + "query=" + strings.Join(d.Queries, ",")
+	}
+	return ""
+}
+
+// reusedQueries は query= で指定された、sqlc が生成済みのクエリを返します。
+// code がない場合や、生成されたコードにクエリが見つからない場合はエラーを返します。
+func reusedQueries(code *SQLCCode, method InterfaceMethod) ([]NamedQuery, error) {
+	if code == nil {
+		return nil, fmt.Errorf("method %s: %squery needs the sqlc generated code, run sqlc generate first", method.Name, directivePrefix)
+	}
+	var queries []NamedQuery
+	for _, name := range method.Directives.Queries {
+		q, ok := code.Query(name)
+		if !ok {
+			return nil, fmt.Errorf("method %s: query %s is not found in the sqlc generated code in %s", method.Name, name, code.pkg.Dir)
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// stripDirectives はインターフェースのソースから //llmsqlc: ディレクティブを取り除きます。
+// ディレクティブは llm-sqlc への指示なので、プロンプトには含めません（書き出すファイルには残します）。
+func stripDirectives(src string) string {
+	lines := strings.Split(src, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		i := strings.Index(line, directivePrefix)
+		if i < 0 {
+			kept = append(kept, line)
+			continue
+		}
+		if rest := strings.TrimRight(line[:i], " \t"); rest != "" {
+			kept = append(kept, rest)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractInterfaceDirectives(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "user.go")
+	source := `package infra
+
+type UserRepository interface {
+	// FindByEmail はメールアドレスでユーザーを探します。
+	// idx_users_email のインデックスを使うこと。
+	//llmsqlc:nocache model=gpt-4.1
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	//llmsqlc:query=GetUser,ListUsers
+	//llmsqlc:query=GetUser
+	Find(ctx context.Context, id entity.UserID) (*entity.User, error)
+	Save(ctx context.Context, user *entity.User) error //llmsqlc:tx
+	Delete(ctx context.Context, id entity.UserID) error
+}`
+	if err := os.WriteFile(filePath, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to write temporary file: %v", err)
+	}
+
+	_, methods, err := ExtractInterface(filePath)
+	if err != nil {
+		t.Fatalf("ExtractInterface() error: %v", err)
+	}
+	if len(methods) != 4 {
+		t.Fatalf("expected 4 methods, got %d", len(methods))
+	}
+	if want := "FindByEmail はメールアドレスでユーザーを探します。\nidx_users_email のインデックスを使うこと。"; methods[0].Doc != want {
+		t.Errorf("expected the doc comment without directives, got %q", methods[0].Doc)
+	}
+	if want := (MethodDirectives{NoCache: true, Model: "gpt-4.1"}); !reflect.DeepEqual(methods[0].Directives, want) {
+		t.Errorf("unexpected directives of FindByEmail: %+v", methods[0].Directives)
+	}
+	if want := []string{"GetUser", "ListUsers"}; !reflect.DeepEqual(methods[1].Directives.Queries, want) || methods[1].Doc != "" {
+		t.Errorf("unexpected directives of Find: %+v (doc %q)", methods[1].Directives, methods[1].Doc)
+	}
+	if !methods[2].Directives.Tx {
+		t.Errorf("expected a trailing directive to be read: %+v", methods[2].Directives)
+	}
+	if !reflect.DeepEqual(methods[3].Directives, MethodDirectives{}) {
+		t.Errorf("expected no directives on Delete: %+v", methods[3].Directives)
+	}
+
+	cfg := &Config{Model: "gpt-4.1-mini"}
+	if got := cfg.modelFor(methods[0]); got != "gpt-4.1" {
+		t.Errorf("expected the model directive to win, got %q", got)
+	}
+	if got := cfg.modelFor(methods[3]); got != "gpt-4.1-mini" {
+		t.Errorf("expected the configured model, got %q", got)
+	}
+}
+
+func TestExtractInterfaceInvalidDirective(t *testing.T) {
+	for _, directive := range []string{"//llmsqlc:nocahce", "//llmsqlc:query=", "//llmsqlc:skip query=GetUser"} {
+		filePath := filepath.Join(t.TempDir(), "user.go")
+		source := "package infra\n\ntype UserRepository interface {\n\t" + directive + "\n\tFind(ctx context.Context) error\n}\n"
+		if err := os.WriteFile(filePath, []byte(source), 0644); err != nil {
+			t.Fatalf("failed to write temporary file: %v", err)
+		}
+		_, _, err := ExtractInterface(filePath)
+		if err == nil || !strings.Contains(err.Error(), "method Find") {
+			t.Errorf("expected an error for %q, got %v", directive, err)
+		}
+	}
+}
+
+// emailQueryCode は、sqlc が GetUserByEmail のために生成したコードです。
+const emailQueryCode = `package db
+
+import "context"
+
+const getUserByEmail = ` + "`" + `-- name: GetUserByEmail :one
+SELECT id, name FROM users WHERE email = $1
+` + "`" + `
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	var i User
+	_ = getUserByEmail
+	return i, nil
+}
+`
+
+func TestDirectivesInPrompts(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		"pkg/infra/user.go": `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/domain/entity"
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+	// メールアドレスは小文字にそろえて比較する。
+	//llmsqlc:query=GetUserByEmail nocache tx
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	Save(ctx context.Context, user *entity.User) error
+}
+
+type UserRepositoryImpl struct {
+	Cache Cache
+}
+
+var _ UserRepository = UserRepositoryImpl{}
+`,
+		"pkg/infra/db/email.sql.go": emailQueryCode,
+	})
+
+	sqlPrompt, err := DumpPrompt("sql", "pkg/infra/user.go", "FindByEmail")
+	if err != nil {
+		t.Fatalf("DumpPrompt(sql) error: %v", err)
+	}
+	if !strings.Contains(sqlPrompt, "# About FindByEmail") || !strings.Contains(sqlPrompt, "メールアドレスは小文字にそろえて比較する。") {
+		t.Errorf("expected the doc comment in the SQL prompt:\n%s", sqlPrompt)
+	}
+	if strings.Contains(sqlPrompt, "llmsqlc:") {
+		t.Errorf("expected directives to be left out of the SQL prompt:\n%s", sqlPrompt)
+	}
+
+	programPrompt, err := DumpPrompt("program", "pkg/infra/user.go", "FindByEmail")
+	if err != nil {
+		t.Fatalf("DumpPrompt(program) error: %v", err)
+	}
+	for _, want := range []string{"# About FindByEmail", "func (q *Queries) GetUserByEmail(", "marked with //llmsqlc:tx"} {
+		if !strings.Contains(programPrompt, want) {
+			t.Errorf("expected program prompt to contain %q:\n%s", want, programPrompt)
+		}
+	}
+	for _, unwanted := range []string{"func (q *Queries) GetUser(", "repo.Cache.Get("} {
+		if strings.Contains(programPrompt, unwanted) {
+			t.Errorf("expected program prompt not to contain %q:\n%s", unwanted, programPrompt)
+		}
+	}
+
+	programPrompt, err = DumpPrompt("program", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(program) error: %v", err)
+	}
+	if strings.Contains(programPrompt, "# About") {
+		t.Errorf("expected no notes section for a method without a doc comment:\n%s", programPrompt)
+	}
+}
+
+func TestQueryDirectiveOverridesManifest(t *testing.T) {
+	// マニフェストは query= を書く前の SQL 生成で、FindByID に GetUser を記録している
+	setupSampleProject(t, map[string]string{
+		"pkg/infra/user.go": `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/domain/entity"
+)
+
+type UserRepository interface {
+	//llmsqlc:query=GetUserByEmail
+	FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+	Save(ctx context.Context, user *entity.User) error
+}
+
+type UserRepositoryImpl struct {
+	Cache Cache
+}
+
+var _ UserRepository = UserRepositoryImpl{}
+`,
+		"pkg/infra/sql/query/user.llm-sqlc.json": `{
+  "infra_file": "pkg/infra/user.go",
+  "query_file": "pkg/infra/sql/query/user.sql",
+  "methods": [
+    {"method": "FindByID", "queries": [{"name": "GetUser", "kind": ":one", "verb": "SELECT", "params": ["id"]}]},
+    {"method": "Save", "queries": [{"name": "UpsertUser", "kind": ":exec", "verb": "INSERT", "params": ["id", "name"]}]}
+  ]
+}
+`,
+		"pkg/infra/db/email.sql.go": emailQueryCode,
+	})
+
+	g, err := newProgramGenerator("pkg/infra/user.go")
+	if err != nil {
+		t.Fatalf("newProgramGenerator() error: %v", err)
+	}
+	if got := g.manifest.Method("FindByID").QueryNames(); !reflect.DeepEqual(got, []string{"GetUserByEmail"}) {
+		t.Errorf("expected the directive to replace the recorded queries, got %v", got)
+	}
+	if got := g.manifest.Method("Save").QueryNames(); !reflect.DeepEqual(got, []string{"UpsertUser"}) {
+		t.Errorf("expected other methods to keep the recorded queries, got %v", got)
+	}
+
+	programPrompt, err := g.prompt("FindByID")
+	if err != nil {
+		t.Fatalf("prompt() error: %v", err)
+	}
+	if !strings.Contains(programPrompt, "func (q *Queries) GetUserByEmail(") || strings.Contains(programPrompt, "func (q *Queries) GetUser(") {
+		t.Errorf("expected the program prompt to use GetUserByEmail only:\n%s", programPrompt)
+	}
+}
+
+func TestReusedQueries(t *testing.T) {
+	code := &SQLCCode{pkg: &DBPackage{Dir: "pkg/infra/db"}, sqls: map[string]string{
+		"GetUser": "-- name: GetUser :one\nSELECT id, name FROM users WHERE id = $1\n",
+	}}
+	method := InterfaceMethod{Name: "Find", Directives: MethodDirectives{Queries: []string{"GetUser"}}}
+	queries, err := reusedQueries(code, method)
+	if err != nil {
+		t.Fatalf("reusedQueries() error: %v", err)
+	}
+	if len(queries) != 1 || queries[0].Name != "GetUser" || queries[0].Kind != ":one" || sqlVerb(queries[0].SQL) != "SELECT" {
+		t.Errorf("unexpected queries: %+v", queries)
+	}
+
+	method.Directives.Queries = append(method.Directives.Queries, "ListUsers")
+	if _, err := reusedQueries(code, method); err == nil || !strings.Contains(err.Error(), "ListUsers") {
+		t.Errorf("expected an error for a missing query, got %v", err)
+	}
+	if _, err := reusedQueries(nil, method); err == nil {
+		t.Errorf("expected an error without the sqlc generated code")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrorsConfig は llm-sqlc.yml の errors セクションです。DB のエラーをプロジェクトのドメインエラーに変換させます。
+type ErrorsConfig struct {
+	// Package はドメインエラーを定義したパッケージのディレクトリです（例: pkg/domain/errs）。空ならエラーの変換を指示しません。
+	Package string `yaml:"package"`
+	// Mapping は DB のエラー（no_rows, unique_violation など）→ パッケージ内の識別子（ErrNotFound など）です。
+	// 書かなかったエラーは識別子の名前から推測します。
+	Mapping map[string]string `yaml:"mapping"`
+}
+
+// DB のエラーの種類
+const (
+	DBErrNoRows               = "no_rows"
+	DBErrUniqueViolation      = "unique_violation"
+	DBErrForeignKeyViolation  = "foreign_key_violation"
+	DBErrCheckViolation       = "check_violation"
+	DBErrSerializationFailure = "serialization_failure"
+)
+
+// dbErrorKind は DB のエラー1種類分です。
+type dbErrorKind struct {
+	Name        string
+	SQLState    string   // PostgreSQL の SQLSTATE（no_rows は空）
+	Description string   // プロンプト向けの説明
+	guesses     []string // 対応するドメインエラーを名前から推測するときの語（優先順）
+}
+
+var dbErrorKinds = []dbErrorKind{
+	{Name: DBErrNoRows, Description: "no rows", guesses: []string{"NotFound", "NoRows", "NotExist"}},
+	{Name: DBErrUniqueViolation, SQLState: "23505", Description: "unique violation", guesses: []string{"AlreadyExists", "Duplicate", "Conflict"}},
+	{Name: DBErrForeignKeyViolation, SQLState: "23503", Description: "foreign key violation", guesses: []string{"ForeignKey", "Reference", "Dependency"}},
+	{Name: DBErrCheckViolation, SQLState: "23514", Description: "check violation", guesses: []string{"Check", "Validation", "InvalidArgument", "Invalid"}},
+	{Name: DBErrSerializationFailure, SQLState: "40001", Description: "serialization failure", guesses: []string{"Serialization", "Concurrent", "Retry", "Conflict"}},
+}
+
+func (c *ErrorsConfig) validate() error {
+	for kind := range c.Mapping {
+		known := false
+		for _, k := range dbErrorKinds {
+			known = known || k.Name == kind
+		}
+		if !known {
+			return fmt.Errorf("errors.mapping: unknown database error %q", kind)
+		}
+	}
+	if len(c.Mapping) > 0 && c.Package == "" {
+		return fmt.Errorf("errors.mapping requires errors.package")
+	}
+	return nil
+}
+
+// DomainError はエラーパッケージで見つけた、エクスポートされたエラーです。
+type DomainError struct {
+	Name      string
+	Kind      string // var（ErrNotFound のような番兵）、func（エラーを作る関数）、type（error を実装する型）
+	Signature string // func の場合のシグネチャ
+}
+
+// DomainErrors はエラーパッケージの情報と、DB のエラーからの対応です。
+type DomainErrors struct {
+	Dir        string
+	Name       string // パッケージ名
+	ImportPath string
+	Errors     []DomainError
+	Mapping    map[string]string // DB のエラーの種類 → Errors の Name
+}
+
+// LoadDomainErrors は c.Package のパッケージからエクスポートされたエラーを集め、DB のエラーとの対応を決めます。
+// c.Package が空なら nil を返します。
+func LoadDomainErrors(c *ErrorsConfig) (*DomainErrors, error) {
+	if c.Package == "" {
+		return nil, nil
+	}
+	modulePath, err := goModulePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module path from go.mod: %w", err)
+	}
+	d, err := parseDomainErrors(c.Package)
+	if err != nil {
+		return nil, err
+	}
+	d.ImportPath = modulePath + "/" + filepath.ToSlash(filepath.Clean(c.Package))
+	if err := d.resolveMapping(c.Mapping); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// parseDomainErrors は dir の Go ファイル（_test.go を除く）から、エクスポートされたエラーの変数・関数・型を集めます。
+func parseDomainErrors(dir string) (*DomainErrors, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read errors package: %w", err)
+	}
+	d := &DomainErrors{Dir: dir}
+	fset := token.NewFileSet()
+	errorTypes := make(map[string]bool) // Error() string を持つ型
+	var types []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse errors package: %w", err)
+		}
+		d.Name = f.Name.Name
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						if decl.Tok != token.VAR {
+							continue
+						}
+						for _, n := range spec.Names {
+							if n.IsExported() && strings.HasPrefix(n.Name, "Err") {
+								d.Errors = append(d.Errors, DomainError{Name: n.Name, Kind: "var"})
+							}
+						}
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							types = append(types, spec.Name.Name)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv != nil {
+					if decl.Name.Name == "Error" && len(decl.Recv.List) > 0 {
+						errorTypes[strings.TrimPrefix(nodeString(fset, decl.Recv.List[0].Type), "*")] = true
+					}
+					continue
+				}
+				if decl.Name.IsExported() && returnsError(decl.Type) && decl.Type.Results.NumFields() == 1 {
+					d.Errors = append(d.Errors, DomainError{Name: decl.Name.Name, Kind: "func", Signature: nodeString(fset, decl.Type)})
+				}
+			}
+		}
+	}
+	for _, typ := range types {
+		if errorTypes[typ] {
+			d.Errors = append(d.Errors, DomainError{Name: typ, Kind: "type"})
+		}
+	}
+	if len(d.Errors) == 0 {
+		return nil, fmt.Errorf("no exported errors found in %s", dir)
+	}
+	return d, nil
+}
+
+// find は name のエラーを返します。
+func (d *DomainErrors) find(name string) *DomainError {
+	for i := range d.Errors {
+		if d.Errors[i].Name == name {
+			return &d.Errors[i]
+		}
+	}
+	return nil
+}
+
+// resolveMapping は設定された対応を確かめ、設定のない DB のエラーは名前から推測して対応付けます。
+func (d *DomainErrors) resolveMapping(configured map[string]string) error {
+	d.Mapping = make(map[string]string)
+	for kind, name := range configured {
+		if d.find(name) == nil {
+			return fmt.Errorf("errors.mapping.%s: %s is not an exported error of %s", kind, name, d.Dir)
+		}
+		d.Mapping[kind] = name
+	}
+	for _, kind := range dbErrorKinds {
+		if _, ok := d.Mapping[kind.Name]; ok {
+			continue
+		}
+	guess:
+		for _, word := range kind.guesses {
+			for _, e := range d.Errors {
+				if strings.Contains(e.Name, word) {
+					d.Mapping[kind.Name] = e.Name
+					break guess
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// expr は生成コードでエラー name を返すときの書き方です。
+func (d *DomainErrors) expr(name string) string {
+	e := d.find(name)
+	if e == nil {
+		return d.Name + "." + name
+	}
+	switch e.Kind {
+	case "func":
+		return fmt.Sprintf("%s.%s%s", d.Name, e.Name, strings.TrimPrefix(e.Signature, "func"))
+	case "type":
+		return fmt.Sprintf("&%s.%s{...}", d.Name, e.Name)
+	}
+	return d.Name + "." + e.Name
+}
+
+// DomainErrorGuidance は DB のエラーをドメインエラーに変換する方法をプロンプト向けに組み立てます。d が nil なら空文字です。
+func DomainErrorGuidance(d *DomainErrors, driver *SQLDriver) string {
+	if d == nil || len(d.Mapping) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Do not return database errors as they are. Convert them to the domain errors of package %s (import path %q):\n", d.Name, d.ImportPath))
+	for _, kind := range dbErrorKinds {
+		name, ok := d.Mapping[kind.Name]
+		if !ok {
+			continue
+		}
+		target := d.expr(name)
+		if kind.SQLState == "" {
+			b.WriteString(fmt.Sprintf("- %s (errors.Is(err, %s)) → %s. This applies only when the method must return an error for a missing record (entity-typed arguments).\n",
+				kind.Description, driver.ErrNoRows, target))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("- %s (SQLSTATE %s) → %s\n", kind.Description, kind.SQLState, target))
+	}
+	b.WriteString(fmt.Sprintf("To read the SQLSTATE, use %s.\n", driver.SQLStateError))
+	b.WriteString(fmt.Sprintf("Wrap the domain error with fmt.Errorf(\"...: %%w\", ...) when adding context so that callers can still use errors.Is or errors.As. Only use errors that exist in package %s.", d.Name))
+	return b.String()
+}
+
+// checkDomainErrors は、エラーパッケージの存在しない識別子を使っていないこと、ドライバーのエラーをそのまま返していないこと、
+// DB のエラーを判定したのに対応するドメインエラーを返していないことを確認します。
+func checkDomainErrors(c *CheckContext) []Finding {
+	d := c.Errors
+	if d == nil {
+		return nil
+	}
+	var errPkg string
+	for name, p := range c.importNames() {
+		if p == d.ImportPath {
+			errPkg = name
+		}
+	}
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		fn := c.Methods[method]
+		used := make(map[string]bool)
+		detected := make(map[string]ast.Node) // 判定している DB のエラー → 判定している箇所
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if errPkg != "" && isIdent(n.X, errPkg) {
+					if d.find(n.Sel.Name) == nil {
+						findings = append(findings, c.newFinding("domain-errors", method, n,
+							"%s.%s does not exist in %s", errPkg, n.Sel.Name, d.ImportPath))
+					}
+					used[n.Sel.Name] = true
+				}
+				if n.Sel.Name == "ErrNoRows" {
+					if _, ok := detected[DBErrNoRows]; !ok {
+						detected[DBErrNoRows] = n
+					}
+				}
+			case *ast.BasicLit:
+				for _, kind := range dbErrorKinds {
+					if kind.SQLState != "" && n.Value == `"`+kind.SQLState+`"` {
+						if _, ok := detected[kind.Name]; !ok {
+							detected[kind.Name] = n
+						}
+					}
+				}
+			case *ast.ReturnStmt:
+				for _, result := range n.Results {
+					if sel, ok := result.(*ast.SelectorExpr); ok && sel.Sel.Name == "ErrNoRows" {
+						instead := "a domain error of " + d.ImportPath
+						if name, ok := d.Mapping[DBErrNoRows]; ok {
+							instead = d.Name + "." + name
+						}
+						findings = append(findings, c.newFinding("domain-errors", method, n,
+							"returns the driver error %s; return %s instead", nodeString(c.Fset, sel), instead))
+					}
+				}
+			}
+			return true
+		})
+
+		var kinds []string
+		for kind := range detected {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			name, ok := d.Mapping[kind]
+			if !ok || used[name] {
+				continue
+			}
+			if kind == DBErrNoRows && !c.EntityArgs[method] {
+				// 基本型の引数では、レコードがなければ nil か空のスライスを返す
+				continue
+			}
+			findings = append(findings, c.newFinding("domain-errors", method, detected[kind],
+				"handles %s but does not return %s.%s", strings.ReplaceAll(kind, "_", " "), d.Name, name))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleErrorsPackage = `package errs
+
+import "errors"
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	errInternal      = errors.New("internal")
+)
+
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string { return "invalid " + e.Field }
+
+func NewConflict(msg string) error {
+	return errors.New(msg)
+}
+`
+
+func TestLoadDomainErrors(t *testing.T) {
+	setupSampleProject(t, map[string]string{"pkg/domain/errs/errs.go": sampleErrorsPackage})
+
+	d, err := LoadDomainErrors(&ErrorsConfig{
+		Package: "pkg/domain/errs",
+		Mapping: map[string]string{DBErrSerializationFailure: "NewConflict"},
+	})
+	if err != nil {
+		t.Fatalf("LoadDomainErrors error: %v", err)
+	}
+	if d.Name != "errs" || d.ImportPath != "example.com/app/pkg/domain/errs" {
+		t.Errorf("unexpected package %s (%s)", d.Name, d.ImportPath)
+	}
+	if d.find("errInternal") != nil {
+		t.Errorf("unexported errors must not be collected")
+	}
+	want := map[string]string{
+		DBErrNoRows:               "ErrNotFound",
+		DBErrUniqueViolation:      "ErrAlreadyExists",
+		DBErrCheckViolation:       "ValidationError",
+		DBErrSerializationFailure: "NewConflict",
+	}
+	for kind, name := range want {
+		if d.Mapping[kind] != name {
+			t.Errorf("mapping[%s] = %q, want %q", kind, d.Mapping[kind], name)
+		}
+	}
+	if _, ok := d.Mapping[DBErrForeignKeyViolation]; ok {
+		t.Errorf("expected no guess for foreign key violations, got %q", d.Mapping[DBErrForeignKeyViolation])
+	}
+
+	guidance := DomainErrorGuidance(d, pgxV5Driver)
+	for _, want := range []string{
+		"- no rows (errors.Is(err, pgx.ErrNoRows)) → errs.ErrNotFound.",
+		"- unique violation (SQLSTATE 23505) → errs.ErrAlreadyExists\n",
+		"- check violation (SQLSTATE 23514) → &errs.ValidationError{...}\n",
+		"- serialization failure (SQLSTATE 40001) → errs.NewConflict(msg string) error\n",
+		"*pgconn.PgError (github.com/jackc/pgx/v5/pgconn)",
+	} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("expected guidance to contain %q:\n%s", want, guidance)
+		}
+	}
+
+	if _, err := LoadDomainErrors(&ErrorsConfig{Package: "pkg/domain/errs", Mapping: map[string]string{DBErrNoRows: "ErrMissing"}}); err == nil {
+		t.Errorf("expected an error for a mapping to an error that does not exist")
+	}
+	if err := (&ErrorsConfig{Package: "pkg/domain/errs", Mapping: map[string]string{"deadlock": "ErrNotFound"}}).validate(); err == nil {
+		t.Errorf("expected an error for an unknown database error")
+	}
+}
+
+func TestCheckDomainErrors(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+	"errors"
+
+	"example.com/app/pkg/domain/errs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type UserRepositoryImpl struct{}
+
+func (repo *UserRepositoryImpl) FindByID(ctx context.Context) error {
+	var err error
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (repo *UserRepositoryImpl) FindByName(ctx context.Context) error {
+	var err error
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return nil
+}
+
+func (repo *UserRepositoryImpl) Create(ctx context.Context) error {
+	var err error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return errs.ErrDuplicate
+	}
+	return nil
+}
+`
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, []string{"FindByID", "FindByName", "Create"}, &DBPackage{Driver: pgxV5Driver})
+	c.Errors = &DomainErrors{
+		Name:       "errs",
+		ImportPath: "example.com/app/pkg/domain/errs",
+		Errors:     []DomainError{{Name: "ErrNotFound", Kind: "var"}, {Name: "ErrAlreadyExists", Kind: "var"}},
+		Mapping:    map[string]string{DBErrNoRows: "ErrNotFound", DBErrUniqueViolation: "ErrAlreadyExists"},
+	}
+	c.EntityArgs = map[string]bool{"FindByID": true}
+
+	var messages []string
+	for _, f := range checkDomainErrors(c) {
+		messages = append(messages, f.Method+": "+f.Message)
+	}
+	want := []string{
+		"FindByID: returns the driver error pgx.ErrNoRows; return errs.ErrNotFound instead",
+		"FindByID: handles no rows but does not return errs.ErrNotFound",
+		"Create: errs.ErrDuplicate does not exist in example.com/app/pkg/domain/errs",
+		"Create: handles unique violation but does not return errs.ErrAlreadyExists",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import "fmt"
+
+// SQLDriver は sqlc の sql_package ごとの違いをまとめたものです。
+// プロンプトのガイドラインと、生成コードの事後チェックの両方で使います。
+type SQLDriver struct {
+	Name       string // sqlc.yml の sql_package の値（database/sql, pgx/v4, pgx/v5）
+	ImportPath string // ErrNoRows や Tx を定義しているパッケージ
+	ErrNoRows  string // 例: sql.ErrNoRows
+	TxType     string // 例: *sql.Tx
+	// SQLStateError は SQLSTATE（23505 など）を取り出す方法のプロンプト向けの説明です。
+	SQLStateError string
+	// Guidance はエラー処理と型変換についてのプロンプト向けの説明です。
+	// %[1]s には生成パッケージ名が入ります。
+	Guidance string
+	// foreignAPIs は、このドライバーでは使ってはならない別ドライバーのパッケージと識別子です。
+	foreignAPIs map[string][]string
+}
+
+var databaseSQLDriver = &SQLDriver{
+	Name:       "database/sql",
+	ImportPath: "database/sql",
+	ErrNoRows:  "sql.ErrNoRows",
+	TxType:     "*sql.Tx",
+	SQLStateError: "errors.As with the error type of the database driver in go.mod " +
+		"(*pq.Error from github.com/lib/pq, or *pgconn.PgError from github.com/jackc/pgx/v5/pgconn with the pgx stdlib driver), then compare its Code",
+	Guidance: `## Error Handling
+query := %[1]s.New(tx) simply wraps *sql.Tx, so the error returned will be usual sql error such as sql.ErrNoRows.
+Check it with errors.Is(err, sql.ErrNoRows) instead of comparing with ==.
+
+## Type Conversion
+Nullable columns are generated as sql.NullString, sql.NullInt64, sql.NullTime, sql.NullBool and so on.
+- To read: if v.Valid { use v.String }
+- To write: sql.NullString{String: s, Valid: s != ""} (or Valid: ptr != nil for pointers)`,
+	foreignAPIs: map[string][]string{
+		"github.com/jackc/pgx/v4":        {"ErrNoRows", "Tx"},
+		"github.com/jackc/pgx/v5":        {"ErrNoRows", "Tx"},
+		"github.com/jackc/pgx/v5/pgtype": nil,
+	},
+}
+
+var pgxV5Driver = &SQLDriver{
+	Name:          "pgx/v5",
+	ImportPath:    "github.com/jackc/pgx/v5",
+	ErrNoRows:     "pgx.ErrNoRows",
+	TxType:        "pgx.Tx",
+	SQLStateError: "var pgErr *pgconn.PgError (github.com/jackc/pgx/v5/pgconn); errors.As(err, &pgErr), then compare pgErr.Code",
+	Guidance: `## Error Handling
+query := %[1]s.New(tx) wraps pgx.Tx (github.com/jackc/pgx/v5), not *sql.Tx. The errors returned are pgx errors.
+- A query annotated with :one returns pgx.ErrNoRows when no row matches. Check it with errors.Is(err, pgx.ErrNoRows). Never use sql.ErrNoRows.
+- Constraint violations are returned as *pgconn.PgError (github.com/jackc/pgx/v5/pgconn). Use errors.As and inspect its Code (e.g. "23505" for unique violation).
+
+## Type Conversion
+Nullable and PostgreSQL specific columns are generated as pgtype types (github.com/jackc/pgx/v5/pgtype).
+- Text: pgtype.Text{String: s, Valid: true}; read with v.String when v.Valid
+- Integers: pgtype.Int4{Int32: n, Valid: true}, pgtype.Int8{Int64: n, Valid: true}
+- Bool: pgtype.Bool{Bool: b, Valid: true}
+- Time: pgtype.Timestamptz{Time: t, Valid: true}, pgtype.Timestamp{Time: t, Valid: true}, pgtype.Date{Time: t, Valid: true}
+- UUID: pgtype.UUID{Bytes: u, Valid: true} where u is a [16]byte
+- Numeric: use v.Float64Value() to read and Scan(strconv.FormatFloat(f, 'f', -1, 64)) to write
+Set Valid to false to store NULL.`,
+	foreignAPIs: map[string][]string{
+		"database/sql":            {"ErrNoRows", "Tx", "NullString", "NullInt32", "NullInt64", "NullBool", "NullTime", "NullFloat64"},
+		"github.com/jackc/pgx/v4": nil,
+	},
+}
+
+var pgxV4Driver = &SQLDriver{
+	Name:          "pgx/v4",
+	ImportPath:    "github.com/jackc/pgx/v4",
+	ErrNoRows:     "pgx.ErrNoRows",
+	TxType:        "pgx.Tx",
+	SQLStateError: "var pgErr *pgconn.PgError (github.com/jackc/pgconn); errors.As(err, &pgErr), then compare pgErr.Code",
+	Guidance: `## Error Handling
+query := %[1]s.New(tx) wraps pgx.Tx (github.com/jackc/pgx/v4), not *sql.Tx. The errors returned are pgx errors.
+- A query annotated with :one returns pgx.ErrNoRows when no row matches. Check it with errors.Is(err, pgx.ErrNoRows). Never use sql.ErrNoRows.
+- Constraint violations are returned as *pgconn.PgError (github.com/jackc/pgconn). Use errors.As and inspect its Code (e.g. "23505" for unique violation).
+
+## Type Conversion
+Nullable columns are generated as database/sql Null types or pgtype types (github.com/jackc/pgtype) depending on the column.
+- pgtype values carry a Status field: pgtype.Text{String: s, Status: pgtype.Present}; use pgtype.Null to store NULL.`,
+	foreignAPIs: map[string][]string{
+		"database/sql":            {"ErrNoRows", "Tx"},
+		"github.com/jackc/pgx/v5": nil,
+	},
+}
+
+// driverFor は sqlc.yml の sql_package の値に対応するドライバーを返します。未指定は database/sql です。
+func driverFor(sqlPackage string) (*SQLDriver, error) {
+	switch sqlPackage {
+	case "", "database/sql":
+		return databaseSQLDriver, nil
+	case "pgx/v5":
+		return pgxV5Driver, nil
+	case "pgx/v4":
+		return pgxV4Driver, nil
+	}
+	return nil, fmt.Errorf("unsupported sql_package %q", sqlPackage)
+}
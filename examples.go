@@ -0,0 +1,285 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExamplesConfig は llm-sqlc.yml の examples セクションです。
+// 同じ infra パッケージ内の実装済みメソッドを、書き方の手本としてプロンプトに含めます。
+type ExamplesConfig struct {
+	Files    []string `yaml:"files"`    // 手本を探すファイルを固定する（省略時はパッケージ内のすべてのファイル）
+	Max      int      `yaml:"max"`      // 1メソッドあたりの手本の数（既定 2）
+	Disabled bool     `yaml:"disabled"` // 手本を使わない
+}
+
+func (c *ExamplesConfig) max() int {
+	if c.Max <= 0 {
+		return 2
+	}
+	return c.Max
+}
+
+// Exemplar は手本となる実装済みメソッドです。
+type Exemplar struct {
+	File   string   // プロジェクトルートからの相対パス
+	Method string   // メソッド名
+	Code   string   // doc コメントを含むメソッドのソース
+	shape  []string // 引数・戻り値の型の形
+	kinds  []string // 呼び出しているクエリの種類（:one, :many など）
+}
+
+// LoadExemplars は dir 内の Go ファイル（exclude と _test.go を除く）から、
+// 実装済みのメソッドを手本の候補として集めます。pinned が指定されていればそのファイルだけを対象にします。
+// queryKinds はクエリ名から種類を引くための関数で、手本がどんなクエリを呼んでいるかを調べるのに使います。
+func LoadExemplars(dir string, exclude string, pinned []string, queryKinds func(name string) string) ([]Exemplar, error) {
+	var files []string
+	if len(pinned) > 0 {
+		files = pinned
+	} else {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	var exemplars []Exemplar
+	for _, path := range files {
+		if filepath.Clean(path) == filepath.Clean(exclude) {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil || !fn.Name.IsExported() || isStubBody(fn.Body) {
+				continue
+			}
+			if !strings.HasSuffix(receiverTypeName(fn.Recv.List[0].Type), "Impl") {
+				continue
+			}
+			start := fn.Pos()
+			if fn.Doc != nil {
+				start = fn.Doc.Pos()
+			}
+			exemplars = append(exemplars, Exemplar{
+				File:   path,
+				Method: fn.Name.Name,
+				Code:   string(src[fset.Position(start).Offset:fset.Position(fn.End()).Offset]),
+				shape:  funcTypeShape(fn.Type),
+				kinds:  calledQueryKinds(fn.Body, queryKinds),
+			})
+		}
+	}
+	return exemplars, nil
+}
+
+// isStubBody は panic("not implemented") のような未実装の本体かを返します。
+func isStubBody(body *ast.BlockStmt) bool {
+	if len(body.List) != 1 {
+		return len(body.List) == 0
+	}
+	expr, ok := body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "panic"
+}
+
+// calledQueryKinds は本体から呼ばれているクエリの種類を集めます。
+func calledQueryKinds(body *ast.BlockStmt, queryKinds func(name string) string) []string {
+	if queryKinds == nil {
+		return nil
+	}
+	var kinds []string
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			if kind := queryKinds(sel.Sel.Name); kind != "" {
+				kinds = append(kinds, kind)
+			}
+		}
+		return true
+	})
+	return kinds
+}
+
+// funcTypeShape は関数の型から、引数と戻り値の型の形を取り出します。
+func funcTypeShape(ft *ast.FuncType) []string {
+	var params []MethodParam
+	var results []string
+	for _, p := range ft.Params.List {
+		typ := exprText(p.Type)
+		count := len(p.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			params = append(params, MethodParam{Type: typ})
+		}
+	}
+	if ft.Results != nil {
+		for _, r := range ft.Results.List {
+			results = append(results, exprText(r.Type))
+		}
+	}
+	return methodShape(params, results)
+}
+
+// exprText は型の式を短い文字列にします（比較用で、ソースの再現は目的としない）。
+func exprText(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + exprText(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprText(e.Elt)
+	case *ast.SelectorExpr:
+		return exprText(e.X) + "." + e.Sel.Name
+	case *ast.MapType:
+		return "map[" + exprText(e.Key) + "]" + exprText(e.Value)
+	}
+	return "?"
+}
+
+// methodShape は引数と戻り値の型を、具体的なエンティティ名に依存しない形に変換します。
+// 例: (ctx context.Context, id entity.UserID) (*entity.User, error) → in:context, in:id, out:*entity, out:error
+func methodShape(params []MethodParam, results []string) []string {
+	shapeOf := func(typ string) string {
+		prefix := ""
+		for {
+			switch {
+			case strings.HasPrefix(typ, "*"):
+				prefix += "*"
+				typ = typ[1:]
+				continue
+			case strings.HasPrefix(typ, "[]"):
+				prefix += "[]"
+				typ = typ[2:]
+				continue
+			}
+			break
+		}
+		switch {
+		case typ == "context.Context":
+			return prefix + "context"
+		case typ == "error":
+			return prefix + "error"
+		case strings.HasSuffix(typ, "ID"):
+			return prefix + "id"
+		case strings.Contains(typ, "."):
+			return prefix + typ[:strings.Index(typ, ".")]
+		}
+		return prefix + typ
+	}
+	var shape []string
+	for _, p := range params {
+		shape = append(shape, "in:"+shapeOf(p.Type))
+	}
+	for _, r := range results {
+		shape = append(shape, "out:"+shapeOf(r))
+	}
+	return shape
+}
+
+// methodVerb はメソッド名の先頭の動詞（FindByID → Find）を返します。
+func methodVerb(name string) string {
+	for i := 1; i < len(name); i++ {
+		if name[i] >= 'A' && name[i] <= 'Z' {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// commonShapes はほとんどのメソッドが持つ引数・戻り値の形です。どの手本とも一致するので、類似度には数えません。
+var commonShapes = []string{"in:context", "out:error"}
+
+// exemplarScore は target と手本の類似度です。動詞、引数・戻り値の形、呼び出すクエリの種類の一致を数えます。
+func exemplarScore(e *Exemplar, targetName string, targetShape []string, targetKinds []string) int {
+	score := 0
+	if methodVerb(e.Method) == methodVerb(targetName) {
+		score += 3
+	}
+	score += 2 * overlap(withoutCommonShapes(e.shape), withoutCommonShapes(targetShape))
+	score += 2 * overlap(e.kinds, targetKinds)
+	return score
+}
+
+// withoutCommonShapes は shape から commonShapes を除いたものです。
+func withoutCommonShapes(shape []string) []string {
+	var rest []string
+	for _, s := range shape {
+		if !containsString(commonShapes, s) {
+			rest = append(rest, s)
+		}
+	}
+	return rest
+}
+
+// overlap は2つの多重集合の共通部分の要素数を返します。
+func overlap(a, b []string) int {
+	counts := make(map[string]int)
+	for _, v := range a {
+		counts[v]++
+	}
+	n := 0
+	for _, v := range b {
+		if counts[v] > 0 {
+			counts[v]--
+			n++
+		}
+	}
+	return n
+}
+
+// SelectExemplars は target に似た手本を最大 max 個、類似度の高い順に返します。
+func SelectExemplars(candidates []Exemplar, target InterfaceMethod, targetKinds []string, max int) []Exemplar {
+	targetShape := methodShape(target.Params, target.Results)
+	type scored struct {
+		exemplar Exemplar
+		score    int
+	}
+	var list []scored
+	for _, c := range candidates {
+		list = append(list, scored{exemplar: c, score: exemplarScore(&c, target.Name, targetShape, targetKinds)})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].score > list[j].score })
+
+	var result []Exemplar
+	for _, s := range list {
+		if len(result) >= max || s.score == 0 {
+			break
+		}
+		result = append(result, s.exemplar)
+	}
+	return result
+}
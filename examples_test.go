@@ -0,0 +1,162 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const postRepositorySrc = `package infra
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/app/pkg/domain/entity"
+	"example.com/app/pkg/infra/db"
+)
+
+type PostRepositoryImpl struct{}
+
+// FindByID は投稿を1件取得します。
+func (r PostRepositoryImpl) FindByID(ctx context.Context, id entity.PostID) (*entity.Post, error) {
+	row, err := db.New(nil).GetPost(ctx, int64(id))
+	if err != nil {
+		return nil, fmt.Errorf("PostRepository.FindByID: %w", err)
+	}
+	return entity.NewPost(entity.PostID(row.ID), row.Title), nil
+}
+
+func (r PostRepositoryImpl) ListByAuthor(ctx context.Context, authorID entity.UserID) ([]*entity.Post, error) {
+	rows, err := db.New(nil).ListPostsByAuthor(ctx, int64(authorID))
+	if err != nil {
+		return nil, fmt.Errorf("PostRepository.ListByAuthor: %w", err)
+	}
+	_ = rows
+	return nil, nil
+}
+
+func (r PostRepositoryImpl) Delete(ctx context.Context, id entity.PostID) error {
+	panic("not implemented")
+}
+
+func (r PostRepositoryImpl) helper() {}
+`
+
+func TestLoadExemplars(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"user.go": sampleProject["pkg/infra/user.go"],
+		"post.go": postRepositorySrc,
+	})
+	kinds := map[string]string{"GetPost": ":one", "ListPostsByAuthor": ":many"}
+
+	exemplars, err := LoadExemplars(dir, filepath.Join(dir, "user.go"), nil, func(name string) string { return kinds[name] })
+	if err != nil {
+		t.Fatalf("LoadExemplars error: %v", err)
+	}
+	var names []string
+	for _, e := range exemplars {
+		names = append(names, e.Method)
+	}
+	// 未実装（panic のみ）のメソッドと非公開のメソッドは手本にしない
+	if got := strings.Join(names, ","); got != "FindByID,ListByAuthor" {
+		t.Fatalf("exemplars = %s, want FindByID,ListByAuthor", got)
+	}
+	if !strings.HasPrefix(exemplars[0].Code, "// FindByID は投稿を1件取得します。") {
+		t.Errorf("expected the doc comment to be included:\n%s", exemplars[0].Code)
+	}
+	if got := strings.Join(exemplars[1].kinds, ","); got != ":many" {
+		t.Errorf("ListByAuthor kinds = %s, want :many", got)
+	}
+
+	// ファイルを固定すると、それ以外は見ない
+	pinned, err := LoadExemplars(dir, filepath.Join(dir, "user.go"), []string{filepath.Join(dir, "user.go")}, nil)
+	if err != nil {
+		t.Fatalf("LoadExemplars(pinned) error: %v", err)
+	}
+	if len(pinned) != 0 {
+		t.Errorf("expected no exemplars from the excluded file, got %d", len(pinned))
+	}
+}
+
+func TestSelectExemplars(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{"post.go": postRepositorySrc})
+	kinds := map[string]string{"GetPost": ":one", "ListPostsByAuthor": ":many"}
+	candidates, err := LoadExemplars(dir, "", nil, func(name string) string { return kinds[name] })
+	if err != nil {
+		t.Fatalf("LoadExemplars error: %v", err)
+	}
+
+	tests := []struct {
+		target InterfaceMethod
+		kinds  []string
+		max    int
+		want   string
+	}{
+		{
+			target: InterfaceMethod{
+				Name:    "FindByID",
+				Params:  []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "id", Type: "entity.UserID"}},
+				Results: []string{"*entity.User", "error"},
+			},
+			kinds: []string{":one"},
+			max:   1,
+			want:  "FindByID",
+		},
+		{
+			target: InterfaceMethod{
+				Name:    "ListByGroup",
+				Params:  []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "groupID", Type: "entity.GroupID"}},
+				Results: []string{"[]*entity.User", "error"},
+			},
+			kinds: []string{":many"},
+			max:   2,
+			want:  "ListByAuthor,FindByID",
+		},
+		{
+			// context と error だけが共通のメソッドは手本にしない
+			target: InterfaceMethod{
+				Name:    "Ping",
+				Params:  []MethodParam{{Name: "ctx", Type: "context.Context"}},
+				Results: []string{"error"},
+			},
+			max:  2,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, e := range SelectExemplars(candidates, tt.target, tt.kinds, tt.max) {
+			names = append(names, e.Method)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("SelectExemplars(%s) = %s, want %s", tt.target.Name, got, tt.want)
+		}
+	}
+}
+
+func TestDumpPromptExamples(t *testing.T) {
+	setupSampleProject(t, map[string]string{"pkg/infra/post.go": postRepositorySrc})
+
+	prompt, err := DumpPrompt("program", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(program) error: %v", err)
+	}
+	for _, want := range []string{"# Examples from this project", "## FindByID (pkg/infra/post.go)", "PostRepository.FindByID: %w"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected program prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+
+	// examples.disabled で手本を外せる
+	writeProjectFiles(t, ".", map[string]string{configPath: "examples:\n  disabled: true\n"})
+	prompt, err = DumpPrompt("program", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(program) error: %v", err)
+	}
+	if strings.Contains(prompt, "# Examples from this project") {
+		t.Errorf("expected no examples when disabled:\n%s", prompt)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+// FakeConfig は llm-sqlc.yml の fake セクションです。
+type FakeConfig struct {
+	// DatabaseEnv は適合テストを実装（XxxImpl）に対して走らせるときの接続先を読む環境変数です（既定 TEST_DATABASE_URL）。
+	DatabaseEnv string `yaml:"database_env"`
+}
+
+func (c *FakeConfig) databaseEnv() string {
+	return defaultString(c.DatabaseEnv, "TEST_DATABASE_URL")
+}
+
+// FileResponse はファイル1つ分の宣言と import 文を返す応答です。
+type FileResponse struct {
+	Code   string `json:"code" jsonschema_description:"All declarations of the file without the package clause and import statements"`
+	Import string `json:"import" jsonschema_description:"The import statements of the code"`
+}
+
+// FakeMethod はフェイクのプロンプトに載せるメソッドです。
+type FakeMethod struct {
+	Signature  string
+	EntityArgs bool // エンティティ型の引数を取る（レコードがなければエラーを返す）
+}
+
+// FakePromptData はフェイクと適合テストの生成プロンプト（prompts/fake.tmpl）に渡すデータです。
+type FakePromptData struct {
+	InterfaceName string
+	Interface     string // インターフェース定義のソース
+	FakeName      string // 例: UserRepositoryFake
+	ImplName      string // 例: UserRepositoryImpl
+	ImplStruct    string // 実装 struct の定義
+	Methods       []FakeMethod
+	Entities      []PromptEntity
+	Fake          string // 生成済みのフェイクのコード（適合テストのみ）
+	DatabaseEnv   string
+	DBPackage     *DBPackage
+	GoMod         string
+	ImplDir       string
+	Package       string
+}
+
+// hasEntityArgs は method が context 以外にエンティティ型の引数を取るかを返します。
+// ガイドラインでは、エンティティ型の引数ならレコードがないときにエラー、基本型なら nil か空のスライスを返します。
+func hasEntityArgs(method InterfaceMethod) bool {
+	for _, p := range method.Params {
+		typ := strings.TrimLeft(strings.TrimPrefix(p.Type, "..."), "*[]")
+		if strings.HasPrefix(typ, "entity.") {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeGenerator はフェイクと適合テストの生成に必要な情報をまとめます。
+type fakeGenerator struct {
+	cfg       *Config
+	prompts   *Prompts
+	infraFile string
+	ifaceName string
+	methods   []string
+	data      *FakePromptData
+}
+
+func newFakeGenerator(infraFile string) (*fakeGenerator, error) {
+	ifaceSrc, methods, implStructSrc, _, err := ExtractFirstInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+	ifaceName, signatures, err := ExtractInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	prompts, err := LoadPrompts(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	dbPkg, err := ResolveDBPackage(cfg, infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sqlc package: %w", err)
+	}
+
+	// エンティティ定義の抽出（存在しなければ警告）
+	entities, err := ExtractEntityDefinitions(filepath.Join("pkg", "domain", "entity"))
+	if err != nil {
+		slog.Warn("could not extract entity definitions", "error", err)
+	}
+	goModContent, err := parseGoModFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	relDir, err := filepath.Rel(".", filepath.Dir(infraFile))
+	if err != nil {
+		relDir = filepath.Dir(infraFile)
+	}
+
+	var fakeMethods []FakeMethod
+	for _, m := range signatures {
+		fakeMethods = append(fakeMethods, FakeMethod{Signature: m.Signature, EntityArgs: hasEntityArgs(m)})
+	}
+	return &fakeGenerator{
+		cfg:       cfg,
+		prompts:   prompts,
+		infraFile: infraFile,
+		ifaceName: ifaceName,
+		methods:   methods,
+		data: &FakePromptData{
+			InterfaceName: ifaceName,
+			Interface:     stripDirectives(ifaceSrc),
+			FakeName:      ifaceName + "Fake",
+			ImplName:      ifaceName + "Impl",
+			ImplStruct:    implStructSrc,
+			Methods:       fakeMethods,
+			Entities:      newPromptEntities(entities),
+			DatabaseEnv:   cfg.Fake.databaseEnv(),
+			DBPackage:     dbPkg,
+			GoMod:         goModContent,
+			ImplDir:       relDir,
+			Package:       filepath.Base(filepath.Dir(infraFile)),
+		},
+	}, nil
+}
+
+// prompt は top（fake または conformance）のプロンプトを返します。予算を超える場合は、インターフェースから辿れないエンティティを削ります。
+func (g *fakeGenerator) prompt(top string) (string, error) {
+	data := *g.data
+	trimmers := []promptTrimmer{
+		func() []string {
+			var removed []string
+			data.Entities, removed = trimEntities(data.Entities, data.Interface+"\n"+data.ImplStruct)
+			return removed
+		},
+	}
+	model := g.cfg.model()
+	return g.prompts.fitPrompt(top, methodLogger("fake", g.infraFile, ""), model, g.cfg.tokenBudget(model), &data, trimmers)
+}
+
+// fakeFilePath は infraFile のフェイクの出力先です（例: pkg/infra/user_fake.go）。
+func fakeFilePath(infraFile string) string {
+	return strings.TrimSuffix(infraFile, ".go") + "_fake.go"
+}
+
+// conformanceFilePath は infraFile の適合テストの出力先です（例: pkg/infra/user_conformance_test.go）。
+func conformanceFilePath(infraFile string) string {
+	return strings.TrimSuffix(infraFile, ".go") + "_conformance_test.go"
+}
+
+// fileSource は応答からファイル全体のソースを組み立て、import を整形します。
+func fileSource(path string, pkgName string, resp *FileResponse) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	if imp := strings.TrimSpace(resp.Import); imp != "" {
+		b.WriteString(imp)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(resp.Code)
+	b.WriteString("\n")
+	formatted, err := imports.Process(path, []byte(b.String()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process imports: %w", err)
+	}
+	return formatted, nil
+}
+
+// missingMethods は src で recv 型（またはそのポインタ）に定義されていない methods を返します。
+func missingMethods(src []byte, recv string, methods []string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	defined := make(map[string]bool)
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+			continue
+		}
+		typ := fn.Recv.List[0].Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if ident, ok := typ.(*ast.Ident); ok && ident.Name == recv {
+			defined[fn.Name.Name] = true
+		}
+	}
+	var missing []string
+	for _, m := range methods {
+		if !defined[m] {
+			missing = append(missing, m)
+		}
+	}
+	return missing, nil
+}
+
+// GenerateFake は infraFile の最初のインターフェースについて、マップで実装したインメモリのフェイク（XxxFake）と、
+// フェイクと実装（XxxImpl）の両方に対して走らせる適合テストを生成します。
+func GenerateFake(ctx context.Context, infraFile string) error {
+	g, err := newFakeGenerator(infraFile)
+	if err != nil {
+		return err
+	}
+	runUsage.Configure(&g.cfg.Usage)
+	modelCalls.Configure(&g.cfg.Requests)
+	logger := methodLogger("fake", infraFile, "")
+
+	// フェイク本体
+	prompt, err := g.prompt("fake")
+	if err != nil {
+		return err
+	}
+	fake, err := ChatCompletionHandler[FileResponse](withUsageScope(ctx, "fake", infraFile, g.data.FakeName), g.cfg.model(), prompt)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s: %w", g.data.FakeName, err)
+	}
+	fakePath := fakeFilePath(infraFile)
+	fakeSrc, err := fileSource(fakePath, g.data.Package, fake)
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", fakePath, err)
+	}
+	// 足りないメソッドがあっても書き込みは行い、警告として報告する
+	if missing, err := missingMethods(fakeSrc, g.data.FakeName, g.methods); err != nil {
+		logger.Warn("could not parse generated fake for checks", "error", err)
+	} else if len(missing) > 0 {
+		logger.Warn("generated fake does not implement every method", "fake", g.data.FakeName, "missing", strings.Join(missing, ", "))
+	}
+	if err := os.WriteFile(fakePath, fakeSrc, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", fakePath, err)
+	}
+	logger.Info("generated fake", "file", fakePath)
+
+	// 適合テスト（生成したフェイクのコンストラクタを使う）
+	g.data.Fake = fake.Code
+	prompt, err = g.prompt("conformance")
+	if err != nil {
+		return err
+	}
+	suiteName := "test" + g.ifaceName + "Conformance"
+	suite, err := ChatCompletionHandler[FileResponse](withUsageScope(ctx, "fake", infraFile, suiteName), g.cfg.model(), prompt)
+	if err != nil {
+		return fmt.Errorf("failed to generate the conformance tests: %w", err)
+	}
+	suitePath := conformanceFilePath(infraFile)
+	suiteSrc, err := fileSource(suitePath, g.data.Package, suite)
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", suitePath, err)
+	}
+	if err := os.WriteFile(suitePath, suiteSrc, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", suitePath, err)
+	}
+	logger.Info("generated conformance tests", "file", suitePath)
+	return nil
+}
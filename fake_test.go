@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasEntityArgs(t *testing.T) {
+	tests := []struct {
+		params []MethodParam
+		want   bool
+	}{
+		{params: []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "id", Type: "entity.UserID"}}, want: true},
+		{params: []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "user", Type: "*entity.User"}}, want: true},
+		{params: []MethodParam{{Name: "ids", Type: "[]entity.UserID"}}, want: true},
+		{params: []MethodParam{{Name: "ctx", Type: "context.Context"}, {Name: "id", Type: "string"}}, want: false},
+		{params: []MethodParam{{Name: "ctx", Type: "context.Context"}}, want: false},
+	}
+	for _, tt := range tests {
+		if got := hasEntityArgs(InterfaceMethod{Params: tt.params}); got != tt.want {
+			t.Errorf("hasEntityArgs(%v) = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	resp := &FileResponse{
+		Import: "import (\n\t\"sync\"\n\t\"strings\"\n)",
+		Code: `type UserRepositoryFake struct {
+	mu    sync.Mutex
+	users map[int64]string
+}
+
+func (f *UserRepositoryFake) FindByID(id int64) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.users[id]
+}`,
+	}
+	src, err := fileSource("pkg/infra/user_fake.go", "infra", resp)
+	if err != nil {
+		t.Fatalf("fileSource error: %v", err)
+	}
+	if !strings.HasPrefix(string(src), "package infra\n") || strings.Contains(string(src), `"strings"`) {
+		t.Errorf("expected a package clause and unused imports to be removed:\n%s", src)
+	}
+
+	missing, err := missingMethods(src, "UserRepositoryFake", []string{"FindByID", "Save"})
+	if err != nil {
+		t.Fatalf("missingMethods error: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != "Save" {
+		t.Errorf("expected Save to be missing, got %v", missing)
+	}
+}
+
+func TestDumpFakePrompt(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		"pkg/infra/user.go": `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/domain/entity"
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+	FindByName(ctx context.Context, name string) ([]*entity.User, error)
+}
+
+type UserRepositoryImpl struct{}
+
+var _ UserRepository = UserRepositoryImpl{}
+`,
+		"pkg/infra/user_fake.go": "package infra\n\nfunc NewUserRepositoryFake() *UserRepositoryFake { return nil }\n",
+	})
+
+	fakePrompt, err := DumpPrompt("fake", "pkg/infra/user.go", "")
+	if err != nil {
+		t.Fatalf("DumpPrompt(fake) error: %v", err)
+	}
+	for _, want := range []string{
+		"in-memory fake of the UserRepository interface",
+		"- FindByID(ctx context.Context, id entity.UserID) (*entity.User, error): return an error\n",
+		"- FindByName(ctx context.Context, name string) ([]*entity.User, error): return nil or an empty slice, not an error\n",
+		"func NewUserRepositoryFake() *UserRepositoryFake",
+		"## pkg/domain/entity/user.go",
+		"Its package name is infra.",
+	} {
+		if !strings.Contains(fakePrompt, want) {
+			t.Errorf("expected fake prompt to contain %q:\n%s", want, fakePrompt)
+		}
+	}
+
+	suitePrompt, err := DumpPrompt("conformance", "pkg/infra/user.go", "")
+	if err != nil {
+		t.Fatalf("DumpPrompt(conformance) error: %v", err)
+	}
+	for _, want := range []string{
+		"func testUserRepositoryConformance(t *testing.T, newRepo func(t *testing.T) UserRepository)",
+		"func TestUserRepositoryImpl(t *testing.T)",
+		"TEST_DATABASE_URL environment variable",
+		"func NewUserRepositoryFake() *UserRepositoryFake { return nil }",
+		"with the database/sql driver",
+	} {
+		if !strings.Contains(suitePrompt, want) {
+			t.Errorf("expected conformance prompt to contain %q:\n%s", want, suitePrompt)
+		}
+	}
+}
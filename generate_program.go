@@ -1,328 +1,647 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"sort"
-	"strings"
-
-	"golang.org/x/tools/imports"
-)
-
-type GenerationResponse struct {
-	Code       string `json:"code" jsonschema_description:"The code of the implemented function"`
-	Import     string `json:"import" jsonschema_description:"The import statements of the function"`
-	DocComment string `json:"doccomment" jsonschema_description:"The documentation comment before the function"`
-}
-
-// parseGoModFile reads the go.mod file from the project root,
-// and extracts the module declaration, Go version, and the direct dependencies
-// (ignoring dependencies marked as "// indirect").
-func parseGoModFile() (string, error) {
-	data, err := os.ReadFile("go.mod")
-	if err != nil {
-		return "", err
-	}
-	lines := strings.Split(string(data), "\n")
-	var moduleLine, goLine string
-	var deps []string
-	inRequireBlock := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "module ") {
-			moduleLine = trimmed
-		} else if strings.HasPrefix(trimmed, "go ") {
-			goLine = trimmed
-		} else if strings.HasPrefix(trimmed, "require (") {
-			inRequireBlock = true
-		} else if inRequireBlock {
-			if trimmed == ")" {
-				inRequireBlock = false
-			} else {
-				// 依存関係行で"// indirect"が含まれていなければ採用
-				if trimmed != "" && !strings.Contains(trimmed, "// indirect") {
-					deps = append(deps, trimmed)
-				}
-			}
-		} else if strings.HasPrefix(trimmed, "require ") {
-			// 単一行の require 文の場合
-			if !strings.Contains(trimmed, "// indirect") {
-				depLine := strings.TrimPrefix(trimmed, "require ")
-				depLine = strings.TrimSpace(depLine)
-				deps = append(deps, depLine)
-			}
-		}
-	}
-
-	var builder strings.Builder
-	if moduleLine != "" {
-		builder.WriteString(moduleLine)
-		builder.WriteString("\n\n")
-	}
-	if goLine != "" {
-		builder.WriteString(goLine)
-		builder.WriteString("\n\n")
-	}
-	if len(deps) > 0 {
-		builder.WriteString("require (\n")
-		for _, dep := range deps {
-			builder.WriteString("\t" + dep + "\n")
-		}
-		builder.WriteString(")\n")
-	}
-	return builder.String(), nil
-}
-
-func GenerateProgram(infraFile string) error {
-	// インターフェースとそのメソッド一覧、実装struct定義、実装チェック用の変数定義を抽出する
-	ifaceSrc, methods, implStructSrc, varCheckSrc, err := ExtractFirstInterface(infraFile)
-	if err != nil {
-		return fmt.Errorf("failed to extract interface: %w", err)
-	}
-
-	// DB関連のファイル読み込み
-	dbFilePath := filepath.Join("pkg", "infra", "db", "db.go")
-	dbContent, err := os.ReadFile(dbFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read db file: %w", err)
-	}
-	modelsFilePath := filepath.Join("pkg", "infra", "db", "models.go")
-	modelsContent, err := os.ReadFile(modelsFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read models.go file: %w", err)
-	}
-	base := filepath.Base(infraFile)
-	nameWithoutExt := strings.TrimSuffix(base, ".go")
-	sqlFileName := nameWithoutExt + ".sql.go"
-	sqlFilePath := filepath.Join("pkg", "infra", "db", sqlFileName)
-	sqlContent, err := os.ReadFile(sqlFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read sql file %s: %w", sqlFilePath, err)
-	}
-
-	// トランザクション処理コードの読み込み
-	txFilePath := filepath.Join("pkg", "infra", "txProvider.go")
-	txContent, err := os.ReadFile(txFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read transaction file: %w", err)
-	}
-
-	// エンティティ定義の抽出（存在しなければ警告）
-	entities, err := ExtractEntityDefinitions(filepath.Join("pkg", "domain", "entity"))
-	if err != nil {
-		log.Printf("warning: could not extract entity definitions: %v", err)
-	}
-	var entityDefBuilder strings.Builder
-	entityDefBuilder.WriteString("# Entity Definition\nThe function we are implementing references the following Entity. Here are the type definitions and the definition of the New function for generating the Entity:\n")
-	for _, entity := range entities {
-		relPath, err := filepath.Rel(".", entity.FileName)
-		if err != nil {
-			relPath = entity.FileName
-		}
-		entityDefBuilder.WriteString(fmt.Sprintf("## %s\n", relPath))
-		entityDefBuilder.WriteString("```\n")
-		entityDefBuilder.WriteString(entity.Code)
-		entityDefBuilder.WriteString("\n```\n")
-	}
-	entityDefinitionsSection := entityDefBuilder.String()
-
-	// 実装ガイドライン
-	implGuidelines := `## Implementation Guidelines
-- Always create the Entity using the New function. Do not instantiate the struct directly.
-- For queries that retrieve a single record by ID, first check the cache, and if it is not found, then issue a DB query.
-- The cache key should be in the format "EntityType:EntityID".
-- If the method argument is an entity type (for example, id entity.ChannelID), then if the corresponding record does not exist in the DB, return an error.
-- If the method argument is a basic data type (for example, id string), then if the corresponding record does not exist in the DB, return nil or an empty slice rather than an error.
-
-## Error Handling
-query := db.New(tx) simply wraps *sql.Tx, so the error returned will be usual sql error such as sql.ErrNoRows
-
-## Cache
-The infrastructure implementation uses a cache to speed up access by avoiding direct DB queries.
-The cache is defined in pkg/infra/cache.go as follows:
-
-package infra
-
-import "time"
-
-type Cache interface {
-	Set(k string, x interface{}, d time.Duration)
-	Get(k string) (interface{}, bool)
-	Delete(k string)
-}
-
-## Implementation Pattern
-query := db.New(tx)
-// Use cache if necessary. In some cases, caching may not be used.
-cacheKey := fmt.Sprintf("EntityType:%d", id)
-if cachedEntity, found := repo.Cache.Get(cacheKey); found {
-    // If the cache contains the entity, return it.
-}
-
-// Call the DB query via its function
-// For example: query.GetSomething(ctx)
-
-// Convert the retrieved data to an Entity using the New function.
-
-// If needed, store the entity in the cache. Set the cache duration appropriately.
-repo.Cache.Set(cacheKey, entity, 10*time.Minute)`
-
-	// プロジェクトルートの go.mod から直接依存関係のみ抽出
-	goModContent, err := parseGoModFile()
-	if err != nil {
-		return fmt.Errorf("failed to read go.mod: %w", err)
-	}
-
-	// 各メソッドの実装生成結果を格納するスライス
-	var generatedMethods []*GenerationResponse
-	// 各メソッドのimport文をまとめるためのスライス
-	var allMethodImports []string
-
-	// infraFileのディレクトリから、ルートからの相対パスを取得（例: pkg/infra/subdir）
-	relDir, err := filepath.Rel(".", filepath.Dir(infraFile))
-	if err != nil {
-		relDir = filepath.Dir(infraFile)
-	}
-
-	// 各メソッドごとに生成プロンプトを作成し、実装コードを取得する
-	for _, methodName := range methods {
-		var promptBuilder strings.Builder
-		promptBuilder.WriteString("# Instruction\n")
-		promptBuilder.WriteString("Please implement the function as specified with golang.\n\n")
-		promptBuilder.WriteString("# Function to Implement\n")
-		promptBuilder.WriteString(fmt.Sprintf("Implement the %s function of the interface defined below.\n\n", methodName))
-		promptBuilder.WriteString("Interface definition:\n")
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(ifaceSrc)
-		promptBuilder.WriteString("\n```\n\n")
-		promptBuilder.WriteString("Implementation struct definition:\n")
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(implStructSrc)
-		promptBuilder.WriteString("\n\n")
-		promptBuilder.WriteString(varCheckSrc)
-		promptBuilder.WriteString("\n```\n")
-		promptBuilder.WriteString("# DB\n")
-		promptBuilder.WriteString("You will communicate with the database using the code provided below.\n")
-		promptBuilder.WriteString("## pkg/infra/db/db.go\n")
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(string(dbContent))
-		promptBuilder.WriteString("\n```\n")
-		promptBuilder.WriteString("## pkg/infra/db/models.go\n")
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(string(modelsContent))
-		promptBuilder.WriteString("\n```\n")
-		promptBuilder.WriteString(fmt.Sprintf("## pkg/infra/db/%s\n", sqlFileName))
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(string(sqlContent))
-		promptBuilder.WriteString("\n```\n")
-		promptBuilder.WriteString(entityDefinitionsSection)
-		promptBuilder.WriteString("\n")
-		promptBuilder.WriteString("# Transactions\n")
-		promptBuilder.WriteString(string(txContent))
-		promptBuilder.WriteString("\n\n")
-		promptBuilder.WriteString(implGuidelines)
-		promptBuilder.WriteString("\n\n")
-		promptBuilder.WriteString("# Output Schema\n")
-		promptBuilder.WriteString("Define the JSON schema for the output with the following properties:\n")
-		promptBuilder.WriteString("- code (string): The code of the implemented function. It starts from func keyword. Don't write any import statement. Only the code of a function.\n")
-		promptBuilder.WriteString("- import (string): The import statements of the function. It starts from `import (` and ends with `)`\n")
-		promptBuilder.WriteString("- doccomment (string): The documentation comment before the function.\n")
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(goModContent)
-		promptBuilder.WriteString("```\n")
-		promptBuilder.WriteString(fmt.Sprintf("Your implementation is in root/%s package.\n", relDir))
-		promptBuilder.WriteString("# Directory Structure\n")
-		promptBuilder.WriteString("entity is in root/pkg/domain/entity package.\n")
-		promptBuilder.WriteString("db is in root/pkg/infra/db package.\n")
-		promptBuilder.WriteString("Your implementation file is provided as an argument and may reside in a subdirectory of pkg/infra.\n")
-
-		promptText := promptBuilder.String()
-
-		response, err := ChatCompletionHandler[GenerationResponse](context.Background(), "gpt-4.1-mini", promptText)
-		if err != nil {
-			return fmt.Errorf("ChatCompletionHandler error for method %s: %w", methodName, err)
-		}
-
-		// 生成結果を保存
-		generatedMethods = append(generatedMethods, response)
-
-		// 各メソッドのインポート文を収集する
-		impBlock := strings.TrimSpace(response.Import)
-		impBlock = strings.TrimPrefix(impBlock, "import (")
-		impBlock = strings.TrimSuffix(impBlock, ")")
-		lines := strings.Split(impBlock, "\n")
-		for _, line := range lines {
-			trimmedLine := strings.TrimSpace(line)
-			if trimmedLine != "" {
-				allMethodImports = append(allMethodImports, trimmedLine)
-			}
-		}
-	}
-
-	// 重複除去とアルファベット順のソート（標準ライブラリ sort を利用）
-	importMap := make(map[string]struct{})
-	for _, imp := range allMethodImports {
-		importMap[imp] = struct{}{}
-	}
-	var importList []string
-	for imp := range importMap {
-		importList = append(importList, imp)
-	}
-	sort.Strings(importList)
-
-	var finalImportBuilder strings.Builder
-	finalImportBuilder.WriteString("import (\n")
-	for _, imp := range importList {
-		finalImportBuilder.WriteString("\t" + imp + "\n")
-	}
-	finalImportBuilder.WriteString(")\n")
-	finalImportBlock := finalImportBuilder.String()
-
-	// 最終的なコード生成：
-	// ・package文（ファイルの直上のディレクトリ名をパッケージ名とする）
-	// ・整形済みimportブロック
-	// ・インターフェース定義
-	// ・実装するstructの定義
-	// ・varによる実装チェック
-	// ・生成された各関数（doccommentとコード）を順に並べる
-	var finalCodeBuilder strings.Builder
-	pkgName := filepath.Base(filepath.Dir(infraFile))
-	finalCodeBuilder.WriteString(fmt.Sprintf("package %s\n\n", pkgName))
-	finalCodeBuilder.WriteString(finalImportBlock)
-	finalCodeBuilder.WriteString("\n")
-	finalCodeBuilder.WriteString(ifaceSrc)
-	finalCodeBuilder.WriteString("\n\n")
-	finalCodeBuilder.WriteString(implStructSrc)
-	finalCodeBuilder.WriteString("\n\n")
-	finalCodeBuilder.WriteString(varCheckSrc)
-	finalCodeBuilder.WriteString("\n\n")
-	for _, method := range generatedMethods {
-		if strings.TrimSpace(method.DocComment) != "" {
-			finalCodeBuilder.WriteString(method.DocComment)
-			finalCodeBuilder.WriteString("\n")
-		}
-		finalCodeBuilder.WriteString(method.Code)
-		finalCodeBuilder.WriteString("\n\n")
-	}
-
-	// 生成されたコードを一旦バイトスライスに変換
-	finalCode := []byte(finalCodeBuilder.String())
-
-	// VSCode保存時と同様の自動import整形処理をgolang.org/x/tools/importsで実行
-	formattedCode, err := imports.Process(infraFile, finalCode, nil)
-	if err != nil {
-		return fmt.Errorf("failed to process imports: %w", err)
-	}
-
-	// infraFileの内容を上書きする
-	if err := os.WriteFile(infraFile, formattedCode, 0644); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", infraFile, err)
-	}
-
-	log.Printf("Successfully updated %s", infraFile)
-	return nil
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+type GenerationResponse struct {
+	Code       string `json:"code" jsonschema_description:"The code of the implemented function"`
+	Import     string `json:"import" jsonschema_description:"The import statements of the function"`
+	DocComment string `json:"doccomment" jsonschema_description:"The documentation comment before the function"`
+}
+
+// parseGoModFile reads the go.mod file from the project root,
+// and extracts the module declaration, Go version, and the direct dependencies
+// (ignoring dependencies marked as "// indirect").
+func parseGoModFile() (string, error) {
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	var moduleLine, goLine string
+	var deps []string
+	inRequireBlock := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "module ") {
+			moduleLine = trimmed
+		} else if strings.HasPrefix(trimmed, "go ") {
+			goLine = trimmed
+		} else if strings.HasPrefix(trimmed, "require (") {
+			inRequireBlock = true
+		} else if inRequireBlock {
+			if trimmed == ")" {
+				inRequireBlock = false
+			} else {
+				// 依存関係行で"// indirect"が含まれていなければ採用
+				if trimmed != "" && !strings.Contains(trimmed, "// indirect") {
+					deps = append(deps, trimmed)
+				}
+			}
+		} else if strings.HasPrefix(trimmed, "require ") {
+			// 単一行の require 文の場合
+			if !strings.Contains(trimmed, "// indirect") {
+				depLine := strings.TrimPrefix(trimmed, "require ")
+				depLine = strings.TrimSpace(depLine)
+				deps = append(deps, depLine)
+			}
+		}
+	}
+
+	var builder strings.Builder
+	if moduleLine != "" {
+		builder.WriteString(moduleLine)
+		builder.WriteString("\n\n")
+	}
+	if goLine != "" {
+		builder.WriteString(goLine)
+		builder.WriteString("\n\n")
+	}
+	if len(deps) > 0 {
+		builder.WriteString("require (\n")
+		for _, dep := range deps {
+			builder.WriteString("\t" + dep + "\n")
+		}
+		builder.WriteString(")\n")
+	}
+	return builder.String(), nil
+}
+
+// ProgramPromptData はプログラム生成プロンプト（prompts/program.tmpl）に渡すデータです。
+type ProgramPromptData struct {
+	Method         string       // 実装するメソッド名
+	Interface      string       // インターフェース定義のソース
+	ImplStruct     string       // 実装 struct の定義
+	VarCheck       string       // var _ Xxx = XxxImpl{} の定義
+	DBFiles        []SourceFile // sqlc が生成したコード（メソッドに関係する部分）
+	Entities       []PromptEntity
+	Transactions   string     // トランザクションプロバイダーの使い方と、このメソッドに必要かどうか
+	Bulk           string     // まとめて書き込むメソッドで、:copyfrom や :batchexec のクエリの呼び出し方（対象でなければ空）
+	Pagination     string     // 一覧系メソッドで、絞り込み条件の渡し方とページングの結果の組み立て方（対象でなければ空）
+	Feedback       []string   // 前回の生成結果のチェックで見つかった、直すべき問題
+	Instructions   []string   // --interactive で再生成するときに利用者が加えた指示
+	Notes          string     // インターフェースのメソッドのドキュメントコメント（なければ空）
+	DriverGuidance string     // ドライバー固有のエラー処理・型変換の説明
+	Errors         string     // DB のエラーからドメインエラーへの変換の説明（設定がなければ空）
+	Conventions    string     // ctx からテナントを取り出してクエリに渡す方法などの規約（設定がなければ空）
+	Cache          string     // メソッドのキャッシュポリシーに応じた説明と実装パターン
+	Examples       []Exemplar // 同じパッケージにある実装済みメソッドのうち、似ているもの
+	GoMod          string     // go.mod の直接依存
+	ImplDir        string     // 実装ファイルのディレクトリ
+	DBPackage      *DBPackage
+}
+
+// programGenerator はプログラム生成に必要な情報をまとめ、メソッドごとのプロンプトを組み立てます。
+type programGenerator struct {
+	cfg           *Config
+	prompts       *Prompts
+	infraFile     string
+	ifaceName     string
+	ifaceSrc      string
+	methods       []string
+	signatures    map[string]InterfaceMethod
+	implStructSrc string
+	varCheckSrc   string
+	dbPkg         *DBPackage
+	queryFile     string // このインターフェースのクエリを sqlc が生成したファイル
+	fullDBFiles   []SourceFile
+	sqlcCode      *SQLCCode
+	manifest      *QueryManifest
+	txAPI         *TxAPI
+	txInfo        *TxCheckInfo
+	implFields    map[string]string // 実装 struct のフィールド名 → 型
+	domainErrors  *DomainErrors
+	entities      []PromptEntity
+	structs       map[string]*StructDecl // 絞り込み条件・ページ指定・結果の型を探す struct の宣言
+	cacheSrc      string
+	cacheInfo     *CacheCheckInfo
+	exemplars     []Exemplar
+	goModContent  string
+	relDir        string
+	// instructions は --interactive で再生成するときに加えた、メソッドごとの指示です。
+	instructions map[string][]string
+}
+
+func newProgramGenerator(infraFile string) (*programGenerator, error) {
+	// インターフェースとそのメソッド一覧、実装struct定義、実装チェック用の変数定義を抽出する
+	ifaceSrc, methods, implStructSrc, varCheckSrc, err := ExtractFirstInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+	ifaceName, ifaceMethods, err := ExtractInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+	signatures := make(map[string]InterfaceMethod)
+	for _, m := range ifaceMethods {
+		signatures[m.Name] = m
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	prompts, err := LoadPrompts(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+
+	// sqlc の生成先パッケージを sqlc.yml から求め、DB関連のファイルを読み込む
+	dbPkg, err := ResolveDBPackage(cfg, infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sqlc package: %w", err)
+	}
+	base := filepath.Base(infraFile)
+	nameWithoutExt := strings.TrimSuffix(base, ".go")
+	sqlFilePath := dbPkg.QueryFile(nameWithoutExt + ".sql")
+	var fullDBFiles []SourceFile
+	for _, path := range []string{dbPkg.DBFile, dbPkg.ModelsFile, dbPkg.QuerierFile, sqlFilePath, dbPkg.BatchFile, dbPkg.CopyFromFile} {
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) && (path == dbPkg.BatchFile || path == dbPkg.CopyFromFile) {
+			// :batchexec や :copyfrom のクエリがなければ生成されない
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sqlc generated file %s: %w", path, err)
+		}
+		fullDBFiles = append(fullDBFiles, SourceFile{Path: path, Content: string(content)})
+	}
+
+	// SQL生成時に記録したメソッドとクエリの対応から、メソッドごとに必要な宣言だけを切り出せるようにする
+	sqlcCode, err := LoadSQLCCode(dbPkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sqlc generated code: %w", err)
+	}
+	manifest, err := loadQueryManifest(infraFile)
+	if err != nil {
+		slog.Warn("could not read the method-to-query mapping, the whole sqlc code is used", "file", infraFile, "error", err)
+	}
+	// query= で既存のクエリを使うメソッドは、マニフェストの記録（ディレクティブを書く前の SQL 生成のものかもしれない）より優先する
+	for _, m := range ifaceMethods {
+		if len(m.Directives.Queries) == 0 {
+			continue
+		}
+		queries, err := reusedQueries(sqlcCode, m)
+		if err != nil {
+			return nil, err
+		}
+		if manifest == nil {
+			manifest = &QueryManifest{InfraFile: filepath.ToSlash(infraFile), QueryFile: filepath.ToSlash(queryFilePath(infraFile))}
+		}
+		if existing := manifest.Method(m.Name); existing != nil {
+			*existing = NewMethodManifest(m.Name, queries)
+		} else {
+			manifest.Methods = append(manifest.Methods, NewMethodManifest(m.Name, queries))
+		}
+	}
+
+	// トランザクションプロバイダーを読み込み、トランザクションの開始・取り出しに使う関数を調べる
+	txAPI, err := LoadTxAPI(cfg.Transactions.file())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction file: %w", err)
+	}
+	txInfo := &TxCheckInfo{API: txAPI, Reasons: make(map[string]string)}
+	for _, methodName := range methods {
+		if reason := txReason(manifest.Method(methodName)); reason != "" {
+			txInfo.Reasons[methodName] = reason
+		} else if looped := loopedInserts(signatures[methodName], manifest.Method(methodName), dbPkg); len(looped) > 0 {
+			txInfo.Reasons[methodName] = fmt.Sprintf("it inserts the items one by one with %s", strings.Join(looped, ", "))
+		} else if signatures[methodName].Directives.Tx {
+			txInfo.Reasons[methodName] = "it is marked with " + directivePrefix + "tx"
+		}
+	}
+
+	// エンティティ定義の抽出（存在しなければ警告）
+	entities, err := ExtractEntityDefinitions(filepath.Join("pkg", "domain", "entity"))
+	if err != nil {
+		slog.Warn("could not extract entity definitions", "error", err)
+	}
+	structs, err := LoadStructDecls(filepath.Join("pkg", "domain", "entity"), filepath.Dir(infraFile))
+	if err != nil {
+		slog.Warn("could not collect struct declarations", "error", err)
+	}
+
+	// ドメインエラーのパッケージから、DB のエラーを変換する先のエラーを集める
+	domainErrors, err := LoadDomainErrors(&cfg.Errors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load domain errors: %w", err)
+	}
+
+	// キャッシュのインターフェースを実際のファイルから読み取る（なければキャッシュを使わない）
+	cacheSrc, err := LoadCacheInterface(&cfg.Cache)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("cache interface file not found, generating without cache", "path", cfg.Cache.file())
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cache interface: %w", err)
+	}
+	cacheInfo := &CacheCheckInfo{
+		Config:    &cfg.Cache,
+		Available: cacheSrc != "",
+		Policies:  make(map[string]CachePolicy),
+		Write:     make(map[string]bool),
+	}
+	for _, methodName := range methods {
+		cacheInfo.Policies[methodName] = cfg.Cache.PolicyFor(ifaceName, methodName)
+		if signatures[methodName].Directives.NoCache {
+			cacheInfo.Policies[methodName] = CachePolicy{Strategy: CacheNone}
+		}
+		cacheInfo.Write[methodName] = isWriteMethod(manifest, methodName)
+	}
+
+	// 同じパッケージの実装済みメソッドを、書き方の手本の候補として集める
+	var exemplars []Exemplar
+	if !cfg.Examples.Disabled {
+		exemplars, err = LoadExemplars(filepath.Dir(infraFile), infraFile, cfg.Examples.Files, sqlcCode.QueryKind)
+		if err != nil {
+			slog.Warn("could not collect example implementations", "error", err)
+		}
+	}
+
+	// プロジェクトルートの go.mod から直接依存関係のみ抽出
+	goModContent, err := parseGoModFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	// infraFileのディレクトリから、ルートからの相対パスを取得（例: pkg/infra/subdir）
+	relDir, err := filepath.Rel(".", filepath.Dir(infraFile))
+	if err != nil {
+		relDir = filepath.Dir(infraFile)
+	}
+
+	return &programGenerator{
+		cfg:           cfg,
+		prompts:       prompts,
+		infraFile:     infraFile,
+		ifaceName:     ifaceName,
+		ifaceSrc:      ifaceSrc,
+		methods:       methods,
+		signatures:    signatures,
+		implStructSrc: implStructSrc,
+		varCheckSrc:   varCheckSrc,
+		dbPkg:         dbPkg,
+		queryFile:     sqlFilePath,
+		fullDBFiles:   fullDBFiles,
+		sqlcCode:      sqlcCode,
+		manifest:      manifest,
+		txAPI:         txAPI,
+		txInfo:        txInfo,
+		implFields:    structFields(implStructSrc),
+		domainErrors:  domainErrors,
+		entities:      newPromptEntities(entities),
+		structs:       structs,
+		cacheSrc:      cacheSrc,
+		cacheInfo:     cacheInfo,
+		exemplars:     exemplars,
+		goModContent:  goModContent,
+		relDir:        relDir,
+		instructions:  make(map[string][]string),
+	}, nil
+}
+
+// promptData は methodName のプロンプトに渡すデータを組み立てます。
+// sqlc のコードをメソッドのクエリだけに絞り込めた場合は sliced が true になります。
+func (g *programGenerator) promptData(methodName string) (data *ProgramPromptData, sliced bool) {
+	dbFiles := g.fullDBFiles
+	var queryKinds []string
+	if m := g.manifest.Method(methodName); m != nil && len(m.Queries) > 0 {
+		for _, q := range m.Queries {
+			queryKinds = append(queryKinds, q.Kind)
+		}
+		if files, err := g.sqlcCode.Slice(m.QueryNames()); err != nil {
+			methodLogger("program", g.infraFile, methodName).Warn("could not slice the sqlc code, the whole code is used", "error", err)
+		} else {
+			dbFiles, sliced = files, true
+		}
+	}
+	return &ProgramPromptData{
+		Method:         methodName,
+		Interface:      stripDirectives(g.ifaceSrc),
+		ImplStruct:     g.implStructSrc,
+		VarCheck:       g.varCheckSrc,
+		DBFiles:        dbFiles,
+		Entities:       g.entities,
+		Transactions:   TxGuidance(g.txAPI, g.txInfo.Reasons[methodName], g.implFields, g.dbPkg.Name),
+		Bulk:           BulkProgramGuidance(g.signatures[methodName], g.manifest.Method(methodName), g.dbPkg),
+		Pagination:     PaginationProgramGuidance(DetectListShape(g.signatures[methodName], g.structs, &g.cfg.Pagination)),
+		DriverGuidance: fmt.Sprintf(g.dbPkg.Driver.Guidance, g.dbPkg.Name),
+		Errors:         DomainErrorGuidance(g.domainErrors, g.dbPkg.Driver),
+		Conventions:    ConventionsProgramGuidance(&g.cfg.Conventions),
+		Cache:          CacheGuidance(&g.cfg.Cache, g.cacheInfo.Policies[methodName], g.cacheInfo.Write[methodName], g.cacheSrc, g.dbPkg.Name),
+		Examples:       SelectExemplars(g.exemplars, g.signatures[methodName], queryKinds, g.cfg.Examples.max()),
+		GoMod:          g.goModContent,
+		ImplDir:        g.relDir,
+		DBPackage:      g.dbPkg,
+	}, sliced
+}
+
+// prompt は methodName を実装するためのプロンプトを返します。feedback は直すべき問題として含めます。
+func (g *programGenerator) prompt(methodName string, feedback ...string) (string, error) {
+	data, sliced := g.promptData(methodName)
+	data.Feedback = feedback
+	data.Instructions = g.instructions[methodName]
+	data.Notes = g.signatures[methodName].Doc
+	// 予算を超える場合は、インターフェースから辿れないエンティティ、このファイルのクエリと関係のないモデルの順に削る
+	trimmers := []promptTrimmer{
+		func() []string {
+			var removed []string
+			data.Entities, removed = trimEntities(data.Entities, g.ifaceSrc+"\n"+g.implStructSrc)
+			return removed
+		},
+		func() []string {
+			if sliced {
+				// すでにメソッドのクエリだけに絞り込まれている
+				return nil
+			}
+			queries := g.sqlcCode.QueriesIn(g.queryFile)
+			omitted, err := g.sqlcCode.OmittedModels(queries)
+			if err != nil || len(omitted) == 0 {
+				return nil
+			}
+			sliced, err := g.sqlcCode.Slice(queries)
+			if err != nil {
+				return nil
+			}
+			data.DBFiles = sliced
+			var removed []string
+			for _, name := range omitted {
+				removed = append(removed, "model "+name)
+			}
+			return removed
+		},
+	}
+	model := g.cfg.modelFor(g.signatures[methodName])
+	return g.prompts.fitPrompt("program", methodLogger("program", g.infraFile, methodName), model, g.cfg.tokenBudget(model), data, trimmers)
+}
+
+// generate は methodName の実装をモデルに生成させます。
+func (g *programGenerator) generate(ctx context.Context, methodName string, feedback []string) (*GenerationResponse, error) {
+	promptText, err := g.prompt(methodName, feedback...)
+	if err != nil {
+		return nil, err
+	}
+	response, err := ChatCompletionHandler[GenerationResponse](ctx, g.cfg.modelFor(g.signatures[methodName]), promptText)
+	if err != nil {
+		return nil, fmt.Errorf("ChatCompletionHandler error for method %s: %w", methodName, err)
+	}
+	return response, nil
+}
+
+func GenerateProgram(ctx context.Context, infraFile string) error {
+	g, err := newProgramGenerator(infraFile)
+	if err != nil {
+		return err
+	}
+	runUsage.Configure(&g.cfg.Usage)
+	modelCalls.Configure(&g.cfg.Requests)
+
+	// 各メソッドの実装生成結果を格納するスライス（メソッドの順に並べる）
+	generatedMethods := make([]*GenerationResponse, len(g.methods))
+	current := currentMethods(infraFile)
+
+	// 各メソッドごとに生成プロンプトを作成し、実装コードを取得する（並行に実行する）
+	err = forEachMethod(ctx, g.methods, func(ctx context.Context, i int, methodName string) error {
+		if g.signatures[methodName].Directives.Skip {
+			logger := methodLogger("program", infraFile, methodName).With("directive", directivePrefix+"skip")
+			if current[methodName] != nil {
+				logger.Info("keeping the current implementation")
+				generatedMethods[i] = current[methodName]
+				return nil
+			}
+			// 実装がないとインターフェースを満たせないので生成する
+			logger.Warn("skipped method has no current implementation, generating it")
+		}
+		response, err := g.generate(withUsageScope(ctx, "program", infraFile, methodName), methodName, nil)
+		if err != nil {
+			return err
+		}
+
+		// 生成結果を保存
+		generatedMethods[i] = response
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// --interactive なら、メソッドごとに現在の実装との差分を見せて採用するかを尋ねる
+	if reviewer != nil {
+		if err := g.review(ctx, generatedMethods); err != nil {
+			return err
+		}
+	}
+
+	formattedCode, err := g.assemble(generatedMethods)
+	if err != nil {
+		return err
+	}
+
+	// 生成コードの事後チェック。Checks.Fix が有効で error の指摘があれば、そのメソッドだけ指摘を添えて1度だけ生成し直す
+	// 確認済みのコードは生成し直さない
+	findings, checked := g.check(formattedCode)
+	if feedback := g.cfg.Checks.fixFeedback(findings); len(feedback) > 0 && reviewer == nil {
+		slog.Info("asking the model to fix the generated code", "file", infraFile, "methods", len(feedback))
+		err = forEachMethod(ctx, g.methods, func(ctx context.Context, i int, methodName string) error {
+			if len(feedback[methodName]) == 0 || (g.signatures[methodName].Directives.Skip && current[methodName] != nil) {
+				return nil
+			}
+			response, err := g.generate(withUsageScope(ctx, "program", infraFile, methodName), methodName, feedback[methodName])
+			if err != nil {
+				return err
+			}
+			generatedMethods[i] = response
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if formattedCode, err = g.assemble(generatedMethods); err != nil {
+			return err
+		}
+		findings, checked = g.check(formattedCode)
+	}
+
+	// 問題があっても書き込みは行い、重大度に応じて報告する
+	errorCount := g.cfg.Checks.report("program", infraFile, findings)
+	// N+1 はクエリから作り直す必要があるので、次の sql コマンドで使えるようマニフェストに残す
+	if checked && g.cfg.Checks.severity("n-plus-one") != SeverityOff && g.manifest != nil {
+		if changed := recordFeedback(g.manifest, nPlusOneFeedback(findings)); len(changed) > 0 {
+			if err := WriteManifest(manifestPath(infraFile), g.manifest); err != nil {
+				slog.Warn("could not record N+1 findings in the query manifest", "file", infraFile, "error", err)
+			} else {
+				slog.Info("recorded N+1 findings, run the sql command again to regenerate the queries with a batched query",
+					"file", infraFile, "methods", strings.Join(changed, ", "))
+			}
+		}
+	}
+
+	// infraFileの内容を上書きする（error の指摘があっても、直すための手がかりとして書き込む）
+	if err := os.WriteFile(infraFile, formattedCode, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", infraFile, err)
+	}
+
+	slog.Info("updated implementation", "file", infraFile)
+	if errorCount > 0 {
+		return fmt.Errorf("%d problems found in the generated code of %s", errorCount, infraFile)
+	}
+	return nil
+}
+
+// review は生成された各メソッドを reviewer で確認し、generated を採用・編集・再生成した結果で置き換えます。
+// 却下したメソッドは現在のファイルの実装に戻します（現在の実装がなければ生成結果のままにします）。
+func (g *programGenerator) review(ctx context.Context, generated []*GenerationResponse) error {
+	current := currentMethods(g.infraFile)
+	for i, methodName := range g.methods {
+		if g.signatures[methodName].Directives.Skip && current[methodName] != nil {
+			continue
+		}
+		latest := generated[i]
+		item := ReviewItem{
+			Method:    methodName,
+			Index:     i + 1,
+			Total:     len(g.methods),
+			Ext:       ".go",
+			Current:   current[methodName].source(),
+			Generated: latest.source(),
+		}
+		code, accepted, err := reviewer.Review(ctx, item, func(ctx context.Context, instruction string) (string, error) {
+			if instruction != "" {
+				g.instructions[methodName] = append(g.instructions[methodName], instruction)
+			}
+			response, err := g.generate(withUsageScope(ctx, "program", g.infraFile, methodName), methodName, nil)
+			if err != nil {
+				return "", err
+			}
+			latest = response
+			return response.source(), nil
+		})
+		if err != nil {
+			return err
+		}
+		switch {
+		case accepted:
+			// 編集されているかもしれないので、ドキュメントコメントも含めたコードとして扱い、書き足された import を拾う
+			generated[i] = editedResponse(code, latest)
+		case current[methodName] != nil:
+			generated[i] = current[methodName]
+		default:
+			methodLogger("program", g.infraFile, methodName).Warn("no current implementation to keep, using the generated one")
+			generated[i] = latest
+		}
+	}
+	return nil
+}
+
+// assemble は各メソッドの生成結果を、インターフェース・実装 struct の定義とともに1つのファイルにまとめて整形します。
+func (g *programGenerator) assemble(generated []*GenerationResponse) ([]byte, error) {
+	// 各メソッドのimport文をまとめるためのスライス
+	var allMethodImports []string
+	for _, response := range generated {
+		// 各メソッドのインポート文を収集する
+		impBlock := strings.TrimSpace(response.Import)
+		impBlock = strings.TrimPrefix(impBlock, "import (")
+		impBlock = strings.TrimSuffix(impBlock, ")")
+		lines := strings.Split(impBlock, "\n")
+		for _, line := range lines {
+			trimmedLine := strings.TrimSpace(line)
+			if trimmedLine != "" {
+				allMethodImports = append(allMethodImports, trimmedLine)
+			}
+		}
+	}
+
+	// 重複除去とアルファベット順のソート（標準ライブラリ sort を利用）
+	importMap := make(map[string]struct{})
+	for _, imp := range allMethodImports {
+		importMap[imp] = struct{}{}
+	}
+	var importList []string
+	for imp := range importMap {
+		importList = append(importList, imp)
+	}
+	sort.Strings(importList)
+
+	var finalImportBuilder strings.Builder
+	finalImportBuilder.WriteString("import (\n")
+	for _, imp := range importList {
+		finalImportBuilder.WriteString("\t" + imp + "\n")
+	}
+	finalImportBuilder.WriteString(")\n")
+	finalImportBlock := finalImportBuilder.String()
+
+	// 最終的なコード生成：
+	// ・package文（ファイルの直上のディレクトリ名をパッケージ名とする）
+	// ・整形済みimportブロック
+	// ・インターフェース定義
+	// ・実装するstructの定義
+	// ・varによる実装チェック
+	// ・生成された各関数（doccommentとコード）を順に並べる
+	var finalCodeBuilder strings.Builder
+	pkgName := filepath.Base(filepath.Dir(g.infraFile))
+	finalCodeBuilder.WriteString(fmt.Sprintf("package %s\n\n", pkgName))
+	finalCodeBuilder.WriteString(finalImportBlock)
+	finalCodeBuilder.WriteString("\n")
+	finalCodeBuilder.WriteString(g.ifaceSrc)
+	finalCodeBuilder.WriteString("\n\n")
+	finalCodeBuilder.WriteString(g.implStructSrc)
+	finalCodeBuilder.WriteString("\n\n")
+	finalCodeBuilder.WriteString(g.varCheckSrc)
+	finalCodeBuilder.WriteString("\n\n")
+	for _, method := range generated {
+		if strings.TrimSpace(method.DocComment) != "" {
+			finalCodeBuilder.WriteString(method.DocComment)
+			finalCodeBuilder.WriteString("\n")
+		}
+		finalCodeBuilder.WriteString(method.Code)
+		finalCodeBuilder.WriteString("\n\n")
+	}
+
+	// 生成されたコードを一旦バイトスライスに変換
+	finalCode := []byte(finalCodeBuilder.String())
+
+	// VSCode保存時と同様の自動import整形処理をgolang.org/x/tools/importsで実行
+	formattedCode, err := imports.Process(g.infraFile, finalCode, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process imports: %w", err)
+	}
+	return formattedCode, nil
+}
+
+// check は整形済みのコードに事後チェックを実行します。コードをパースできなければ警告を出し、checked は false になります。
+func (g *programGenerator) check(code []byte) (findings []Finding, checked bool) {
+	checkFset := token.NewFileSet()
+	checkFile, err := parser.ParseFile(checkFset, g.infraFile, code, 0)
+	if err != nil {
+		slog.Warn("could not parse generated code for checks", "file", g.infraFile, "error", err)
+		return nil, false
+	}
+	checkContext := NewCheckContext(checkFset, checkFile, g.methods, g.dbPkg)
+	checkContext.Cache = g.cacheInfo
+	checkContext.Tx = g.txInfo
+	checkContext.Errors = g.domainErrors
+	checkContext.QueryKinds = g.sqlcCode.QueryKind
+	checkContext.EntityArgs = make(map[string]bool)
+	checkContext.LoopedQueries = make(map[string][]string)
+	for name, signature := range g.signatures {
+		checkContext.EntityArgs[name] = hasEntityArgs(signature)
+		checkContext.LoopedQueries[name] = loopedInserts(signature, g.manifest.Method(name), g.dbPkg)
+	}
+	return RunCodeChecks(checkContext), true
+}
@@ -1,203 +1,436 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"strings"
-
-	"gopkg.in/yaml.v3"
-)
-
-type SQLResponse struct {
-	Queries []string `json:"queries"`
-}
-
-func GenerateSQL(infraFile string) error {
-	// インターフェースの抽出
-	ifaceSrc, methods, _, _, err := ExtractFirstInterface(infraFile)
-	if err != nil {
-		return fmt.Errorf("failed to extract interface: %w", err)
-	}
-	if len(methods) == 0 {
-		return fmt.Errorf("no methods found in the interface from file: %s", infraFile)
-	}
-
-	// DBスキーマの読み込み
-	schemaPath := filepath.Join("pkg", "infra", "sql", "schema", "schema.sql")
-	schemaContentBytes, err := os.ReadFile(schemaPath)
-	if err != nil {
-		log.Printf("warning: could not read schema file %s: %v", schemaPath, err)
-	}
-	schemaContent := string(schemaContentBytes)
-
-	// エンティティ定義の抽出（存在しなければ警告）
-	entities, err := ExtractEntityDefinitions(filepath.Join("pkg", "domain", "entity"))
-	if err != nil {
-		log.Printf("warning: could not extract entity definitions: %v", err)
-	}
-	var entityDefBuilder strings.Builder
-	entityDefBuilder.WriteString("# Entity Definition\nThe function we are implementing references the following Entity. Here are the type definitions and the definition of the New function for generating the Entity:\n")
-	for _, entity := range entities {
-		relPath, err := filepath.Rel(".", entity.FileName)
-		if err != nil {
-			relPath = entity.FileName
-		}
-		entityDefBuilder.WriteString(fmt.Sprintf("## %s\n", relPath))
-		entityDefBuilder.WriteString("```\n")
-		entityDefBuilder.WriteString(entity.Code)
-		entityDefBuilder.WriteString("\n```\n")
-	}
-	entityDefinitionsSection := entityDefBuilder.String()
-
-	var allQueries []string
-	// 各メソッドごとにSQL生成プロンプトを作成し、クエリを取得する
-	for _, method := range methods {
-		prompt := fmt.Sprintf(`# Instruction
-Please create SQL queries to implement the specified function for the given interface.
-We are using sqlc to allow the generated SQL queries to be handled from Golang. Therefore, please ensure that the format of the generated SQL complies with sqlc.
-
-# Function to be implemented
-%s
-
-We want to implement %s for this interface.
-
-# Important Notes
-You are generating SQL only. There is no need to write the implementation of the function in a programming language.
-Please ensure that the SQL queries are optimized for performance and do not cause issues like the N+1 problem.
-In the function implementation, processing will be achieved by calling the SQL queries you generate.
-It is preferable to have as few queries as possible, but you may use multiple queries if necessary.
-
-# sqlc
-The generated queries should include special comments as shown below. Make sure to correctly include the naming, the :one tag (or similar), and the placeholder settings.
-We are using PostgreSQL as the DB.
-sqlc tries to generate good names for positional parameters, but sometimes it lacks enough context.
-Please use @variable_name syntax for the placeholders if possible.
-
--- name: GetAuthor :one
-SELECT * FROM authors
-WHERE id = $1 LIMIT 1;
-
--- name: UpsertAuthorName :one
-UPDATE author
-SET
-  name = CASE WHEN @set_name::bool
-    THEN @name::text
-    ELSE name
-    END
-RETURNING *;
-
--- name: ListAuthorsByIDs :many
-SELECT * FROM authors
-WHERE id = ANY($1::int[]);
-
--- name: CreateAuthor :one
-INSERT INTO authors (
-  name, bio
-) VALUES (
-  $1, $2
-)
-RETURNING *;
-
--- name: UpdateAuthor :exec
-UPDATE authors
-  SET name = $2,
-      bio = $3
-WHERE id = $1;
-
--- name: DeleteAuthor :exec
-DELETE FROM authors
-WHERE id = $1;
-
-# DB Schema
-Below is the schema of the database. Please generate the SQL queries based on this schema:
-%s
-
-%s
-
-# Output Format
-Output an array named "queries" containing the SQL queries required for the function implementation.
-The data type is an array of strings. If necessary, you can output multiple queries.
-Each SQL query should start with a comment that is compliant with sqlc.
-`, ifaceSrc, method, schemaContent, entityDefinitionsSection)
-
-		resp, err := ChatCompletionHandler[SQLResponse](context.Background(), "gpt-4.1-mini", prompt)
-		if err != nil {
-			return fmt.Errorf("failed to generate SQL queries for method %s: %w", method, err)
-		}
-
-		allQueries = append(allQueries, resp.Queries...)
-	}
-
-	infraBase := filepath.Join("pkg", "infra")
-	infraFileDir := filepath.Dir(infraFile)
-	relSubPath, err := filepath.Rel(infraBase, infraFileDir)
-	if err != nil {
-		relSubPath = ""
-	}
-	outputDir := filepath.Join("pkg", "infra", "sql", "query", relSubPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	baseName := filepath.Base(infraFile)
-	fileNameWithoutExt := strings.TrimSuffix(baseName, filepath.Ext(baseName))
-	outputFile := filepath.Join(outputDir, fileNameWithoutExt+".sql")
-	outputContent := strings.Join(allQueries, "\n\n")
-	if err := os.WriteFile(outputFile, []byte(outputContent), 0644); err != nil {
-		return fmt.Errorf("failed to write SQL queries to file %s: %w", outputFile, err)
-	}
-
-	fmt.Printf("Successfully generated SQL queries and wrote them to %s\n", outputFile)
-
-	sqlcConfigPath := filepath.Join("pkg", "infra", "sqlc.yml")
-	configData, err := os.ReadFile(sqlcConfigPath)
-	if err != nil {
-		log.Printf("warning: could not read sqlc configuration file %s: %v", sqlcConfigPath, err)
-		return nil
-	}
-
-	var sqlcConfig map[string]interface{}
-	if err := yaml.Unmarshal(configData, &sqlcConfig); err != nil {
-		log.Printf("warning: failed to parse sqlc configuration file %s: %v", sqlcConfigPath, err)
-		return nil
-	}
-
-	relativeQueryPath, err := filepath.Rel(infraBase, outputFile)
-	if err != nil {
-		relativeQueryPath = outputFile
-	}
-
-	if sqlBlocks, ok := sqlcConfig["sql"].([]interface{}); ok {
-		for _, block := range sqlBlocks {
-			if blockMap, ok := block.(map[string]interface{}); ok {
-				if queries, ok := blockMap["queries"].([]interface{}); ok {
-					found := false
-					for _, q := range queries {
-						if qs, ok := q.(string); ok && qs == relativeQueryPath {
-							found = true
-							break
-						}
-					}
-					if !found {
-						queries = append(queries, relativeQueryPath)
-						blockMap["queries"] = queries
-					}
-				}
-			}
-		}
-	}
-
-	newConfigData, err := yaml.Marshal(sqlcConfig)
-	if err != nil {
-		log.Printf("warning: failed to marshal updated sqlc configuration: %v", err)
-	} else if err := os.WriteFile(sqlcConfigPath, newConfigData, 0644); err != nil {
-		log.Printf("warning: failed to update sqlc configuration file %s: %v", sqlcConfigPath, err)
-	} else {
-		fmt.Printf("Updated sqlc configuration at %s with new query file: %s\n", sqlcConfigPath, relativeQueryPath)
-	}
-
-	return nil
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SQLResponse struct {
+	Queries []string `json:"queries"`
+	Reuse   []string `json:"reuse" jsonschema_description:"The names of existing queries used instead of writing new ones"`
+}
+
+// SQLPromptData は SQL 生成プロンプト（prompts/sql.tmpl）に渡すデータです。
+type SQLPromptData struct {
+	Interface    string // インターフェース定義のソース
+	Method       string // 実装するメソッド名
+	Schema       string // DB スキーマ
+	Entities     []PromptEntity
+	Bulk         string   // スライスを受け取る書き込みメソッドで、まとめて実行するクエリの書き方（対象でなければ空）
+	Pagination   string   // 一覧系メソッドで、任意の絞り込み条件とページングの書き方（対象でなければ空）
+	Feedback     []string // 前回のクエリから生成したプログラムで見つかった問題（N+1 など）
+	Conventions  string   // 論理削除・テナント・監査用の列の規約（設定がなければ空）
+	Instructions []string // --interactive で再生成するときに利用者が加えた指示
+	Notes        string   // インターフェースのメソッドのドキュメントコメント（なければ空）
+	Reuse        string   // 再利用できる既存のクエリ（候補がなければ空）
+	Engine       string   // sqlc.yml の engine（postgresql, mysql, sqlite。分からなければ空で、PostgreSQL として説明する）
+}
+
+// sqlGenerator は SQL 生成に必要な情報をまとめ、メソッドごとのプロンプトを組み立てます。
+type sqlGenerator struct {
+	cfg        *Config
+	prompts    *Prompts
+	infraFile  string
+	ifaceName  string
+	ifaceSrc   string
+	methods    []string
+	signatures map[string]InterfaceMethod
+	schema     *Schema
+	dbPkg      *DBPackage // 生成先パッケージ（分からなければ nil）
+	entities   []PromptEntity
+	structs    map[string]*StructDecl // 絞り込み条件・ページ指定・結果の型を探す struct の宣言
+	previous   *QueryManifest         // 前回の SQL 生成で書き出したマニフェスト（なければ nil）
+	sqlcCode   *SQLCCode              // query= で指定されたクエリを探す sqlc の生成コード（指定がなければ nil）
+	queries    *QueryIndex            // 同じ sqlc パッケージにある既存のクエリ（読めなければ nil）
+	queryFile  string                 // 生成したクエリを書き込むファイル（/ 区切り）
+	// instructions は --interactive で再生成するときに加えた、メソッドごとの指示です。
+	instructions map[string][]string
+}
+
+func newSQLGenerator(infraFile string) (*sqlGenerator, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	prompts, err := LoadPrompts(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+
+	// インターフェースの抽出
+	ifaceSrc, methods, _, _, err := ExtractFirstInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no methods found in the interface from file: %s", infraFile)
+	}
+	ifaceName, ifaceMethods, err := ExtractInterface(infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract interface: %w", err)
+	}
+	signatures := make(map[string]InterfaceMethod)
+	reuse := false
+	for _, m := range ifaceMethods {
+		signatures[m.Name] = m
+		reuse = reuse || len(m.Directives.Queries) > 0
+	}
+
+	// DBスキーマの読み込み（sqlc.yml の schema に指定されたファイル・マイグレーションを順に適用する）
+	schemaPaths, err := ResolveSchemaPaths(cfg, infraFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sqlc schema: %w", err)
+	}
+	schema, err := LoadSchema(schemaPaths)
+	if err != nil {
+		slog.Warn("could not read schema", "paths", strings.Join(schemaPaths, ", "), "error", err)
+		schema = &Schema{}
+	}
+	WarnUnusedConventions(&cfg.Conventions, schema)
+
+	// エンジンとドライバーによって、まとめて書き込むクエリの書き方が変わる
+	dbPkg, err := ResolveDBPackage(cfg, infraFile)
+	if err != nil {
+		slog.Warn("could not resolve sqlc package", "error", err)
+		dbPkg = nil
+	}
+
+	// query= で既存のクエリを使うメソッドがあれば、sqlc の生成コードからそのクエリを探す
+	var sqlcCode *SQLCCode
+	if reuse && dbPkg != nil {
+		if sqlcCode, err = LoadSQLCCode(dbPkg); err != nil {
+			return nil, fmt.Errorf("failed to parse sqlc generated code: %w", err)
+		}
+	}
+
+	// エンティティ定義の抽出（存在しなければ警告）
+	entities, err := ExtractEntityDefinitions(filepath.Join("pkg", "domain", "entity"))
+	if err != nil {
+		slog.Warn("could not extract entity definitions", "error", err)
+	}
+	structs, err := LoadStructDecls(filepath.Join("pkg", "domain", "entity"), filepath.Dir(infraFile))
+	if err != nil {
+		slog.Warn("could not collect struct declarations", "error", err)
+	}
+
+	// 同じ sqlc パッケージの既存のクエリを、再利用の候補と名前の重複のチェックに使う
+	var queries *QueryIndex
+	queryPaths, err := ResolveQueryPaths(cfg, infraFile)
+	if err == nil {
+		queries, err = LoadQueryIndex(queryPaths)
+	}
+	if err != nil {
+		slog.Warn("could not index existing queries", "error", err)
+		queries = nil
+	}
+
+	// 前回のマニフェストに、プログラムのチェックで見つかった問題が残っていればプロンプトに含める
+	previous, err := ReadManifest(manifestPath(infraFile))
+	if err != nil {
+		previous = nil
+	}
+
+	return &sqlGenerator{
+		cfg:          cfg,
+		prompts:      prompts,
+		infraFile:    infraFile,
+		ifaceName:    ifaceName,
+		ifaceSrc:     ifaceSrc,
+		methods:      methods,
+		signatures:   signatures,
+		schema:       schema,
+		dbPkg:        dbPkg,
+		entities:     newPromptEntities(entities),
+		structs:      structs,
+		previous:     previous,
+		sqlcCode:     sqlcCode,
+		queries:      queries,
+		queryFile:    filepath.ToSlash(queryFilePath(infraFile)),
+		instructions: make(map[string][]string),
+	}, nil
+}
+
+// prompt は method の SQL を生成するためのプロンプトを返します。
+// スキーマは、メソッドのシグネチャとインターフェース名から辿れるエンティティに対応するテーブルと、
+// それらと外部キーで直接つながるテーブルだけを載せます。feedback は直すべき問題として前回の指摘に加えます。
+func (g *sqlGenerator) prompt(method string, feedback ...string) (string, error) {
+	subject := g.signatures[method].Signature + "\n" + interfaceSubject(g.ifaceName)
+	related, _ := trimEntities(g.entities, subject)
+	typeNames := entityTypeNames(related)
+	schema, omitted := g.schema.Slice(typeNames, true)
+
+	data := &SQLPromptData{
+		Interface:  stripDirectives(g.ifaceSrc),
+		Method:     method,
+		Schema:     schema,
+		Entities:   g.entities,
+		Bulk:       BulkSQLGuidance(g.signatures[method], g.dbPkg),
+		Pagination: PaginationSQLGuidance(DetectListShape(g.signatures[method], g.structs, &g.cfg.Pagination), g.dbPkg),
+		// 規約はスライスしたスキーマではなく、スキーマ全体のテーブルについて説明する
+		Conventions: ConventionsSQLGuidance(&g.cfg.Conventions, g.schema),
+	}
+	if m := g.previous.Method(method); m != nil {
+		data.Feedback = m.Feedback
+	}
+	data.Feedback = append(data.Feedback, feedback...)
+	data.Instructions = g.instructions[method]
+	data.Notes = g.signatures[method].Doc
+	if g.dbPkg != nil {
+		data.Engine = g.dbPkg.Engine
+	}
+	// 再利用の候補は、スキーマと同じくメソッドから辿れるテーブルを参照するクエリに絞る
+	data.Reuse = ReuseSQLGuidance(g.queries.Candidates(g.schema.RelatedTables(typeNames, true), g.queryFile))
+	// 予算を超える場合は、インターフェースから辿れないエンティティ、外部キーでつながるだけのテーブル、再利用の候補の順に削る
+	trimmers := []promptTrimmer{
+		func() []string {
+			var removed []string
+			data.Entities, removed = trimEntities(data.Entities, g.ifaceSrc)
+			return removed
+		},
+		func() []string {
+			sliced, removed := g.schema.Slice(typeNames, false)
+			var newlyRemoved []string
+			for _, r := range removed {
+				if !containsString(omitted, r) {
+					newlyRemoved = append(newlyRemoved, r)
+				}
+			}
+			data.Schema = sliced
+			return newlyRemoved
+		},
+		func() []string {
+			if data.Reuse == "" {
+				return nil
+			}
+			data.Reuse = ""
+			return []string{"existing queries"}
+		},
+	}
+	model := g.cfg.modelFor(g.signatures[method])
+	return g.prompts.fitPrompt("sql", methodLogger("sql", g.infraFile, method), model, g.cfg.tokenBudget(model), data, trimmers)
+}
+
+// interfaceSubject は UserRepository のようなインターフェース名から、対象のエンティティ名（User）を取り出します。
+func interfaceSubject(ifaceName string) string {
+	for _, suffix := range []string{"Repository", "Repo", "Store", "Gateway", "DAO", "Dao", "Query", "Queries"} {
+		if strings.HasSuffix(ifaceName, suffix) && len(ifaceName) > len(suffix) {
+			return strings.TrimSuffix(ifaceName, suffix)
+		}
+	}
+	return ifaceName
+}
+
+// checkContext は生成したクエリのチェックに渡す情報を返します。
+func (g *sqlGenerator) checkContext() *SQLCheckContext {
+	c := &SQLCheckContext{Schema: g.schema, Conventions: &g.cfg.Conventions}
+	if g.queries != nil {
+		c.Existing = g.queries.Names(g.queryFile)
+	}
+	if g.dbPkg != nil {
+		c.Engine = g.dbPkg.Engine
+	}
+	return c
+}
+
+// generate は method のクエリを生成します。Checks.Fix が有効で error の指摘があれば、指摘を添えて1度だけ生成し直します。
+func (g *sqlGenerator) generate(ctx context.Context, method string) (string, error) {
+	queries, err := g.complete(ctx, method, nil)
+	if err != nil {
+		return "", err
+	}
+	feedback := g.cfg.Checks.fixFeedback(LintQueries(queryFilePath(g.infraFile), 1, method, queries, g.checkContext()))[method]
+	if len(feedback) == 0 {
+		return queries, nil
+	}
+	methodLogger("sql", g.infraFile, method).Info("asking the model to fix the queries", "problems", len(feedback))
+	return g.complete(ctx, method, feedback)
+}
+
+// complete は method のプロンプトをモデルに送り、クエリをつなげて返します。
+// 既存のクエリを再利用する場合は、その名前を reuseMarker で先頭に残します。
+func (g *sqlGenerator) complete(ctx context.Context, method string, feedback []string) (string, error) {
+	prompt, err := g.prompt(method, feedback...)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ChatCompletionHandler[SQLResponse](ctx, g.cfg.modelFor(g.signatures[method]), prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate SQL queries for method %s: %w", method, err)
+	}
+	var parts []string
+	if len(resp.Reuse) > 0 {
+		parts = append(parts, reuseMarker+strings.Join(resp.Reuse, " "))
+	}
+	parts = append(parts, resp.Queries...)
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// methodManifest は method のクエリ（reuseMarker を含む）から、マニフェストのエントリを作ります。
+// 再利用する既存のクエリが見つからなければ、マニフェストには含めません（チェックで sql-reuse として報告します）。
+func (g *sqlGenerator) methodManifest(method string, queries string) MethodManifest {
+	var all []NamedQuery
+	for _, name := range reusedQueryNames(queries) {
+		if q, ok := g.queries.Lookup(name, g.queryFile); ok {
+			all = append(all, q.NamedQuery)
+		}
+	}
+	return NewMethodManifest(method, append(all, ParseQueries(queries)...))
+}
+
+func GenerateSQL(ctx context.Context, infraFile string) error {
+	g, err := newSQLGenerator(infraFile)
+	if err != nil {
+		return err
+	}
+	runUsage.Configure(&g.cfg.Usage)
+	modelCalls.Configure(&g.cfg.Requests)
+
+	// 同じパッケージで名前が重なっていると sqlc がコードを生成できないので、既存のものも知らせる
+	for _, d := range g.queries.Duplicates() {
+		slog.Warn("query name is defined more than once", "name", d.Name, "files", strings.Join(d.Files, ", "))
+	}
+
+	outputFile := queryFilePath(infraFile)
+	current := currentQueries(outputFile)
+
+	// メソッドごとのクエリ群。先頭に methodMarker を付け、どのメソッドのクエリかを残す（クエリを書かないメソッドは空）
+	allQueries := make([]string, len(g.methods))
+	// メソッドとクエリの対応（マニフェストとして書き出す）
+	methodManifests := make([]MethodManifest, len(g.methods))
+	// 各メソッドごとにSQL生成プロンプトを作成し、クエリを取得する（並行に実行し、結果はメソッドの順に並べる）
+	err = forEachMethod(ctx, g.methods, func(ctx context.Context, i int, method string) error {
+		signature := g.signatures[method]
+		if reason := signature.Directives.sqlSkipReason(); reason != "" {
+			logger := methodLogger("sql", infraFile, method).With("directive", reason)
+			if signature.Directives.Skip {
+				// 現在のクエリをそのまま残す
+				if current[method] == "" {
+					logger.Warn("skipped method has no queries in the current query file")
+					methodManifests[i] = NewMethodManifest(method, nil)
+					return nil
+				}
+				logger.Info("keeping the current queries")
+				allQueries[i] = methodMarker + method + "\n" + current[method]
+				methodManifests[i] = g.methodManifest(method, current[method])
+				return nil
+			}
+			queries, err := reusedQueries(g.sqlcCode, signature)
+			if err != nil {
+				return err
+			}
+			logger.Info("reusing existing queries")
+			methodManifests[i] = NewMethodManifest(method, queries)
+			return nil
+		}
+
+		ctx = withUsageScope(ctx, "sql", infraFile, method)
+		methodQueries, err := g.generate(ctx, method)
+		if err != nil {
+			return err
+		}
+
+		allQueries[i] = methodMarker + method + "\n" + methodQueries
+		methodManifests[i] = g.methodManifest(method, methodQueries)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// --interactive なら、メソッドごとに現在のクエリとの差分を見せて採用するかを尋ねる
+	if reviewer != nil {
+		for i, method := range g.methods {
+			if g.signatures[method].Directives.sqlSkipReason() != "" {
+				continue
+			}
+			_, generated, _ := strings.Cut(allQueries[i], "\n")
+			item := ReviewItem{
+				Method:    method,
+				Index:     i + 1,
+				Total:     len(g.methods),
+				Ext:       ".sql",
+				Current:   current[method],
+				Generated: generated,
+				Check: func(content string) []string {
+					var notes []string
+					for _, f := range LintQueries(outputFile, 1, method, content, g.checkContext()) {
+						notes = append(notes, fmt.Sprintf("[%s] %s", f.Rule, f.Message))
+					}
+					return notes
+				},
+			}
+			queries, _, err := reviewer.Review(ctx, item, func(ctx context.Context, instruction string) (string, error) {
+				if instruction != "" {
+					g.instructions[method] = append(g.instructions[method], instruction)
+				}
+				return g.complete(withUsageScope(ctx, "sql", infraFile, method), method, nil)
+			})
+			if err != nil {
+				return err
+			}
+			allQueries[i] = methodMarker + method + "\n" + queries
+			methodManifests[i] = g.methodManifest(method, queries)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var blocks []string
+	for _, block := range allQueries {
+		if block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	outputContent := strings.Join(blocks, "\n\n")
+
+	// 生成されたクエリのチェック（問題があっても書き込みは行い、重大度に応じて報告する）
+	var findings []Finding
+	checkContext := g.checkContext()
+	line := 1
+	for i, block := range allQueries {
+		if block == "" {
+			continue
+		}
+		// 各ブロックの1行目は methodMarker
+		_, queries, _ := strings.Cut(block, "\n")
+		findings = append(findings, LintQueries(outputFile, line+1, g.methods[i], queries, checkContext)...)
+		line += strings.Count(block, "\n") + 2
+		// 後のメソッドのクエリが、このメソッドのクエリと同じ名前を使っていないかも確かめる
+		if checkContext.Existing != nil {
+			for _, q := range ParseQueries(queries) {
+				if _, ok := checkContext.Existing[q.Name]; !ok {
+					checkContext.Existing[q.Name] = filepath.ToSlash(outputFile) + " (" + g.methods[i] + ")"
+				}
+			}
+		}
+	}
+	errorCount := g.cfg.Checks.report("sql", infraFile, findings)
+
+	if err := os.WriteFile(outputFile, []byte(outputContent), 0644); err != nil {
+		return fmt.Errorf("failed to write SQL queries to file %s: %w", outputFile, err)
+	}
+
+	slog.Info("generated SQL queries", "file", outputFile)
+
+	manifest := &QueryManifest{
+		InfraFile: filepath.ToSlash(infraFile),
+		QueryFile: filepath.ToSlash(outputFile),
+		Methods:   methodManifests,
+	}
+	if err := WriteManifest(manifestPath(infraFile), manifest); err != nil {
+		return fmt.Errorf("failed to write query manifest: %w", err)
+	}
+
+	registerQueryFile(g.cfg, infraFile, outputFile)
+
+	if errorCount > 0 {
+		return fmt.Errorf("%d problems found in the generated queries of %s", errorCount, infraFile)
+	}
+	return nil
+}
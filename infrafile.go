@@ -1,143 +1,250 @@
-package main
-
-import (
-	"bytes"
-	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/printer"
-	"go/token"
-)
-
-func ExtractFirstInterface(filePath string) (ifaceSrc string, methods []string, implStructSrc string, varCheckSrc string, err error) {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
-	if err != nil {
-		return "", nil, "", "", err
-	}
-
-	var interfaceName string
-	foundInterface := false
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-		for _, spec := range genDecl.Specs {
-			ts, ok := spec.(*ast.TypeSpec)
-			if !ok {
-				continue
-			}
-			it, ok := ts.Type.(*ast.InterfaceType)
-			if !ok {
-				continue
-			}
-			interfaceName = ts.Name.Name
-
-			var buf bytes.Buffer
-			if err := printer.Fprint(&buf, fset, genDecl); err != nil {
-				return "", nil, "", "", err
-			}
-			ifaceSrc = buf.String()
-
-			if it.Methods != nil {
-				for _, field := range it.Methods.List {
-					for _, name := range field.Names {
-						methods = append(methods, name.Name)
-					}
-				}
-			}
-			foundInterface = true
-			break
-		}
-		if foundInterface {
-			break
-		}
-	}
-	if !foundInterface {
-		return "", nil, "", "", fmt.Errorf("no interface found in file %q", filePath)
-	}
-
-	targetStructName := interfaceName + "Impl"
-	foundStruct := false
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-		for _, spec := range genDecl.Specs {
-			ts, ok := spec.(*ast.TypeSpec)
-			if !ok || ts.Name.Name != targetStructName {
-				continue
-			}
-			if _, ok := ts.Type.(*ast.StructType); !ok {
-				continue
-			}
-			var buf bytes.Buffer
-			if err := printer.Fprint(&buf, fset, genDecl); err != nil {
-				return "", nil, "", "", err
-			}
-			implStructSrc = buf.String()
-			foundStruct = true
-			break
-		}
-		if foundStruct {
-			break
-		}
-	}
-	if !foundStruct {
-		return "", nil, "", "", fmt.Errorf("struct %q not found", targetStructName)
-	}
-
-	foundVar := false
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.VAR {
-			continue
-		}
-		for _, spec := range genDecl.Specs {
-			vs, ok := spec.(*ast.ValueSpec)
-			if !ok {
-				continue
-			}
-			for _, name := range vs.Names {
-				if name.Name != "_" {
-					continue
-				}
-				idType, ok := vs.Type.(*ast.Ident)
-				if !ok || idType.Name != interfaceName {
-					continue
-				}
-				if len(vs.Values) == 0 {
-					continue
-				}
-				cl, ok := vs.Values[0].(*ast.CompositeLit)
-				if !ok {
-					continue
-				}
-				idComposite, ok := cl.Type.(*ast.Ident)
-				if !ok || idComposite.Name != targetStructName {
-					continue
-				}
-				var buf bytes.Buffer
-				if err := printer.Fprint(&buf, fset, genDecl); err != nil {
-					return "", nil, "", "", err
-				}
-				varCheckSrc = buf.String()
-				foundVar = true
-				break
-			}
-			if foundVar {
-				break
-			}
-		}
-		if foundVar {
-			break
-		}
-	}
-	if !foundVar {
-		return "", nil, "", "", fmt.Errorf("var _ %s = %s{} not found", interfaceName, targetStructName)
-	}
-
-	return ifaceSrc, methods, implStructSrc, varCheckSrc, nil
-}
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+func ExtractFirstInterface(filePath string) (ifaceSrc string, methods []string, implStructSrc string, varCheckSrc string, err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+
+	var interfaceName string
+	foundInterface := false
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			interfaceName = ts.Name.Name
+
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, genDecl); err != nil {
+				return "", nil, "", "", err
+			}
+			ifaceSrc = buf.String()
+
+			if it.Methods != nil {
+				for _, field := range it.Methods.List {
+					for _, name := range field.Names {
+						methods = append(methods, name.Name)
+					}
+				}
+			}
+			foundInterface = true
+			break
+		}
+		if foundInterface {
+			break
+		}
+	}
+	if !foundInterface {
+		return "", nil, "", "", fmt.Errorf("no interface found in file %q", filePath)
+	}
+
+	targetStructName := interfaceName + "Impl"
+	foundStruct := false
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != targetStructName {
+				continue
+			}
+			if _, ok := ts.Type.(*ast.StructType); !ok {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, genDecl); err != nil {
+				return "", nil, "", "", err
+			}
+			implStructSrc = buf.String()
+			foundStruct = true
+			break
+		}
+		if foundStruct {
+			break
+		}
+	}
+	if !foundStruct {
+		return "", nil, "", "", fmt.Errorf("struct %q not found", targetStructName)
+	}
+
+	foundVar := false
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, name := range vs.Names {
+				if name.Name != "_" {
+					continue
+				}
+				idType, ok := vs.Type.(*ast.Ident)
+				if !ok || idType.Name != interfaceName {
+					continue
+				}
+				if len(vs.Values) == 0 {
+					continue
+				}
+				cl, ok := vs.Values[0].(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				idComposite, ok := cl.Type.(*ast.Ident)
+				if !ok || idComposite.Name != targetStructName {
+					continue
+				}
+				var buf bytes.Buffer
+				if err := printer.Fprint(&buf, fset, genDecl); err != nil {
+					return "", nil, "", "", err
+				}
+				varCheckSrc = buf.String()
+				foundVar = true
+				break
+			}
+			if foundVar {
+				break
+			}
+		}
+		if foundVar {
+			break
+		}
+	}
+	if !foundVar {
+		return "", nil, "", "", fmt.Errorf("var _ %s = %s{} not found", interfaceName, targetStructName)
+	}
+
+	return ifaceSrc, methods, implStructSrc, varCheckSrc, nil
+}
+
+// InterfaceMethod はインターフェースのメソッド1つ分のシグネチャ情報です。
+type InterfaceMethod struct {
+	Name      string
+	Signature string        // 例: FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+	Params    []MethodParam // 引数（名前のない引数は Name が空）
+	Results   []string      // 戻り値の型
+	// Doc はメソッドのドキュメントコメントのうち、ディレクティブを除いた自由記述の部分です。
+	// 「idx_users_email のインデックスを使う」のような、メソッドごとの指示としてプロンプトに含めます。
+	Doc        string
+	Directives MethodDirectives // //llmsqlc: で始まるコメント
+}
+
+// MethodParam はメソッドの引数1つ分です。
+type MethodParam struct {
+	Name string
+	Type string
+}
+
+// ExtractInterface は filePath に含まれる最初のインターフェースの名前と、各メソッドのシグネチャ・ドキュメントコメントを返します。
+// 解釈できない //llmsqlc: ディレクティブがあればエラーを返します。
+func ExtractInterface(filePath string) (name string, methods []InterfaceMethod, err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exprString := func(expr ast.Expr) (string, error) {
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, expr); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			if it.Methods == nil {
+				return ts.Name.Name, nil, nil
+			}
+			for _, field := range it.Methods.List {
+				ft, ok := field.Type.(*ast.FuncType)
+				if !ok || len(field.Names) == 0 {
+					continue
+				}
+				signature, err := exprString(ft)
+				if err != nil {
+					return "", nil, err
+				}
+				directives, err := parseDirectives(field.Doc, field.Comment)
+				if err != nil {
+					return "", nil, fmt.Errorf("method %s: %w", field.Names[0].Name, err)
+				}
+				method := InterfaceMethod{
+					Name:       field.Names[0].Name,
+					Signature:  field.Names[0].Name + strings.TrimPrefix(signature, "func"),
+					Doc:        methodNotes(field.Doc),
+					Directives: directives,
+				}
+				for _, p := range ft.Params.List {
+					typ, err := exprString(p.Type)
+					if err != nil {
+						return "", nil, err
+					}
+					if len(p.Names) == 0 {
+						method.Params = append(method.Params, MethodParam{Type: typ})
+					}
+					for _, n := range p.Names {
+						method.Params = append(method.Params, MethodParam{Name: n.Name, Type: typ})
+					}
+				}
+				if ft.Results != nil {
+					for _, r := range ft.Results.List {
+						typ, err := exprString(r.Type)
+						if err != nil {
+							return "", nil, err
+						}
+						count := len(r.Names)
+						if count == 0 {
+							count = 1
+						}
+						for i := 0; i < count; i++ {
+							method.Results = append(method.Results, typ)
+						}
+					}
+				}
+				methods = append(methods, method)
+			}
+			return ts.Name.Name, methods, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no interface found in file %q", filePath)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogOptions はコマンドラインで指定するログの出力方法です。
+type LogOptions struct {
+	Verbose      bool   // デバッグログも出す
+	Quiet        bool   // 警告とエラーだけを出す
+	JSON         bool   // JSON 形式で出す
+	ArtifactsDir string // 指定するとプロンプトと応答をこのディレクトリ以下に実行ごとに保存する
+}
+
+// setupLogger は opts に従ってデフォルトの slog ロガーを設定します。
+func setupLogger(w io.Writer, opts LogOptions) *slog.Logger {
+	level := slog.LevelInfo
+	switch {
+	case opts.Verbose:
+		level = slog.LevelDebug
+	case opts.Quiet:
+		level = slog.LevelWarn
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	if opts.ArtifactsDir != "" {
+		runArtifacts.setDir(filepath.Join(opts.ArtifactsDir, runUsage.runID))
+	}
+	return logger
+}
+
+// methodLogger はコマンド・infra ファイル・メソッドを属性に持つロガーを返します。
+func methodLogger(command, infraFile, method string) *slog.Logger {
+	var attrs []any
+	if command != "" {
+		attrs = append(attrs, "command", command)
+	}
+	if infraFile != "" {
+		attrs = append(attrs, "file", filepath.ToSlash(infraFile))
+	}
+	if method != "" {
+		attrs = append(attrs, "method", method)
+	}
+	return slog.Default().With(attrs...)
+}
+
+// scopeLogger は withUsageScope で ctx に付けたコマンドとメソッドを属性に持つロガーを返します。
+func scopeLogger(ctx context.Context) *slog.Logger {
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	return methodLogger(scope.Command, scope.InfraFile, scope.Method)
+}
+
+// artifactStore はプロンプトと応答を保存するディレクトリです。dir が空なら何も保存しません。
+type artifactStore struct {
+	mu  sync.Mutex
+	dir string
+	seq int
+}
+
+// runArtifacts はこの実行の保存先です。
+var runArtifacts = &artifactStore{}
+
+func (s *artifactStore) setDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir = dir
+}
+
+// Next はモデル呼び出し1回分の連番を返します。同じメソッドを何度呼び出しても上書きしないよう、ファイル名に付けます。
+func (s *artifactStore) Next() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return s.seq
+}
+
+// Save は連番と ctx のコマンド・メソッドの名前を付けて content を保存します（例: 001-sql-user-FindByID.prompt.md）。
+func (s *artifactStore) Save(ctx context.Context, seq int, kind string, ext string, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		return
+	}
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	var parts []string
+	for _, p := range []string{scope.Command, strings.TrimSuffix(filepath.Base(scope.InfraFile), ".go"), scope.Method} {
+		if p != "" && p != "." {
+			parts = append(parts, p)
+		}
+	}
+	name := fmt.Sprintf("%03d-%s.%s.%s", seq, strings.Join(parts, "-"), kind, ext)
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		slog.Warn("could not create artifacts directory", "dir", s.dir, "error", err)
+		return
+	}
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		slog.Warn("could not write artifact", "path", path, "error", err)
+		return
+	}
+	scopeLogger(ctx).Debug("saved artifact", "path", path)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupLogger(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	setupLogger(&buf, LogOptions{Quiet: true})
+	slog.Info("hidden")
+	slog.Warn("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("quiet output = %q", buf.String())
+	}
+
+	buf.Reset()
+	setupLogger(&buf, LogOptions{Verbose: true, JSON: true})
+	methodLogger("sql", "pkg/infra/user.go", "FindByID").Debug("calling model")
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["command"] != "sql" || record["file"] != "pkg/infra/user.go" || record["method"] != "FindByID" || record["level"] != "DEBUG" {
+		t.Errorf("record = %v", record)
+	}
+}
+
+func TestArtifactStore(t *testing.T) {
+	ctx := withUsageScope(context.Background(), "sql", filepath.Join("pkg", "infra", "user.go"), "FindByID")
+
+	// 保存先がなければ何もしない
+	(&artifactStore{}).Save(ctx, 1, "prompt", "md", "prompt")
+
+	dir := filepath.Join(t.TempDir(), "run")
+	store := &artifactStore{dir: dir}
+	first, second := store.Next(), store.Next()
+	store.Save(ctx, first, "prompt", "md", "first prompt")
+	store.Save(ctx, second, "prompt", "md", "second prompt")
+	store.Save(ctx, first, "response", "json", `{"queries":[]}`)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := "001-sql-user-FindByID.prompt.md,001-sql-user-FindByID.response.json,002-sql-user-FindByID.prompt.md"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("artifacts = %s, want %s", got, want)
+	}
+}
@@ -1,32 +1,102 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"os"
-)
-
-func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: go run main.go <command> <path-to-infra-go-file>")
-		os.Exit(1)
-	}
-
-	command := os.Args[1]
-	infraFile := os.Args[2]
-
-	if command == "sql" {
-		if err := GenerateSQL(infraFile); err != nil {
-			log.Fatalf("failed to generate SQL: %v", err)
-
-		}
-	} else if command == "program" {
-		if err := GenerateProgram(infraFile); err != nil {
-			log.Fatalf("failed to generate program: %v", err)
-		}
-	} else {
-		fmt.Printf("Unknown command: %s\n", command)
-		fmt.Println("Available commands: sql, program, infra")
-		os.Exit(1)
-	}
-}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+)
+
+func usage() {
+	fmt.Println("Usage: go run main.go [flags] <command> <path-to-infra-go-file>")
+	fmt.Println("       go run main.go [flags] prompts dump <sql|program> <path-to-infra-go-file> <method>")
+	fmt.Println("       go run main.go [flags] prompts dump <fake|conformance> <path-to-infra-go-file>")
+	fmt.Println("Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var opts LogOptions
+	flag.BoolVar(&opts.Verbose, "verbose", false, "show debug logs")
+	flag.BoolVar(&opts.Quiet, "quiet", false, "show only warnings and errors")
+	flag.BoolVar(&opts.JSON, "json", false, "write logs as JSON")
+	flag.StringVar(&opts.ArtifactsDir, "artifacts", "", "save prompts and responses under `dir`/<run-id>")
+	interactive := flag.Bool("interactive", false, "review each generated method (accept, reject, edit or regenerate) before writing")
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+
+	if len(args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	logger := setupLogger(os.Stderr, opts)
+	if *interactive {
+		reviewer = NewReviewer(os.Stdin, os.Stdout)
+	}
+
+	// Ctrl+C で実行中のモデル呼び出しを取り消す
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	command := args[0]
+	infraFile := args[1]
+
+	if command == "sql" {
+		err := GenerateSQL(ctx, infraFile)
+		writeUsageSummary(logger, opts)
+		if err != nil {
+			fatal("failed to generate SQL", err)
+		}
+	} else if command == "program" {
+		err := GenerateProgram(ctx, infraFile)
+		writeUsageSummary(logger, opts)
+		if err != nil {
+			fatal("failed to generate program", err)
+		}
+	} else if command == "fake" {
+		err := GenerateFake(ctx, infraFile)
+		writeUsageSummary(logger, opts)
+		if err != nil {
+			fatal("failed to generate fake", err)
+		}
+	} else if command == "prompts" {
+		if len(args) < 4 || args[1] != "dump" || (len(args) < 5 && args[2] != "fake" && args[2] != "conformance") {
+			fmt.Println("Usage: go run main.go [flags] prompts dump <sql|program> <path-to-infra-go-file> <method>")
+			fmt.Println("       go run main.go [flags] prompts dump <fake|conformance> <path-to-infra-go-file>")
+			os.Exit(1)
+		}
+		method := ""
+		if len(args) >= 5 {
+			method = args[4]
+		}
+		prompt, err := DumpPrompt(args[2], args[3], method)
+		if err != nil {
+			fatal("failed to render prompt", err)
+		}
+		fmt.Print(prompt)
+	} else {
+		fmt.Printf("Unknown command: %s\n", command)
+		fmt.Println("Available commands: sql, program, fake, prompts")
+		os.Exit(1)
+	}
+}
+
+// writeUsageSummary は実行の最後に使用量の集計を出します。--quiet のときは出しません。
+func writeUsageSummary(logger *slog.Logger, opts LogOptions) {
+	switch {
+	case opts.Quiet:
+	case opts.JSON:
+		runUsage.LogSummary(logger)
+	default:
+		runUsage.WriteSummary(os.Stderr)
+	}
+}
+
+// fatal はエラーを記録して終了します。
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// QueryManifest は SQL 生成の結果として、インターフェースの各メソッドがどのクエリを使うかを記録したものです。
+// クエリファイルと同じディレクトリに <name>.llm-sqlc.json として書き出され、プログラム生成などで利用されます。
+type QueryManifest struct {
+	InfraFile string           `json:"infra_file"`
+	QueryFile string           `json:"query_file"`
+	Methods   []MethodManifest `json:"methods"`
+}
+
+// MethodManifest はインターフェースのメソッド1つ分の対応です。
+type MethodManifest struct {
+	Method  string               `json:"method"`
+	Queries []QueryManifestEntry `json:"queries"`
+	// Feedback は生成されたプログラムのチェックで見つかった、クエリを作り直すべき問題です（N+1 など）。
+	// 次に SQL を生成するとき、そのメソッドのプロンプトに含めます。
+	Feedback []string `json:"feedback,omitempty"`
+}
+
+// QueryManifestEntry はクエリ1つ分の情報です。
+type QueryManifestEntry struct {
+	Name   string   `json:"name"`
+	Kind   string   `json:"kind"`   // :one, :many, :exec など
+	Verb   string   `json:"verb"`   // SELECT, INSERT, UPDATE, DELETE
+	Params []string `json:"params"` // sqlc.arg(name)、@name、$1 などのプレースホルダ名（出現順）
+}
+
+// manifestPath は infraFile に対応するマニフェストのパスを返します。
+func manifestPath(infraFile string) string {
+	return strings.TrimSuffix(queryFilePath(infraFile), ".sql") + ".llm-sqlc.json"
+}
+
+// NewMethodManifest は method のために生成されたクエリからマニフェストのエントリを作ります。
+func NewMethodManifest(method string, queries []NamedQuery) MethodManifest {
+	m := MethodManifest{Method: method, Queries: []QueryManifestEntry{}}
+	for _, q := range queries {
+		m.Queries = append(m.Queries, QueryManifestEntry{
+			Name:   q.Name,
+			Kind:   q.Kind,
+			Verb:   sqlVerb(q.SQL),
+			Params: sqlParams(q.SQL),
+		})
+	}
+	return m
+}
+
+// WriteManifest はマニフェストを JSON として書き出します。
+func WriteManifest(path string, manifest *QueryManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadManifest はマニフェストを読み込みます。
+func ReadManifest(path string) (*QueryManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest QueryManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Method は method のエントリを返します。見つからなければ（マニフェスト自体が nil の場合も）nil を返します。
+func (m *QueryManifest) Method(method string) *MethodManifest {
+	if m == nil {
+		return nil
+	}
+	for i := range m.Methods {
+		if m.Methods[i].Method == method {
+			return &m.Methods[i]
+		}
+	}
+	return nil
+}
+
+// QueryNames はメソッドが使うクエリ名の一覧を返します。
+func (m *MethodManifest) QueryNames() []string {
+	var names []string
+	for _, q := range m.Queries {
+		names = append(names, q.Name)
+	}
+	return names
+}
+
+// loadQueryManifest は infraFile のマニフェストを読み込みます。
+// マニフェストがなければ、クエリファイル中の methodMarker からメソッドとクエリの対応を復元します。
+func loadQueryManifest(infraFile string) (*QueryManifest, error) {
+	if manifest, err := ReadManifest(manifestPath(infraFile)); err == nil {
+		return manifest, nil
+	}
+	queryFile := queryFilePath(infraFile)
+	queries, err := ParseQueryFile(queryFile)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &QueryManifest{InfraFile: filepath.ToSlash(infraFile), QueryFile: filepath.ToSlash(queryFile)}
+	grouped := make(map[string][]NamedQuery)
+	for _, q := range queries {
+		if q.Method == "" {
+			continue
+		}
+		if _, ok := grouped[q.Method]; !ok {
+			manifest.Methods = append(manifest.Methods, MethodManifest{Method: q.Method})
+		}
+		grouped[q.Method] = append(grouped[q.Method], q)
+	}
+	for i := range manifest.Methods {
+		manifest.Methods[i] = NewMethodManifest(manifest.Methods[i].Method, grouped[manifest.Methods[i].Method])
+	}
+	return manifest, nil
+}
+
+// IsWrite はメソッドのクエリに書き込み（INSERT, UPDATE, DELETE）が含まれるかを返します。
+func (m *MethodManifest) IsWrite() bool {
+	for _, q := range m.Queries {
+		switch q.Verb {
+		case "INSERT", "UPDATE", "DELETE":
+			return true
+		}
+	}
+	return false
+}
+
+// readMethodPrefixes は、クエリの情報がないときに読み取り専用とみなすメソッド名の接頭辞です。
+var readMethodPrefixes = []string{"Get", "Find", "List", "Count", "Exists", "Search", "Fetch", "Load", "Is", "Has"}
+
+// isWriteMethod は method が書き込みを行うかを返します。
+// マニフェストに記録があればクエリの種類から、なければメソッド名から判断します。
+func isWriteMethod(manifest *QueryManifest, method string) bool {
+	if m := manifest.Method(method); m != nil && len(m.Queries) > 0 {
+		return m.IsWrite()
+	}
+	for _, prefix := range readMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	sqlLineCommentPattern  = regexp.MustCompile(`--[^\n]*`)
+	sqlBlockCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	sqlStringPattern       = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlQuotedArgPattern    = regexp.MustCompile(`(?i)(sqlc\.(?:n?arg|slice))\(\s*'([A-Za-z_][A-Za-z0-9_]*)'\s*\)`)
+	sqlParamPattern        = regexp.MustCompile(`(?i)sqlc\.(?:n?arg|slice)\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)|@([A-Za-z_][A-Za-z0-9_]*)|(\$[0-9]+)`)
+	sqlWordPattern         = regexp.MustCompile(`[A-Za-z_]+`)
+)
+
+// stripSQLComments はコメントと文字列リテラルを取り除いた SQL を返します。
+// sqlc.arg('name') のように引用符で書かれた引数名は、取り除かれないよう sqlc.arg(name) に直します。
+func stripSQLComments(sql string) string {
+	sql = sqlBlockCommentPattern.ReplaceAllString(sql, " ")
+	sql = sqlQuotedArgPattern.ReplaceAllString(sql, "$1($2)")
+	sql = sqlLineCommentPattern.ReplaceAllString(sql, " ")
+	return sqlStringPattern.ReplaceAllString(sql, "''")
+}
+
+// sqlVerb はクエリの種類（SELECT, INSERT, UPDATE, DELETE）を返します。
+// WITH 句から始まる場合は、本体に書き込みがあればその種類を返します。
+func sqlVerb(sql string) string {
+	words := sqlWordPattern.FindAllString(stripSQLComments(sql), -1)
+	if len(words) == 0 {
+		return ""
+	}
+	first := strings.ToUpper(words[0])
+	if first != "WITH" {
+		return first
+	}
+	for _, w := range words {
+		switch upper := strings.ToUpper(w); upper {
+		case "INSERT", "UPDATE", "DELETE":
+			return upper
+		}
+	}
+	return "SELECT"
+}
+
+// sqlParams はクエリ中のプレースホルダ名を重複なく出現順に返します。
+func sqlParams(sql string) []string {
+	params := []string{}
+	seen := make(map[string]bool)
+	for _, m := range sqlParamPattern.FindAllStringSubmatch(stripSQLComments(sql), -1) {
+		var name string
+		for _, group := range m[1:] {
+			if group != "" {
+				name = group
+				break
+			}
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		params = append(params, name)
+	}
+	return params
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewMethodManifest(t *testing.T) {
+	queries := ParseQueries(`-- name: GetUser :one
+-- fetch a user by @id
+SELECT * FROM users WHERE id = @id AND name <> '@skip' AND status = sqlc.arg('status');
+
+-- name: ArchiveUsers :execrows
+WITH targets AS (
+  SELECT id FROM users WHERE created_at < $1
+)
+UPDATE users SET archived = true WHERE id IN (SELECT id FROM targets) AND tenant_id = sqlc.narg(tenant_id);
+`)
+	m := NewMethodManifest("Archive", queries)
+	expected := []QueryManifestEntry{
+		{Name: "GetUser", Kind: ":one", Verb: "SELECT", Params: []string{"id", "status"}},
+		{Name: "ArchiveUsers", Kind: ":execrows", Verb: "UPDATE", Params: []string{"$1", "tenant_id"}},
+	}
+	if !reflect.DeepEqual(m.Queries, expected) {
+		t.Errorf("unexpected manifest entries:\n got: %+v\nwant: %+v", m.Queries, expected)
+	}
+}
+
+func TestLoadQueryManifest(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	infraFile := filepath.Join("pkg", "infra", "user.go")
+
+	// マニフェストがなければクエリファイルのマーカーから復元する
+	writeProjectFiles(t, dir, map[string]string{
+		"pkg/infra/sql/query/user.sql": "-- llm-sqlc:method FindByID\n-- name: GetUser :one\nSELECT 1;\n",
+	})
+	got, err := loadQueryManifest(infraFile)
+	if err != nil {
+		t.Fatalf("loadQueryManifest() error: %v", err)
+	}
+	if m := got.Method("FindByID"); m == nil || !reflect.DeepEqual(m.QueryNames(), []string{"GetUser"}) || m.IsWrite() {
+		t.Errorf("unexpected mapping from query file: %+v", got.Methods)
+	}
+
+	// マニフェストがあればそちらを優先する
+	manifest := &QueryManifest{Methods: []MethodManifest{
+		{Method: "FindByID", Queries: []QueryManifestEntry{{Name: "GetUserByID", Kind: ":one"}}},
+	}}
+	if err := WriteManifest(manifestPath(infraFile), manifest); err != nil {
+		t.Fatalf("WriteManifest() error: %v", err)
+	}
+	got, err = loadQueryManifest(infraFile)
+	if err != nil {
+		t.Fatalf("loadQueryManifest() error: %v", err)
+	}
+	if m := got.Method("FindByID"); m == nil || !reflect.DeepEqual(m.QueryNames(), []string{"GetUserByID"}) {
+		t.Errorf("unexpected mapping from manifest: %+v", got.Methods)
+	}
+	if isWriteMethod(got, "FindByID") || !isWriteMethod(got, "Save") || isWriteMethod(nil, "ListActive") {
+		t.Errorf("isWriteMethod() returned an unexpected result")
+	}
+}
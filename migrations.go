@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSchemaPath は sqlc.yml がない場合に読むスキーマファイルです。
+var defaultSchemaPath = filepath.Join("pkg", "infra", "sql", "schema", "schema.sql")
+
+// ResolveSchemaPaths は infraFile に対応する sqlc.yml の sql ブロックから、schema に指定されたパスを
+// プロジェクトルートからの相対パスで返します。sqlc.yml がなければ defaultSchemaPath を返します。
+func ResolveSchemaPaths(cfg *Config, infraFile string) ([]string, error) {
+	sqlcConfig, err := loadSQLCConfig()
+	if err != nil {
+		return []string{defaultSchemaPath}, nil
+	}
+	configDir := filepath.Dir(sqlcConfigPath)
+	index, err := selectSQLCBlock(sqlcConfig, configDir, infraFile, cfg.SQLCPackages)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range sqlcConfig.SQL[index].Schema {
+		paths = append(paths, filepath.Join(configDir, p))
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("sqlc package #%d has no schema", index)
+	}
+	return paths, nil
+}
+
+// LoadSchema は paths のファイル（ディレクトリなら中の .sql ファイルをファイル名順に）を順に適用し、最終的なスキーマを返します。
+// goose・dbmate のマイグレーションは Up の部分だけを、golang-migrate は *.up.sql だけを使います。
+func LoadSchema(paths []string) (*Schema, error) {
+	schema := &Schema{}
+	for _, path := range paths {
+		files, err := schemaFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			up := migrationUp(normalizeNewlines(string(data)))
+			if strings.TrimSpace(up) == "" {
+				continue
+			}
+			if s := schema.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+				up = "\n\n" + strings.TrimLeft(up, "\n")
+			}
+			schema.Apply(up)
+		}
+	}
+	return schema, nil
+}
+
+// schemaFiles は path がディレクトリなら、その中の .sql ファイル（*.down.sql を除く）をファイル名順に返します。
+// sqlc と同じく、サブディレクトリは見ません。
+func schemaFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		files = append(files, filepath.Join(path, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+var (
+	gooseUpPattern    = regexp.MustCompile(`(?im)^\s*--\s*\+goose\s+Up\b.*$`)
+	gooseDownPattern  = regexp.MustCompile(`(?im)^\s*--\s*\+goose\s+Down\b.*$`)
+	dbmateUpPattern   = regexp.MustCompile(`(?im)^\s*--\s*migrate:up\b.*$`)
+	dbmateDownPattern = regexp.MustCompile(`(?im)^\s*--\s*migrate:down\b.*$`)
+)
+
+// migrationUp はマイグレーションファイルから Up の部分を取り出します。
+// goose（-- +goose Up / Down）と dbmate（-- migrate:up / down）の区切りがなければ、ファイル全体を Up とみなします。
+func migrationUp(content string) string {
+	for _, markers := range [][2]*regexp.Regexp{{gooseUpPattern, gooseDownPattern}, {dbmateUpPattern, dbmateDownPattern}} {
+		up := markers[0].FindStringIndex(content)
+		if up == nil {
+			continue
+		}
+		section := content[up[1]:]
+		if down := markers[1].FindStringIndex(section); down != nil {
+			section = section[:down[0]]
+		}
+		return strings.TrimLeft(section, "\n")
+	}
+	return content
+}
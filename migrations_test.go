@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "goose",
+			content: "-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE users (id BIGINT);\n-- +goose StatementEnd\n\n-- +goose Down\nDROP TABLE users;\n",
+			want:    "-- +goose StatementBegin\nCREATE TABLE users (id BIGINT);\n-- +goose StatementEnd\n",
+		},
+		{
+			name:    "dbmate",
+			content: "-- migrate:up\nCREATE TABLE users (id BIGINT);\n\n-- migrate:down\nDROP TABLE users;\n",
+			want:    "CREATE TABLE users (id BIGINT);\n",
+		},
+		{
+			name:    "plain",
+			content: "CREATE TABLE users (id BIGINT);\n",
+			want:    "CREATE TABLE users (id BIGINT);\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := migrationUp(tt.content); got != tt.want {
+			t.Errorf("%s: migrationUp() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		"pkg/infra/sqlc.yml": `version: "2"
+sql:
+  - engine: postgresql
+    schema: migrations
+    queries: sql/query
+    gen:
+      go:
+        package: db
+        out: db
+`,
+		"pkg/infra/migrations/000001_users.up.sql":   "CREATE TABLE users (id BIGINT PRIMARY KEY, name TEXT);\n",
+		"pkg/infra/migrations/000001_users.down.sql": "DROP TABLE users;\n",
+		"pkg/infra/migrations/000002_posts.up.sql":   "CREATE TABLE posts (id BIGINT PRIMARY KEY, user_id BIGINT);\nCREATE INDEX posts_user_idx ON posts (user_id);\n",
+		"pkg/infra/migrations/000003_alter.up.sql": `ALTER TABLE users ADD COLUMN email TEXT NOT NULL, DROP COLUMN name;
+ALTER TABLE posts RENAME COLUMN user_id TO author_id;
+ALTER TABLE posts ADD CONSTRAINT posts_author_fk FOREIGN KEY (author_id) REFERENCES users (id);
+DROP INDEX posts_user_idx;
+`,
+		"pkg/infra/migrations/000004_legacy.up.sql": "-- +goose Up\nCREATE TABLE legacy (id BIGINT);\n-- +goose Down\nDROP TABLE legacy;\n",
+		"pkg/infra/migrations/000005_drop.up.sql":   "-- migrate:up\nDROP TABLE IF EXISTS legacy;\n-- migrate:down\nCREATE TABLE legacy (id BIGINT);\n",
+	})
+
+	paths, err := ResolveSchemaPaths(&Config{}, "pkg/infra/user.go")
+	if err != nil {
+		t.Fatalf("ResolveSchemaPaths error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != filepath.Join("pkg", "infra", "migrations") {
+		t.Fatalf("paths = %v", paths)
+	}
+
+	schema, err := LoadSchema(paths)
+	if err != nil {
+		t.Fatalf("LoadSchema error: %v", err)
+	}
+	var tables []string
+	for _, table := range schema.Tables {
+		tables = append(tables, table.Name)
+	}
+	if got := strings.Join(tables, ","); got != "users,posts" {
+		t.Errorf("tables = %s", got)
+	}
+
+	var columns []string
+	for _, c := range schema.Table("users").Columns {
+		columns = append(columns, c.Name)
+	}
+	if got := strings.Join(columns, ","); got != "id,email" {
+		t.Errorf("users columns = %s", got)
+	}
+	posts := schema.Table("posts")
+	if posts.Columns[1].Name != "author_id" || len(posts.ForeignKeys) != 1 || posts.ForeignKeys[0].RefTable != "users" {
+		t.Errorf("posts = %+v", posts)
+	}
+
+	sql := schema.String()
+	for _, unwanted := range []string{"posts_user_idx", "legacy", "DROP TABLE users"} {
+		if strings.Contains(sql, unwanted) {
+			t.Errorf("expected effective schema not to contain %q:\n%s", unwanted, sql)
+		}
+	}
+	if !strings.Contains(sql, "ALTER TABLE users ADD COLUMN email") {
+		t.Errorf("expected the ALTER TABLE statements to be kept:\n%s", sql)
+	}
+
+	// sqlc.yml がなければ従来どおり schema.sql を読む
+	chdir(t, t.TempDir())
+	paths, err = ResolveSchemaPaths(&Config{}, "pkg/infra/user.go")
+	if err != nil || len(paths) != 1 || paths[0] != defaultSchemaPath {
+		t.Errorf("paths without sqlc.yml = %v, %v", paths, err)
+	}
+}
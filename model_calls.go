@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// RequestsConfig は llm-sqlc.yml の requests セクションです。モデル呼び出しのタイムアウト・再試行・流量を調整します。
+type RequestsConfig struct {
+	Timeout           string `yaml:"timeout"`             // 1回の呼び出しのタイムアウト（既定 2m）
+	MaxAttempts       int    `yaml:"max_attempts"`        // 再試行を含めた最大の試行回数（既定 5）
+	Concurrency       int    `yaml:"concurrency"`         // 同時に行う呼び出しの数（既定 4）
+	RequestsPerMinute int    `yaml:"requests_per_minute"` // 1分あたりの呼び出し数の上限（0 なら制限しない）
+}
+
+func (c *RequestsConfig) timeout() time.Duration {
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 2 * time.Minute
+	}
+	return d
+}
+
+func (c *RequestsConfig) maxAttempts() int {
+	if c.MaxAttempts <= 0 {
+		return 5
+	}
+	return c.MaxAttempts
+}
+
+func (c *RequestsConfig) concurrency() int {
+	if c.Concurrency <= 0 {
+		return 4
+	}
+	return c.Concurrency
+}
+
+func (c *RequestsConfig) validate() error {
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("requests.timeout %q is not a positive duration", c.Timeout)
+		}
+	}
+	if c.MaxAttempts < 0 || c.Concurrency < 0 || c.RequestsPerMinute < 0 {
+		return errors.New("requests.max_attempts, concurrency and requests_per_minute must not be negative")
+	}
+	return nil
+}
+
+const (
+	// retryBaseDelay は最初の再試行までの待ち時間の基準です。試行ごとに倍になります。
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay は再試行までの待ち時間の上限です。Retry-After もこの時間で打ち切ります。
+	retryMaxDelay = time.Minute
+)
+
+// modelCaller はモデル呼び出しの同時実行数と頻度を制限し、一時的な失敗を再試行します。
+type modelCaller struct {
+	mu          sync.Mutex
+	timeout     time.Duration
+	maxAttempts int
+	sem         chan struct{}
+	interval    time.Duration // 呼び出しの開始間隔の下限
+	next        time.Time     // 次の呼び出しを開始してよい時刻
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+// modelCalls はこの実行のすべてのモデル呼び出しで共有する制限です。
+var modelCalls = newModelCaller(&RequestsConfig{})
+
+func newModelCaller(c *RequestsConfig) *modelCaller {
+	caller := &modelCaller{sleep: sleepContext}
+	caller.Configure(c)
+	return caller
+}
+
+// Configure は c の設定を反映します。呼び出しを始める前に使います。
+func (m *modelCaller) Configure(c *RequestsConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = c.timeout()
+	m.maxAttempts = c.maxAttempts()
+	m.sem = make(chan struct{}, c.concurrency())
+	m.interval = 0
+	if c.RequestsPerMinute > 0 {
+		m.interval = time.Minute / time.Duration(c.RequestsPerMinute)
+	}
+}
+
+// acquire は同時実行数と頻度の制限の範囲で呼び出しを始められるまで待ちます。
+func (m *modelCaller) acquire(ctx context.Context) (release func(), err error) {
+	m.mu.Lock()
+	sem := m.sem
+	m.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() { <-sem }
+
+	m.mu.Lock()
+	now := time.Now()
+	wait := m.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	m.next = now.Add(wait + m.interval)
+	m.mu.Unlock()
+	if wait > 0 {
+		if err := m.sleep(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// Do は fn を呼び出し、レート制限（429）やサーバーエラー（5xx）、タイムアウトなどの一時的な失敗であれば、
+// 指数バックオフ（ジッター付き、Retry-After があればそれに従う）で再試行します。
+// fn には呼び出しごとのタイムアウトを設定した context を渡します。ctx が取り消されたら直ちに戻ります。
+func (m *modelCaller) Do(ctx context.Context, logger *slog.Logger, fn func(ctx context.Context) error) error {
+	m.mu.Lock()
+	timeout, maxAttempts := m.timeout, m.maxAttempts
+	m.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		release, err := m.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = fn(callCtx)
+		cancel()
+		release()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		delay, retryable := retryDelay(err, attempt)
+		if !retryable || attempt >= maxAttempts {
+			if retryable {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		logger.Warn("model call failed, retrying", "attempt", attempt, "delay", delay.Round(time.Millisecond), "error", err)
+		if err := m.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// errEmptyResponse はモデルが選択肢を1つも返さなかったことを表します。再試行の対象です。
+var errEmptyResponse = errors.New("model returned no choices")
+
+// RefusalError はモデルが応答を拒否したことを表します。再試行しても結果は変わらないので、そのまま返します。
+type RefusalError struct {
+	Refusal string
+}
+
+func (e *RefusalError) Error() string {
+	return "model refused to answer: " + e.Refusal
+}
+
+// retryDelay は err が再試行すべき失敗かどうかと、再試行までの待ち時間を返します。
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *openai.Error
+	switch {
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode != http.StatusRequestTimeout &&
+			apiErr.StatusCode != http.StatusConflict && apiErr.StatusCode < 500 {
+			return 0, false
+		}
+		if apiErr.Response != nil {
+			if d, ok := retryAfter(apiErr.Response.Header, time.Now()); ok {
+				return d, true
+			}
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errEmptyResponse), errors.Is(err, io.ErrUnexpectedEOF):
+	default:
+		var netErr net.Error
+		if !errors.As(err, &netErr) {
+			return 0, false
+		}
+	}
+	return backoff(attempt), true
+}
+
+// backoff は attempt 回目の失敗の後の待ち時間です。基準の 2^(attempt-1) 倍の半分から全体までの間でランダムに選びます。
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << (attempt - 1)
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter は retry-after-ms または Retry-After（秒数か HTTP の日付）ヘッダーの待ち時間を返します。
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	var d time.Duration
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		d = time.Duration(ms * float64(time.Millisecond))
+	} else if v := header.Get("Retry-After"); v == "" {
+		return 0, false
+	} else if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		d = time.Duration(secs * float64(time.Second))
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if d < 0 {
+		d = 0
+	}
+	if d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forEachMethod は methods のそれぞれについて fn を並行に実行します。モデル呼び出しの数は modelCalls が制限します。
+// どれかが失敗したら残りを取り消し、最初のエラーを返します。
+func forEachMethod(ctx context.Context, methods []string, fn func(ctx context.Context, i int, method string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i, method := range methods {
+		wg.Add(1)
+		go func(i int, method string) {
+			defer wg.Done()
+			if err := fn(ctx, i, method); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i, method)
+	}
+	wg.Wait()
+	return firstErr
+}
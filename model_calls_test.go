@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "none", header: http.Header{}, ok: false},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"10"}}, want: 250 * time.Millisecond, ok: true},
+		{name: "seconds", header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second, ok: true},
+		{name: "http date", header: http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}}, want: 5 * time.Second, ok: true},
+		{name: "capped", header: http.Header{"Retry-After": {"3600"}}, want: retryMaxDelay, ok: true},
+		{name: "invalid", header: http.Header{"Retry-After": {"soon"}}, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header, now)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("retryAfter() = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	apiError := func(status int, header http.Header) error {
+		return &openai.Error{StatusCode: status, Response: &http.Response{StatusCode: status, Header: header}}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "rate limited", err: apiError(http.StatusTooManyRequests, http.Header{}), retryable: true},
+		{name: "server error", err: fmt.Errorf("wrapped: %w", apiError(http.StatusBadGateway, http.Header{})), retryable: true},
+		{name: "bad request", err: apiError(http.StatusBadRequest, http.Header{}), retryable: false},
+		{name: "unauthorized", err: apiError(http.StatusUnauthorized, http.Header{}), retryable: false},
+		{name: "timeout", err: context.DeadlineExceeded, retryable: true},
+		{name: "empty response", err: errEmptyResponse, retryable: true},
+		{name: "connection closed", err: io.ErrUnexpectedEOF, retryable: true},
+		{name: "refusal", err: &RefusalError{Refusal: "no"}, retryable: false},
+		{name: "canceled", err: context.Canceled, retryable: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retryable := retryDelay(tt.err, 1)
+			if retryable != tt.retryable {
+				t.Fatalf("retryDelay() retryable = %v, want %v", retryable, tt.retryable)
+			}
+			if retryable && (delay < retryBaseDelay/2 || delay > retryBaseDelay) {
+				t.Errorf("first backoff %v is outside [%v, %v]", delay, retryBaseDelay/2, retryBaseDelay)
+			}
+		})
+	}
+
+	// Retry-After があればバックオフより優先する
+	delay, _ := retryDelay(apiError(http.StatusTooManyRequests, http.Header{"Retry-After": {"7"}}), 1)
+	if delay != 7*time.Second {
+		t.Errorf("expected Retry-After to be honoured, got %v", delay)
+	}
+}
+
+// fakeSleepCaller は待ち時間を記録するだけで実際には待たない modelCaller を返します。
+func fakeSleepCaller(c *RequestsConfig, slept *[]time.Duration) *modelCaller {
+	caller := newModelCaller(c)
+	caller.sleep = func(ctx context.Context, d time.Duration) error {
+		*slept = append(*slept, d)
+		return ctx.Err()
+	}
+	return caller
+}
+
+func TestModelCallerDo(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("retries until success", func(t *testing.T) {
+		var slept []time.Duration
+		caller := fakeSleepCaller(&RequestsConfig{MaxAttempts: 3}, &slept)
+		calls := 0
+		err := caller.Do(context.Background(), logger, func(ctx context.Context) error {
+			calls++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("expected a per-call deadline")
+			}
+			if calls < 3 {
+				return errEmptyResponse
+			}
+			return nil
+		})
+		if err != nil || calls != 3 || len(slept) != 2 {
+			t.Errorf("got err=%v calls=%d sleeps=%d; want success after 3 calls and 2 sleeps", err, calls, len(slept))
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var slept []time.Duration
+		caller := fakeSleepCaller(&RequestsConfig{MaxAttempts: 2}, &slept)
+		calls := 0
+		err := caller.Do(context.Background(), logger, func(ctx context.Context) error {
+			calls++
+			return errEmptyResponse
+		})
+		if !errors.Is(err, errEmptyResponse) || calls != 2 {
+			t.Errorf("got err=%v calls=%d; want errEmptyResponse after 2 calls", err, calls)
+		}
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		var slept []time.Duration
+		caller := fakeSleepCaller(&RequestsConfig{}, &slept)
+		calls := 0
+		refusal := &RefusalError{Refusal: "cannot help"}
+		err := caller.Do(context.Background(), logger, func(ctx context.Context) error {
+			calls++
+			return refusal
+		})
+		var got *RefusalError
+		if !errors.As(err, &got) || calls != 1 || len(slept) != 0 {
+			t.Errorf("got err=%v calls=%d; want the refusal without retrying", err, calls)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		var slept []time.Duration
+		caller := fakeSleepCaller(&RequestsConfig{}, &slept)
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := caller.Do(ctx, logger, func(ctx context.Context) error {
+			calls++
+			cancel()
+			return ctx.Err()
+		})
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("got err=%v calls=%d; want context.Canceled after 1 call", err, calls)
+		}
+	})
+
+	t.Run("spaces calls by requests per minute", func(t *testing.T) {
+		var slept []time.Duration
+		caller := fakeSleepCaller(&RequestsConfig{RequestsPerMinute: 60, Concurrency: 1}, &slept)
+		for i := 0; i < 2; i++ {
+			if err := caller.Do(context.Background(), logger, func(ctx context.Context) error { return nil }); err != nil {
+				t.Fatalf("Do error: %v", err)
+			}
+		}
+		if len(slept) != 1 || slept[0] <= 0 || slept[0] > time.Second {
+			t.Errorf("expected the second call to wait up to 1s, got %v", slept)
+		}
+	})
+}
+
+func TestForEachMethod(t *testing.T) {
+	methods := []string{"FindByID", "Save", "Delete"}
+	results := make([]string, len(methods))
+	err := forEachMethod(context.Background(), methods, func(ctx context.Context, i int, method string) error {
+		results[i] = method
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachMethod error: %v", err)
+	}
+	for i, method := range methods {
+		if results[i] != method {
+			t.Errorf("results[%d] = %q, want %q", i, results[i], method)
+		}
+	}
+
+	// 1つが失敗したら残りは取り消され、そのエラーが返る
+	failure := errors.New("boom")
+	var cancelled atomic.Int32
+	err = forEachMethod(context.Background(), methods, func(ctx context.Context, i int, method string) error {
+		if method == "Save" {
+			return failure
+		}
+		<-ctx.Done()
+		cancelled.Add(1)
+		return ctx.Err()
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the first failure, got %v", err)
+	}
+	if cancelled.Load() != 2 {
+		t.Errorf("expected the other methods to be cancelled, got %d", cancelled.Load())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// writeQueryKinds は書き込みだけを行うクエリの種類です。
+var writeQueryKinds = []string{":exec", ":execrows", ":execresult", ":execlastid", ":copyfrom", ":batchexec"}
+
+// checkNPlusOne は、for や range のループの中で sqlc のクエリを呼び出しているメソッド（N+1 問題）を報告します。
+// SQLite で1件ずつ実行する INSERT（LoopedQueries）は除きます。
+// メッセージは次に SQL を生成するときのプロンプトにもそのまま渡すので、どう直すべきかまで書きます。
+func checkNPlusOne(c *CheckContext) []Finding {
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		fn := c.Methods[method]
+		var loops []*ast.BlockStmt
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ForStmt:
+				loops = append(loops, n.Body)
+			case *ast.RangeStmt:
+				loops = append(loops, n.Body)
+			}
+			return true
+		})
+		if len(loops) == 0 {
+			continue
+		}
+		for _, call := range queryCalls(c, fn) {
+			name := calledName(call)
+			if !insideAny(call, loops) || containsString(c.LoopedQueries[method], name) {
+				continue
+			}
+			kind := ""
+			if c.QueryKinds != nil {
+				kind = c.QueryKinds(name)
+			}
+			findings = append(findings, c.newFinding("n-plus-one", method, call,
+				"%s calls %s inside a loop (N+1). %s", method, name, nPlusOneFix(c.DBPackage, containsString(writeQueryKinds, kind))))
+		}
+	}
+	return findings
+}
+
+// nPlusOneFix は N+1 の指摘に添える直し方です。pkg のエンジンとドライバーで sqlc が受け付ける書き方だけを示します。
+func nPlusOneFix(pkg *DBPackage, write bool) string {
+	engine := ""
+	if pkg != nil {
+		engine = pkg.Engine
+	}
+	sliced := engine == "mysql" || engine == "sqlite"
+	switch {
+	case write && sliced:
+		fix := "Write all items with one query instead, e.g. UPDATE ... WHERE id IN (sqlc.slice(ids))"
+		if engine == "mysql" {
+			fix += ", or :copyfrom for plain inserts"
+		}
+		return fix
+	case write:
+		fix := "Write all items with one query instead: INSERT ... SELECT * FROM unnest(@ids::bigint[], ...)"
+		if supportsPgxBatch(pkg) {
+			fix += ", :copyfrom or :batchexec"
+		}
+		return fix
+	case sliced:
+		return "Fetch the rows for all items with one query that takes a list, e.g. WHERE id IN (sqlc.slice(ids)), and match them in Go"
+	}
+	return "Fetch the rows for all items with one query that takes an array, e.g. WHERE id = ANY(@ids::bigint[]), and match them in Go"
+}
+
+// insideAny は node がいずれかのブロックの中にあるかを返します。
+func insideAny(node ast.Node, blocks []*ast.BlockStmt) bool {
+	for _, b := range blocks {
+		if b.Pos() <= node.Pos() && node.End() <= b.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// nPlusOneFeedback は n-plus-one の指摘をメソッドごとにまとめます。
+func nPlusOneFeedback(findings []Finding) map[string][]string {
+	feedback := make(map[string][]string)
+	for _, f := range findings {
+		if f.Rule == "n-plus-one" && !containsString(feedback[f.Method], f.Message) {
+			feedback[f.Method] = append(feedback[f.Method], f.Message)
+		}
+	}
+	return feedback
+}
+
+// recordFeedback は指摘をマニフェストのメソッドに記録します。記録した（あるいは消した）メソッドの名前を返します。
+// 次に sql コマンドを実行したとき、記録された指摘はそのメソッドのプロンプトに含まれます。
+func recordFeedback(manifest *QueryManifest, feedback map[string][]string) []string {
+	var changed []string
+	for i := range manifest.Methods {
+		m := &manifest.Methods[i]
+		if strings.Join(m.Feedback, "\n") == strings.Join(feedback[m.Method], "\n") {
+			continue
+		}
+		m.Feedback = feedback[m.Method]
+		changed = append(changed, m.Method)
+	}
+	return changed
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckNPlusOne(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/infra/db"
+)
+
+type PostRepositoryImpl struct {
+	DB db.DBTX
+}
+
+func (repo *PostRepositoryImpl) ListWithAuthors(ctx context.Context, ids []int64) error {
+	query := db.New(repo.DB)
+	posts, err := query.ListPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		if _, err := query.GetUser(ctx, p.AuthorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *PostRepositoryImpl) AddTags(ctx context.Context, tags []string) error {
+	for i := 0; i < len(tags); i++ {
+		if err := db.New(repo.DB).InsertTag(ctx, tags[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, []string{"ListWithAuthors", "AddTags"}, &DBPackage{ImportPath: "example.com/app/pkg/infra/db"})
+	c.QueryKinds = func(name string) string {
+		return map[string]string{"ListPosts": ":many", "GetUser": ":one", "InsertTag": ":exec"}[name]
+	}
+
+	findings := checkNPlusOne(c)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d: %v", len(findings), findings)
+	}
+	if findings[0].Method != "ListWithAuthors" || !strings.Contains(findings[0].Message, "calls GetUser inside a loop") ||
+		!strings.Contains(findings[0].Message, "ANY(@ids::bigint[])") {
+		t.Errorf("unexpected first finding: %v", findings[0])
+	}
+	if findings[1].Method != "AddTags" || !strings.Contains(findings[1].Message, "calls InsertTag inside a loop") ||
+		!strings.Contains(findings[1].Message, "unnest") {
+		t.Errorf("unexpected second finding: %v", findings[1])
+	}
+
+	if strings.Contains(findings[1].Message, ":copyfrom") {
+		t.Errorf("expected no :copyfrom without pgx: %v", findings[1])
+	}
+	c.DBPackage = &DBPackage{ImportPath: "example.com/app/pkg/infra/db", Engine: "postgresql", Driver: pgxV5Driver}
+	if pgx := checkNPlusOne(c); len(pgx) != 2 || !strings.Contains(pgx[1].Message, ":copyfrom or :batchexec") {
+		t.Errorf("expected pgx batch annotations to be suggested, got %v", pgx)
+	}
+	c.DBPackage = &DBPackage{ImportPath: "example.com/app/pkg/infra/db", Engine: "sqlite", Driver: databaseSQLDriver}
+	for _, f := range checkNPlusOne(c) {
+		if !strings.Contains(f.Message, "IN (sqlc.slice(ids))") || strings.Contains(f.Message, "::") || strings.Contains(f.Message, ":copyfrom") {
+			t.Errorf("expected only sqlc.slice for SQLite: %v", f)
+		}
+	}
+
+	// SQLite で1件ずつ実行する INSERT はループの中で呼び出してよい
+	c.LoopedQueries = map[string][]string{"AddTags": {"InsertTag"}}
+	if findings := checkNPlusOne(c); len(findings) != 1 || findings[0].Method != "ListWithAuthors" {
+		t.Errorf("expected the looped insert to be allowed, got %v", findings)
+	}
+
+	manifest := &QueryManifest{Methods: []MethodManifest{
+		{Method: "ListWithAuthors"},
+		{Method: "AddTags"},
+		{Method: "Delete", Feedback: []string{"fixed already"}},
+	}}
+	changed := recordFeedback(manifest, nPlusOneFeedback(findings))
+	if strings.Join(changed, ",") != "ListWithAuthors,AddTags,Delete" {
+		t.Errorf("changed = %v", changed)
+	}
+	if len(manifest.Method("ListWithAuthors").Feedback) != 1 || manifest.Method("Delete").Feedback != nil {
+		t.Errorf("unexpected feedback: %+v", manifest.Methods)
+	}
+	if changed := recordFeedback(manifest, nPlusOneFeedback(findings)); len(changed) != 0 {
+		t.Errorf("expected no changes for the same findings, got %v", changed)
+	}
+}
+
+func TestChecksConfigSeverity(t *testing.T) {
+	c := &ChecksConfig{Severity: map[string]string{"bulk": SeverityOff}}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	for rule, want := range map[string]string{"bulk": SeverityOff, "n-plus-one": SeverityError, "transaction": SeverityWarn} {
+		if got := c.severity(rule); got != want {
+			t.Errorf("severity(%s) = %q, want %q", rule, got, want)
+		}
+	}
+	if err := (&ChecksConfig{Severity: map[string]string{"n+1": SeverityWarn}}).validate(); err == nil {
+		t.Errorf("expected an error for an unknown check")
+	}
+	if err := (&ChecksConfig{Severity: map[string]string{"bulk": "fatal"}}).validate(); err == nil {
+		t.Errorf("expected an error for an unknown severity")
+	}
+}
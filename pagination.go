@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PaginationConfig は一覧系メソッドのページングの方式と、結果を返す型です。
+type PaginationConfig struct {
+	// Style は keyset（カーソル）か offset です。空ならページ指定の型のフィールドから推測します。
+	Style string `yaml:"style"`
+	// Result はページングの結果を返す型の名前です（例: entity.PageResult）。空なら戻り値の型名から推測します。
+	Result string `yaml:"result"`
+}
+
+const (
+	PaginationKeyset = "keyset"
+	PaginationOffset = "offset"
+)
+
+func (c *PaginationConfig) validate() error {
+	switch c.Style {
+	case "", PaginationKeyset, PaginationOffset:
+		return nil
+	}
+	return fmt.Errorf("pagination.style: unknown style %q (expected keyset or offset)", c.Style)
+}
+
+// 型名の末尾で、絞り込み条件・ページ指定・ページングの結果の型を見分ける
+var (
+	filterTypeSuffixes = []string{"Filter", "Criteria", "Condition", "Conditions", "SearchParams"}
+	pageTypeSuffixes   = []string{"Page", "Pagination", "PageRequest", "Paging", "Cursor", "PageParams", "PageOptions"}
+	resultTypeSuffixes = []string{"Page", "PageResult", "Paginated", "Connection", "ListResult"}
+)
+
+// StructDecl は struct 型の宣言1つ分です。
+type StructDecl struct {
+	Name   string
+	Src    string
+	Fields []MethodParam
+}
+
+// LoadStructDecls は entityRoot 以下（再帰的）と infraDir 直下の Go ファイル（_test.go を除く）から struct 型の宣言を集めます。
+// 同じ名前の型はエンティティのものが優先されます。存在しないディレクトリは無視します。
+func LoadStructDecls(entityRoot string, infraDir string) (map[string]*StructDecl, error) {
+	decls := make(map[string]*StructDecl)
+	for _, dir := range []string{entityRoot, infraDir} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// infra 側は sqlc の生成先などのサブパッケージを含めない
+				if dir == infraDir && path != dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return parseStructDecls(path, string(src), decls)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return decls, nil
+}
+
+// parseStructDecls は src の struct 型の宣言を decls に加えます。
+func parseStructDecls(path string, src string, decls map[string]*StructDecl) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || decls[ts.Name.Name] != nil {
+				continue
+			}
+			d := &StructDecl{Name: ts.Name.Name, Src: "type " + nodeString(fset, ts)}
+			for _, field := range st.Fields.List {
+				typ := nodeString(fset, field.Type)
+				if len(field.Names) == 0 {
+					d.Fields = append(d.Fields, MethodParam{Name: baseTypeName(typ), Type: typ})
+				}
+				for _, n := range field.Names {
+					d.Fields = append(d.Fields, MethodParam{Name: n.Name, Type: typ})
+				}
+			}
+			decls[ts.Name.Name] = d
+		}
+	}
+	return nil
+}
+
+// baseTypeName は *entity.Page[T] や []entity.User のような型から、型名（Page, User）だけを取り出します。
+func baseTypeName(typ string) string {
+	for {
+		trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(typ, "*"), "[]"), "...")
+		if trimmed == typ {
+			break
+		}
+		typ = trimmed
+	}
+	if i := strings.Index(typ, "["); i >= 0 {
+		typ = typ[:i]
+	}
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		typ = typ[i+1:]
+	}
+	return typ
+}
+
+func hasAnySuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListParam は一覧系メソッドの、struct 型の引数1つ分です。
+type ListParam struct {
+	Param MethodParam
+	Decl  *StructDecl // 宣言が見つからなければ nil
+}
+
+// ListShape は一覧系メソッドの絞り込み条件・ページ指定・結果の型です。
+type ListShape struct {
+	Method     string
+	Filter     *ListParam
+	Page       *ListParam
+	Style      string      // keyset か offset（Page がなければ空）
+	ResultType string      // 戻り値の型（例: *entity.PageResult[entity.User]）
+	Result     *StructDecl // ResultType の宣言（ページングの結果でなければ nil）
+}
+
+// DetectListShape は method の引数と戻り値から、絞り込み条件・ページ指定・ページングの結果の型を見つけます。
+// どれも見つからなければ nil を返します。
+func DetectListShape(method InterfaceMethod, decls map[string]*StructDecl, cfg *PaginationConfig) *ListShape {
+	shape := &ListShape{Method: method.Name}
+	for _, p := range method.Params {
+		name := baseTypeName(p.Type)
+		switch {
+		case shape.Filter == nil && hasAnySuffix(name, filterTypeSuffixes):
+			shape.Filter = &ListParam{Param: p, Decl: decls[name]}
+		case shape.Page == nil && hasAnySuffix(name, pageTypeSuffixes):
+			shape.Page = &ListParam{Param: p, Decl: decls[name]}
+		}
+	}
+	for _, r := range method.Results {
+		name := baseTypeName(r)
+		matched := hasAnySuffix(name, resultTypeSuffixes)
+		if cfg.Result != "" {
+			matched = name == baseTypeName(cfg.Result)
+		}
+		if matched && decls[name] != nil {
+			shape.ResultType, shape.Result = r, decls[name]
+			break
+		}
+	}
+	if shape.Filter == nil && shape.Page == nil && shape.Result == nil {
+		return nil
+	}
+	if shape.Page != nil || shape.Result != nil {
+		shape.Style = cfg.Style
+		if shape.Style == "" {
+			shape.Style = guessPaginationStyle(shape)
+		}
+	}
+	return shape
+}
+
+// guessPaginationStyle はページ指定と結果の型のフィールド名から方式を推測します。分からなければ keyset です。
+func guessPaginationStyle(shape *ListShape) string {
+	var fields []MethodParam
+	if shape.Page != nil && shape.Page.Decl != nil {
+		fields = append(fields, shape.Page.Decl.Fields...)
+	}
+	if shape.Result != nil {
+		fields = append(fields, shape.Result.Fields...)
+	}
+	if findField(fields, "cursor", "after", "before", "lastid", "token") != nil {
+		return PaginationKeyset
+	}
+	if findField(fields, "offset", "number", "page") != nil {
+		return PaginationOffset
+	}
+	return PaginationKeyset
+}
+
+// findField は名前（小文字にしたもの）に words のいずれかを含む最初のフィールドを返します。
+func findField(fields []MethodParam, words ...string) *MethodParam {
+	for i, f := range fields {
+		lower := strings.ToLower(f.Name)
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				return &fields[i]
+			}
+		}
+	}
+	return nil
+}
+
+// structBlock は宣言のソースをコードブロックにして返します。宣言が見つからなければ空文字です。
+func (p *ListParam) structBlock() string {
+	if p.Decl == nil {
+		return ""
+	}
+	return "```go\n" + p.Decl.Src + "\n```\n"
+}
+
+// paginationExamples は PaginationSQLGuidance に載せる例のクエリです。sqlc が受け付ける書き方はエンジンによって違います。
+type paginationExamples struct {
+	filterHint string // 条件を任意にする方法の説明
+	filter     string
+	keyset     string
+	offset     string
+	count      string
+}
+
+// paginationExamplesFor は pkg のエンジンに合わせた例を返します。MySQL と SQLite では型のキャストや配列、
+// 行値の比較を使わず、sqlc.narg と sqlc.slice で書きます。分からなければ PostgreSQL の例です。
+func paginationExamplesFor(pkg *DBPackage) paginationExamples {
+	if pkg != nil && (pkg.Engine == "mysql" || pkg.Engine == "sqlite") {
+		return paginationExamples{
+			filterHint: "using sqlc.narg for nullable parameters, and a flag parameter for a slice (sqlc.slice with an empty slice matches no rows)",
+			filter: `-- name: ListAuthors :many
+SELECT * FROM authors
+WHERE (sqlc.narg(name) IS NULL OR name = sqlc.narg(name))
+  AND (sqlc.narg(created_after) IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.arg(filter_statuses) = FALSE OR status IN (sqlc.slice(statuses)));`,
+			keyset: `-- name: ListAuthorsPage :many
+SELECT * FROM authors
+WHERE (sqlc.narg(after_created_at) IS NULL
+       OR created_at < sqlc.narg(after_created_at)
+       OR (created_at = sqlc.narg(after_created_at) AND id < sqlc.arg(after_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);`,
+			offset: `-- name: ListAuthorsPage :many
+SELECT * FROM authors
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);`,
+			count: `-- name: CountAuthors :one
+SELECT count(*) FROM authors
+WHERE (sqlc.narg(name) IS NULL OR name = sqlc.narg(name));`,
+		}
+	}
+	return paginationExamples{
+		filterHint: "using sqlc.narg for nullable parameters or a @set_x flag",
+		filter: `-- name: ListAuthors :many
+SELECT * FROM authors
+WHERE (sqlc.narg(name)::text IS NULL OR name = sqlc.narg(name))
+  AND (CASE WHEN @set_created_after::bool THEN created_at >= @created_after ELSE TRUE END)
+  AND (cardinality(@statuses::text[]) = 0 OR status = ANY(@statuses::text[]));`,
+		keyset: `-- name: ListAuthorsPage :many
+SELECT * FROM authors
+WHERE (sqlc.narg(after_created_at)::timestamptz IS NULL
+       OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT @page_limit;`,
+		offset: `-- name: ListAuthorsPage :many
+SELECT * FROM authors
+ORDER BY created_at DESC, id DESC
+LIMIT @page_limit OFFSET @page_offset;`,
+		count: `-- name: CountAuthors :one
+SELECT count(*) FROM authors
+WHERE (sqlc.narg(name)::text IS NULL OR name = sqlc.narg(name));`,
+	}
+}
+
+// PaginationSQLGuidance は、絞り込み条件を1つのクエリで任意にし、ページングを keyset か offset で書かせるための説明です。
+// 例のクエリは pkg のエンジンに合わせます。一覧系メソッドでなければ空文字を返します。
+func PaginationSQLGuidance(shape *ListShape, pkg *DBPackage) string {
+	if shape == nil {
+		return ""
+	}
+	examples := paginationExamplesFor(pkg)
+	var b strings.Builder
+	if f := shape.Filter; f != nil {
+		b.WriteString(fmt.Sprintf("%s takes optional filters (%s %s):\n", shape.Method, f.Param.Name, f.Param.Type))
+		b.WriteString(f.structBlock())
+		b.WriteString(fmt.Sprintf(`Every field is optional. A nil pointer, an empty slice or a zero value means "do not filter by this field".
+Do not write one query per combination of filters. Write a single query whose conditions are skipped when the parameter is not set, %s:
+
+%s
+
+`, examples.filterHint, examples.filter))
+	}
+	if shape.Style != "" {
+		if p := shape.Page; p != nil {
+			b.WriteString(fmt.Sprintf("%s returns one page at a time (%s %s):\n", shape.Method, p.Param.Name, p.Param.Type))
+			b.WriteString(p.structBlock())
+		}
+		switch shape.Style {
+		case PaginationKeyset:
+			b.WriteString(fmt.Sprintf(`Use keyset pagination. Never use OFFSET. Order by a unique, stable key and continue after the last row of the previous page.
+Fetch one row more than the page size, so that the program can tell whether there is a next page:
+
+%s
+
+`, examples.keyset))
+		case PaginationOffset:
+			b.WriteString(fmt.Sprintf(`Use offset pagination. The ORDER BY must end with a unique column, so that rows do not move between pages:
+
+%s
+
+`, examples.offset))
+		}
+		if shape.Result != nil {
+			if total := findField(shape.Result.Fields, "total", "count"); total != nil {
+				b.WriteString(fmt.Sprintf(`The result has a total count (%s). Add a separate :one query that counts the rows with the same filters, without ORDER BY and LIMIT:
+
+%s
+
+`, total.Name, examples.count))
+			}
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n\n")
+}
+
+// PaginationProgramGuidance は、絞り込み条件をクエリのパラメータに渡す方法と、ページングの結果の組み立て方の説明です。
+// 一覧系メソッドでなければ空文字を返します。
+func PaginationProgramGuidance(shape *ListShape) string {
+	if shape == nil {
+		return ""
+	}
+	var lines []string
+	if f := shape.Filter; f != nil {
+		lines = append(lines, fmt.Sprintf("- Pass each field of %s to the query parameters. When a field is not set, pass NULL (a nil pointer or an invalid null value) or set_x = false, so that the condition is skipped.", f.Param.Name))
+	}
+	pageName := "the page request"
+	if shape.Page != nil {
+		pageName = shape.Page.Param.Name
+	}
+	switch shape.Style {
+	case PaginationKeyset:
+		lines = append(lines,
+			fmt.Sprintf("- Take the page size from %s. If it is not set, use 20. Request one more row than the page size.", pageName),
+			"- If more rows than the page size are returned, drop the extra row: there is a next page, and its cursor is built from the last row that is returned.",
+			fmt.Sprintf("- Decode the cursor of %s into the key columns of the ORDER BY. An empty cursor means the first page.", pageName))
+	case PaginationOffset:
+		lines = append(lines, fmt.Sprintf("- Take the page size and the offset (or page number × page size) from %s. If the page size is not set, use 20.", pageName))
+	}
+	if r := shape.Result; r != nil {
+		lines = append(lines, fmt.Sprintf("- Return the page as %s. Fill it the same way in every method that returns it:", shape.ResultType))
+		for _, f := range r.Fields {
+			lower := strings.ToLower(f.Name)
+			switch {
+			case strings.HasPrefix(f.Type, "[]"):
+				lines = append(lines, fmt.Sprintf("  - %s: the rows of this page converted to entities. Use an empty slice, not nil, when there are no rows.", f.Name))
+			case f.Type == "bool" && (strings.Contains(lower, "next") || strings.Contains(lower, "more")):
+				lines = append(lines, fmt.Sprintf("  - %s: whether there is a next page.", f.Name))
+			case strings.Contains(lower, "cursor") || strings.Contains(lower, "token"):
+				lines = append(lines, fmt.Sprintf("  - %s: the cursor of the next page, or the zero value when there is no next page.", f.Name))
+			case strings.Contains(lower, "total") || strings.Contains(lower, "count"):
+				lines = append(lines, fmt.Sprintf("  - %s: the result of the count query with the same filters.", f.Name))
+			}
+		}
+		lines = append(lines, "```go\n"+r.Src+"\n```")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const samplePaginationTypes = `package entity
+
+import "time"
+
+type UserFilter struct {
+	Name          *string
+	Statuses      []UserStatus
+	CreatedAfter  *time.Time
+}
+
+type Page struct {
+	Cursor string
+	Limit  int
+}
+
+type OffsetPage struct {
+	Offset int
+	Limit  int
+}
+
+type PageResult[T any] struct {
+	Items      []T
+	NextCursor string
+	HasNext    bool
+	Total      int64
+}
+`
+
+func TestDetectListShape(t *testing.T) {
+	setupSampleProject(t, map[string]string{"pkg/domain/entity/page.go": samplePaginationTypes})
+	decls, err := LoadStructDecls(filepath.Join("pkg", "domain", "entity"), filepath.Join("pkg", "infra"))
+	if err != nil {
+		t.Fatalf("LoadStructDecls error: %v", err)
+	}
+
+	list := InterfaceMethod{
+		Name: "List",
+		Params: []MethodParam{
+			{Name: "ctx", Type: "context.Context"},
+			{Name: "filter", Type: "entity.UserFilter"},
+			{Name: "page", Type: "entity.Page"},
+		},
+		Results: []string{"*entity.PageResult[entity.User]", "error"},
+	}
+	shape := DetectListShape(list, decls, &PaginationConfig{})
+	if shape == nil || shape.Filter == nil || shape.Page == nil || shape.Result == nil {
+		t.Fatalf("unexpected shape: %+v", shape)
+	}
+	if shape.Style != PaginationKeyset {
+		t.Errorf("Style = %q, want keyset", shape.Style)
+	}
+
+	sql := PaginationSQLGuidance(shape, nil)
+	for _, want := range []string{
+		"List takes optional filters (filter entity.UserFilter)",
+		"type UserFilter struct",
+		"sqlc.narg(name)",
+		"Use keyset pagination. Never use OFFSET.",
+		"The result has a total count (Total).",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected SQL guidance to contain %q:\n%s", want, sql)
+		}
+	}
+	for _, engine := range []string{"mysql", "sqlite"} {
+		sql := PaginationSQLGuidance(shape, &DBPackage{Engine: engine, Driver: databaseSQLDriver})
+		if !strings.Contains(sql, "IN (sqlc.slice(statuses))") || !strings.Contains(sql, "LIMIT sqlc.arg(page_limit)") {
+			t.Errorf("%s: expected sqlc.slice and sqlc.arg in the SQL guidance:\n%s", engine, sql)
+		}
+		for _, unwanted := range []string{"::", "ANY(", "cardinality(", "(created_at, id) <", "@"} {
+			if strings.Contains(sql, unwanted) {
+				t.Errorf("%s: expected SQL guidance not to contain %q:\n%s", engine, unwanted, sql)
+			}
+		}
+	}
+	program := PaginationProgramGuidance(shape)
+	for _, want := range []string{
+		"- Return the page as *entity.PageResult[entity.User].",
+		"  - Items: the rows of this page converted to entities.",
+		"  - NextCursor: the cursor of the next page",
+		"  - HasNext: whether there is a next page.",
+		"  - Total: the result of the count query",
+	} {
+		if !strings.Contains(program, want) {
+			t.Errorf("expected program guidance to contain %q:\n%s", want, program)
+		}
+	}
+
+	offset := InterfaceMethod{Name: "Search", Params: []MethodParam{{Name: "page", Type: "*entity.OffsetPage"}}, Results: []string{"[]*entity.User", "error"}}
+	if shape := DetectListShape(offset, decls, &PaginationConfig{}); shape == nil || shape.Style != PaginationOffset {
+		t.Errorf("expected offset pagination, got %+v", shape)
+	}
+	if shape := DetectListShape(offset, decls, &PaginationConfig{Style: PaginationKeyset}); shape == nil || shape.Style != PaginationKeyset {
+		t.Errorf("expected the configured style to win, got %+v", shape)
+	}
+	if shape := DetectListShape(InterfaceMethod{Name: "FindByID", Params: []MethodParam{{Name: "id", Type: "int64"}}}, decls, &PaginationConfig{}); shape != nil {
+		t.Errorf("expected no shape for FindByID, got %+v", shape)
+	}
+	if err := (&PaginationConfig{Style: "cursor"}).validate(); err == nil {
+		t.Errorf("expected an error for an unknown style")
+	}
+}
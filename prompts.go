@@ -0,0 +1,153 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// builtinPrompts はバイナリに埋め込まれた既定のプロンプトテンプレートです。
+// 各テンプレートは {{define "sql.notes"}} のような名前付きセクションの集まりで、
+// プロジェクトの prompts.dir に同じ名前のセクションを定義すると、そのセクションだけを上書きできます。
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// defaultPromptsDir はプロジェクト固有のテンプレートを置く既定のディレクトリです。
+const defaultPromptsDir = ".llm-sqlc/prompts"
+
+// PromptsConfig は llm-sqlc.yml の prompts セクションです。
+type PromptsConfig struct {
+	Dir string `yaml:"dir"` // 上書き用テンプレート（*.tmpl）を置くディレクトリ
+}
+
+func (c *PromptsConfig) dir() string {
+	return defaultString(c.Dir, defaultPromptsDir)
+}
+
+// PromptEntity はプロンプトに載せるエンティティ定義です。
+type PromptEntity struct {
+	Path string // プロジェクトルートからの相対パス
+	Code string
+}
+
+// newPromptEntities はエンティティ定義をプロンプト用に変換します。
+func newPromptEntities(entities []EntityDefinition) []PromptEntity {
+	var result []PromptEntity
+	for _, entity := range entities {
+		relPath, err := filepath.Rel(".", entity.FileName)
+		if err != nil {
+			relPath = entity.FileName
+		}
+		result = append(result, PromptEntity{Path: relPath, Code: entity.Code})
+	}
+	return result
+}
+
+var promptFuncs = template.FuncMap{
+	"slash": filepath.ToSlash,
+}
+
+// Prompts は既定のテンプレートにプロジェクト固有の上書きを重ねたテンプレート群です。
+type Prompts struct {
+	tmpl *template.Template
+}
+
+// LoadPrompts は埋め込みのテンプレートを読み込み、cfg.Prompts.Dir にある *.tmpl で上書きします。
+func LoadPrompts(cfg *Config) (*Prompts, error) {
+	tmpl := template.New("prompts").Funcs(promptFuncs)
+
+	builtin, err := fs.Glob(builtinPrompts, "prompts/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range builtin {
+		data, err := builtinPrompts.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.Parse(normalizeNewlines(string(data))); err != nil {
+			return nil, fmt.Errorf("failed to parse built-in prompt %s: %w", name, err)
+		}
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(cfg.Prompts.dir(), "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(overrides)
+	for _, path := range overrides {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.Parse(normalizeNewlines(string(data))); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", path, err)
+		}
+	}
+	return &Prompts{tmpl: tmpl}, nil
+}
+
+// Render は name のテンプレート（またはセクション）を data で展開します。
+func (p *Prompts) Render(name string, data interface{}) (string, error) {
+	t := p.tmpl.Lookup(name)
+	if t == nil {
+		return "", fmt.Errorf("prompt template %q not defined", name)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %q: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// DumpPrompt はモデルを呼び出さずに、stage（sql または program）の method 向けのプロンプトを返します。
+// stage が fake または conformance のときはインターフェース全体のプロンプトで、method は使いません。
+func DumpPrompt(stage string, infraFile string, method string) (string, error) {
+	switch stage {
+	case "sql":
+		g, err := newSQLGenerator(infraFile)
+		if err != nil {
+			return "", err
+		}
+		if !containsString(g.methods, method) {
+			return "", errUnknownMethod(method, g.methods)
+		}
+		return g.prompt(method)
+	case "program":
+		g, err := newProgramGenerator(infraFile)
+		if err != nil {
+			return "", err
+		}
+		if !containsString(g.methods, method) {
+			return "", errUnknownMethod(method, g.methods)
+		}
+		return g.prompt(method)
+	case "fake", "conformance":
+		g, err := newFakeGenerator(infraFile)
+		if err != nil {
+			return "", err
+		}
+		if stage == "conformance" {
+			// 生成済みのフェイクがあれば、それを元にした適合テストのプロンプトにする
+			if fake, err := os.ReadFile(fakeFilePath(infraFile)); err == nil {
+				g.data.Fake = string(fake)
+			}
+		}
+		return g.prompt(stage)
+	}
+	return "", fmt.Errorf("unknown stage %q (expected sql, program, fake or conformance)", stage)
+}
+
+func errUnknownMethod(method string, methods []string) error {
+	return fmt.Errorf("method %s not found in the interface (available: %s)", method, strings.Join(methods, ", "))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleProject は、プロンプトの組み立てを検証するための最小限のプロジェクトです。
+var sampleProject = map[string]string{
+	"go.mod": "module example.com/app\n\ngo 1.22\n",
+	"pkg/infra/user.go": `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/domain/entity"
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+	Save(ctx context.Context, user *entity.User) error
+}
+
+type UserRepositoryImpl struct {
+	Cache Cache
+}
+
+var _ UserRepository = UserRepositoryImpl{}
+`,
+	"pkg/infra/cache.go": `package infra
+
+import "time"
+
+type Cache interface {
+	Set(k string, x interface{}, d time.Duration)
+	Get(k string) (interface{}, bool)
+	Delete(k string)
+}
+`,
+	"pkg/infra/txProvider.go": `package infra
+
+func WithTx() {}
+`,
+	"pkg/domain/entity/user.go": `package entity
+
+type UserID int64
+
+type User struct {
+	ID   UserID
+	Name string
+}
+
+func NewUser(id UserID, name string) *User {
+	return &User{ID: id, Name: name}
+}
+`,
+	"pkg/infra/sql/schema/schema.sql": "CREATE TABLE users (id BIGINT PRIMARY KEY, name TEXT NOT NULL);\n",
+	"pkg/infra/sql/query/user.sql": `-- llm-sqlc:method FindByID
+-- name: GetUser :one
+SELECT * FROM users WHERE id = @id;
+
+-- llm-sqlc:method Save
+-- name: UpsertUser :exec
+INSERT INTO users (id, name) VALUES (@id, @name);
+`,
+	"pkg/infra/db/db.go": `package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type DBTX interface {
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+func New(db DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+type Queries struct {
+	db DBTX
+}
+`,
+	"pkg/infra/db/models.go": `package db
+
+type User struct {
+	ID   int64
+	Name string
+}
+`,
+	"pkg/infra/db/user.sql.go": `package db
+
+import "context"
+
+const getUser = ` + "`" + `SELECT id, name FROM users WHERE id = $1` + "`" + `
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
+	var i User
+	_ = getUser
+	return i, nil
+}
+
+const upsertUser = ` + "`" + `INSERT INTO users (id, name) VALUES ($1, $2)` + "`" + `
+
+type UpsertUserParams struct {
+	ID   int64
+	Name string
+}
+
+func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) error {
+	_ = upsertUser
+	return nil
+}
+`,
+}
+
+// setupSampleProject は sampleProject と extra を一時ディレクトリに作成し、そこへ移動します。
+func setupSampleProject(t *testing.T, extra map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, sampleProject)
+	writeProjectFiles(t, dir, extra)
+	chdir(t, dir)
+	return dir
+}
+
+func TestDumpPrompt(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		".llm-sqlc/prompts/notes.tmpl": "{{define \"sql.notes\"}}# Important Notes\r\nAlways qualify column names.\r\n{{end}}",
+	})
+
+	sqlPrompt, err := DumpPrompt("sql", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(sql) error: %v", err)
+	}
+	for _, want := range []string{"We want to implement FindByID for this interface.", "CREATE TABLE users", "## pkg/domain/entity/user.go", "Always qualify column names.\n"} {
+		if !strings.Contains(sqlPrompt, want) {
+			t.Errorf("expected SQL prompt to contain %q:\n%s", want, sqlPrompt)
+		}
+	}
+	if strings.Contains(sqlPrompt, "N+1 problem") {
+		t.Errorf("expected the overridden notes section to replace the built-in one")
+	}
+
+	programPrompt, err := DumpPrompt("program", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(program) error: %v", err)
+	}
+	for _, want := range []string{"Implement the FindByID function", "func (q *Queries) GetUser(", "query := db.New(tx)", "repo.Cache.Get(cacheKey)", `import path is "example.com/app/pkg/infra/db"`} {
+		if !strings.Contains(programPrompt, want) {
+			t.Errorf("expected program prompt to contain %q:\n%s", want, programPrompt)
+		}
+	}
+	if strings.Contains(programPrompt, "UpsertUser") {
+		t.Errorf("expected queries of other methods to be left out of the program prompt")
+	}
+
+	if _, err := DumpPrompt("program", "pkg/infra/user.go", "Delete"); err == nil {
+		t.Errorf("expected an error for a method that is not in the interface")
+	}
+}
+
+func TestSQLPromptEngine(t *testing.T) {
+	prompts, err := LoadPrompts(&Config{})
+	if err != nil {
+		t.Fatalf("LoadPrompts() error: %v", err)
+	}
+	for _, tt := range []struct {
+		engine string
+		want   []string
+	}{
+		{engine: "", want: []string{"We are using PostgreSQL as the DB.", "@set_name::bool", "ANY($1::int[])"}},
+		{engine: "postgresql", want: []string{"We are using PostgreSQL as the DB.", "ANY($1::int[])"}},
+		{engine: "mysql", want: []string{"We are using MySQL as the DB.", "sqlc.arg(id)", "IN (sqlc.slice(ids))"}},
+		{engine: "sqlite", want: []string{"We are using SQLite as the DB.", "COALESCE(sqlc.narg(name), name)", "IN (sqlc.slice(ids))"}},
+	} {
+		got, err := prompts.Render("sql.sqlc", &SQLPromptData{Engine: tt.engine})
+		if err != nil {
+			t.Fatalf("Render(sql.sqlc) error: %v", err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q: expected the sqlc section to contain %q:\n%s", tt.engine, want, got)
+			}
+		}
+		if tt.engine == "mysql" || tt.engine == "sqlite" {
+			if strings.Contains(got, "PostgreSQL as the DB") || strings.Contains(got, "::") || strings.Contains(got, "ANY(") {
+				t.Errorf("%q: expected no PostgreSQL-only syntax:\n%s", tt.engine, got)
+			}
+		}
+	}
+}
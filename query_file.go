@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// methodMarker は、生成したクエリがどのインターフェースメソッドのためのものかを
+// クエリファイルに残すためのコメントです。sqlc はこれを通常のコメントとして扱います。
+const methodMarker = "-- llm-sqlc:method "
+
+// reuseMarker は、メソッドが新しいクエリの代わりに使う既存のクエリ（別のクエリファイルにあるもの）を残すためのコメントです。
+const reuseMarker = "-- llm-sqlc:reuse "
+
+// NamedQuery は sqlc のクエリファイル中の名前付きクエリ1つ分です。
+type NamedQuery struct {
+	Name   string // クエリ名（例: GetUser）
+	Kind   string // :one, :many, :exec など
+	Method string // llm-sqlc が生成したクエリであれば、対応するインターフェースのメソッド名
+	SQL    string // "-- name:" コメントを含むクエリ全文
+}
+
+// queryFilePath は infraFile に対応するクエリファイルのパスを返します。
+// pkg/infra/sub/user.go であれば pkg/infra/sql/query/sub/user.sql になります。
+func queryFilePath(infraFile string) string {
+	infraBase := filepath.Join("pkg", "infra")
+	relSubPath, err := filepath.Rel(infraBase, filepath.Dir(infraFile))
+	if err != nil {
+		relSubPath = ""
+	}
+	baseName := filepath.Base(infraFile)
+	fileNameWithoutExt := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	return filepath.Join(infraBase, "sql", "query", relSubPath, fileNameWithoutExt+".sql")
+}
+
+// ParseQueryFile はクエリファイルを読み込み、名前付きクエリを出現順に返します。
+func ParseQueryFile(path string) ([]NamedQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseQueries(string(data)), nil
+}
+
+// ParseQueries は "-- name: Xxx :kind" コメントでクエリを区切り、
+// 直前の methodMarker が示すメソッド名とともに返します。
+func ParseQueries(src string) []NamedQuery {
+	var queries []NamedQuery
+	var current *NamedQuery
+	var body []string
+	method := ""
+
+	flush := func() {
+		if current != nil {
+			current.SQL = strings.TrimSpace(strings.Join(body, "\n"))
+			queries = append(queries, *current)
+		}
+		current = nil
+		body = nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, methodMarker) {
+			flush()
+			method = strings.TrimSpace(strings.TrimPrefix(trimmed, methodMarker))
+			continue
+		}
+		if strings.HasPrefix(trimmed, reuseMarker) {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(trimmed, "-- name:") {
+			flush()
+			fields := strings.Fields(strings.TrimPrefix(trimmed, "-- name:"))
+			current = &NamedQuery{Method: method}
+			if len(fields) > 0 {
+				current.Name = fields[0]
+			}
+			if len(fields) > 1 {
+				current.Kind = fields[1]
+			}
+		}
+		if current != nil {
+			body = append(body, line)
+		}
+	}
+	flush()
+	return queries
+}
+
+// queriesByMethod はクエリをメソッド名ごとにまとめ、クエリ名の一覧を返します。
+func queriesByMethod(queries []NamedQuery) map[string][]string {
+	result := make(map[string][]string)
+	for _, q := range queries {
+		if q.Method == "" {
+			continue
+		}
+		result[q.Method] = append(result[q.Method], q.Name)
+	}
+	return result
+}
+
+// reusedQueryNames は src の reuseMarker が示す既存のクエリ名を、重複なく出現順に返します。
+func reusedQueryNames(src string) []string {
+	var names []string
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, reuseMarker) {
+			continue
+		}
+		for _, name := range strings.Fields(strings.TrimPrefix(trimmed, reuseMarker)) {
+			if !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// methodBlocks はクエリファイルを methodMarker で区切り、メソッド名ごとに続く内容（reuseMarker を含む）を返します。
+func methodBlocks(src string) map[string]string {
+	blocks := make(map[string]string)
+	method := ""
+	var body []string
+	flush := func() {
+		if method != "" {
+			blocks[method] = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, methodMarker) {
+			flush()
+			method = strings.TrimSpace(strings.TrimPrefix(trimmed, methodMarker))
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return blocks
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestParseQueries(t *testing.T) {
+	src := `-- llm-sqlc:method FindByID
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = @id;
+
+-- llm-sqlc:method Save
+-- name: CreateUser :exec
+INSERT INTO users (id, name) VALUES (@id, @name);
+
+-- name: CreateUserProfile :exec
+INSERT INTO profiles (user_id) VALUES (@user_id);
+`
+	queries := ParseQueries(src)
+	expected := []NamedQuery{
+		{Name: "GetUser", Kind: ":one", Method: "FindByID"},
+		{Name: "CreateUser", Kind: ":exec", Method: "Save"},
+		{Name: "CreateUserProfile", Kind: ":exec", Method: "Save"},
+	}
+	if len(queries) != len(expected) {
+		t.Fatalf("expected %d queries, got %d", len(expected), len(queries))
+	}
+	for i, want := range expected {
+		got := queries[i]
+		if got.Name != want.Name || got.Kind != want.Kind || got.Method != want.Method {
+			t.Errorf("query %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if queries[0].SQL != "-- name: GetUser :one\nSELECT * FROM users\nWHERE id = @id;" {
+		t.Errorf("unexpected SQL for GetUser: %q", queries[0].SQL)
+	}
+
+	byMethod := queriesByMethod(queries)
+	if len(byMethod["Save"]) != 2 || byMethod["Save"][1] != "CreateUserProfile" {
+		t.Errorf("unexpected queries for Save: %v", byMethod["Save"])
+	}
+}
+
+func TestReuseMarker(t *testing.T) {
+	src := `-- llm-sqlc:method FindByID
+-- llm-sqlc:reuse GetUser
+
+-- llm-sqlc:method Save
+-- llm-sqlc:reuse GetUser CreateAccount
+-- name: CreateUser :exec
+INSERT INTO users (id, name) VALUES (@id, @name);
+`
+	queries := ParseQueries(src)
+	if len(queries) != 1 || queries[0].Name != "CreateUser" || queries[0].Method != "Save" {
+		t.Fatalf("expected only CreateUser to be parsed as a query, got %+v", queries)
+	}
+
+	blocks := methodBlocks(src)
+	if blocks["FindByID"] != "-- llm-sqlc:reuse GetUser" {
+		t.Errorf("unexpected block for FindByID: %q", blocks["FindByID"])
+	}
+	if got := reusedQueryNames(blocks["Save"]); len(got) != 2 || got[0] != "GetUser" || got[1] != "CreateAccount" {
+		t.Errorf("unexpected reused queries of Save: %v", got)
+	}
+	if got := reusedQueryNames(src); len(got) != 2 {
+		t.Errorf("expected reused names without duplicates, got %v", got)
+	}
+}
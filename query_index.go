@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultQueryDir は sqlc.yml がない場合に既存のクエリを探すディレクトリです。
+var defaultQueryDir = filepath.Join("pkg", "infra", "sql", "query")
+
+// ResolveQueryPaths は infraFile に対応する sqlc.yml の sql ブロックから、queries に指定されたパスを
+// プロジェクトルートからの相対パスで返します。sqlc.yml がなければ defaultQueryDir を返します。
+// 同じブロックのクエリは同じパッケージに生成されるので、生成するメソッドからそのまま呼び出せます。
+func ResolveQueryPaths(cfg *Config, infraFile string) ([]string, error) {
+	sqlcConfig, err := loadSQLCConfig()
+	if err != nil {
+		return []string{defaultQueryDir}, nil
+	}
+	configDir := filepath.Dir(sqlcConfigPath)
+	index, err := selectSQLCBlock(sqlcConfig, configDir, infraFile, cfg.SQLCPackages)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range sqlcConfig.SQL[index].Queries {
+		paths = append(paths, filepath.Join(configDir, p))
+	}
+	return paths, nil
+}
+
+// IndexedQuery は既存のクエリファイルにある名前付きクエリと、そのファイルです。
+type IndexedQuery struct {
+	NamedQuery
+	File string // プロジェクトルートからの相対パス（/ 区切り）
+}
+
+// QueryIndex は sqlc のパッケージにある既存の名前付きクエリの一覧です。
+type QueryIndex struct {
+	Queries []IndexedQuery // ファイル名順、ファイル内では出現順
+}
+
+// LoadQueryIndex は paths のファイル（ディレクトリなら直下の .sql ファイル）から名前付きクエリを集めます。
+// sqlc と同じく、サブディレクトリと . で始まるファイルは見ません。存在しないパスは無視します。
+func LoadQueryIndex(paths []string) (*QueryIndex, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".sql") {
+				continue
+			}
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	sort.Strings(files)
+
+	index := &QueryIndex{}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.ToSlash(filepath.Clean(file))
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		queries, err := ParseQueryFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read query file %s: %w", file, err)
+		}
+		for _, q := range queries {
+			if q.Name != "" {
+				index.Queries = append(index.Queries, IndexedQuery{NamedQuery: q, File: file})
+			}
+		}
+	}
+	return index, nil
+}
+
+// Lookup は name という名前のクエリを、exclude（/ 区切りのパス）以外のファイルから探します。
+func (x *QueryIndex) Lookup(name string, exclude string) (IndexedQuery, bool) {
+	if x == nil {
+		return IndexedQuery{}, false
+	}
+	for _, q := range x.Queries {
+		if q.Name == name && q.File != exclude {
+			return q, true
+		}
+	}
+	return IndexedQuery{}, false
+}
+
+// Names は exclude 以外のファイルにあるクエリ名と、そのファイルを返します。
+func (x *QueryIndex) Names(exclude string) map[string]string {
+	names := make(map[string]string)
+	if x == nil {
+		return names
+	}
+	for _, q := range x.Queries {
+		if _, ok := names[q.Name]; !ok && q.File != exclude {
+			names[q.Name] = q.File
+		}
+	}
+	return names
+}
+
+// QueryDuplicate は複数の場所で定義されているクエリ名です。
+type QueryDuplicate struct {
+	Name  string
+	Files []string // 定義されているファイル（同じファイルに2回あれば2回並ぶ）
+}
+
+// Duplicates は2回以上定義されているクエリ名を名前順に返します。sqlc は同じパッケージで名前が重なるとコードを生成できません。
+func (x *QueryIndex) Duplicates() []QueryDuplicate {
+	if x == nil {
+		return nil
+	}
+	files := make(map[string][]string)
+	for _, q := range x.Queries {
+		files[q.Name] = append(files[q.Name], q.File)
+	}
+	var duplicates []QueryDuplicate
+	for name, where := range files {
+		if len(where) > 1 {
+			duplicates = append(duplicates, QueryDuplicate{Name: name, Files: where})
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Name < duplicates[j].Name })
+	return duplicates
+}
+
+// Candidates は、tables のいずれかを参照する exclude 以外のファイルのクエリを返します。
+// tables が空（関係するテーブルが分からない）なら、exclude 以外のすべてのクエリを返します。
+func (x *QueryIndex) Candidates(tables map[string]bool, exclude string) []IndexedQuery {
+	if x == nil {
+		return nil
+	}
+	var candidates []IndexedQuery
+	for _, q := range x.Queries {
+		if q.File == exclude {
+			continue
+		}
+		if len(tables) == 0 {
+			candidates = append(candidates, q)
+			continue
+		}
+		for _, ref := range referencedTables(stripSQLComments(q.SQL)) {
+			if tables[ref.Name] {
+				candidates = append(candidates, q)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+// ReuseSQLGuidance は既存のクエリを再利用の候補として示す説明を返します。候補がなければ空です。
+func ReuseSQLGuidance(candidates []IndexedQuery) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The following named queries already exist in the same sqlc package and can be called from the implementation.\n")
+	b.WriteString("If one of them does exactly what the function needs, do not write a new query for it: put its name in \"reuse\" instead.\n")
+	b.WriteString("Write new queries only for what none of them covers, and do not give a new query the name of an existing one.\n")
+	file := ""
+	for _, q := range candidates {
+		if q.File != file {
+			if file != "" {
+				b.WriteString("```\n")
+			}
+			file = q.File
+			fmt.Fprintf(&b, "## %s\n```sql\n", file)
+		}
+		b.WriteString(q.SQL + "\n")
+	}
+	b.WriteString("```\n")
+	return b.String()
+}
+
+// checkDuplicateName は、ほかのクエリファイルにすでにある名前を付けたクエリを報告します。sqlc は同じパッケージで名前が重なるとコードを生成できません。
+func checkDuplicateName(q NamedQuery, c *SQLCheckContext) []string {
+	if file, ok := c.Existing[q.Name]; ok {
+		return []string{"the name is already used by a query in " + file + "; reuse that query or choose another name"}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueryIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"query/user.sql": `-- llm-sqlc:method FindByID
+-- name: GetUser :one
+SELECT * FROM users WHERE id = @id;
+`,
+		"query/post.sql": `-- name: ListPosts :many
+SELECT * FROM posts WHERE author_id = @author_id;
+
+-- name: GetUser :one
+SELECT u.* FROM users u JOIN posts p ON p.author_id = u.id WHERE p.id = @post_id;
+`,
+		"query/notes.txt":       "-- name: Ignored :one\nSELECT 1;\n",
+		"query/.draft.sql":      "-- name: Draft :one\nSELECT 1;\n",
+		"query/archive/old.sql": "-- name: Archived :one\nSELECT 1;\n",
+	})
+
+	index, err := LoadQueryIndex([]string{filepath.Join(dir, "query"), filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("LoadQueryIndex() error: %v", err)
+	}
+	if len(index.Queries) != 3 {
+		t.Fatalf("expected 3 queries from the .sql files directly in the directory, got %+v", index.Queries)
+	}
+	postFile := filepath.ToSlash(filepath.Join(dir, "query", "post.sql"))
+	userFile := filepath.ToSlash(filepath.Join(dir, "query", "user.sql"))
+
+	duplicates := index.Duplicates()
+	if len(duplicates) != 1 || duplicates[0].Name != "GetUser" || len(duplicates[0].Files) != 2 {
+		t.Errorf("expected GetUser to be reported as a duplicate, got %+v", duplicates)
+	}
+
+	if q, ok := index.Lookup("GetUser", userFile); !ok || q.File != postFile || sqlVerb(q.SQL) != "SELECT" {
+		t.Errorf("expected GetUser of post.sql when user.sql is excluded, got %+v (%v)", q, ok)
+	}
+	if _, ok := index.Lookup("ListPosts", postFile); ok {
+		t.Errorf("expected queries of the excluded file not to be found")
+	}
+	if names := index.Names(userFile); names["GetUser"] != postFile || names["ListPosts"] != postFile || len(names) != 2 {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	candidates := index.Candidates(map[string]bool{"users": true}, userFile)
+	if len(candidates) != 1 || candidates[0].Name != "GetUser" {
+		t.Errorf("expected only the query that references users, got %+v", candidates)
+	}
+	if candidates := index.Candidates(nil, userFile); len(candidates) != 2 {
+		t.Errorf("expected every query of the other files without tables, got %+v", candidates)
+	}
+
+	guidance := ReuseSQLGuidance(index.Candidates(nil, userFile))
+	for _, want := range []string{`put its name in "reuse"`, "## " + postFile + "\n```sql\n-- name: ListPosts :many"} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("expected guidance to contain %q:\n%s", want, guidance)
+		}
+	}
+	if ReuseSQLGuidance(nil) != "" {
+		t.Errorf("expected no guidance without candidates")
+	}
+}
+
+func TestLintQueriesExistingNames(t *testing.T) {
+	src := `-- llm-sqlc:reuse GetUser ListComments
+-- name: ListPosts :many
+SELECT id FROM posts WHERE author_id = @author_id;
+`
+	c := &SQLCheckContext{Existing: map[string]string{"GetUser": "query/user.sql", "ListPosts": "query/post.sql"}}
+	findings := LintQueries("query/feed.sql", 3, "Feed", src, c)
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule+" "+strings.SplitN(f.Message, ":", 2)[0])
+	}
+	if strings.Join(rules, ", ") != "sql-duplicate-name ListPosts, sql-reuse ListComments" {
+		t.Errorf("unexpected findings: %v", findings)
+	}
+
+	if findings := LintQueries("query/feed.sql", 3, "Feed", src, &SQLCheckContext{}); len(findings) != 0 {
+		t.Errorf("expected no findings without existing names, got %v", findings)
+	}
+}
+
+func TestReuseInPrompt(t *testing.T) {
+	setupSampleProject(t, map[string]string{
+		"pkg/infra/sql/query/account.sql": `-- name: GetUserByName :one
+SELECT id, name FROM users WHERE name = @name;
+
+-- name: ListOrders :many
+SELECT id FROM orders;
+`,
+	})
+
+	sqlPrompt, err := DumpPrompt("sql", "pkg/infra/user.go", "FindByID")
+	if err != nil {
+		t.Fatalf("DumpPrompt(sql) error: %v", err)
+	}
+	for _, want := range []string{"# Existing Queries", "## pkg/infra/sql/query/account.sql", "-- name: GetUserByName :one", `array named "reuse"`} {
+		if !strings.Contains(sqlPrompt, want) {
+			t.Errorf("expected SQL prompt to contain %q:\n%s", want, sqlPrompt)
+		}
+	}
+	// 自分のクエリファイルは書き直すので候補にしない。関係のないテーブルのクエリも載せない
+	for _, unwanted := range []string{"-- name: GetUser :one", "ListOrders"} {
+		if strings.Contains(sqlPrompt, unwanted) {
+			t.Errorf("expected SQL prompt not to contain %q:\n%s", unwanted, sqlPrompt)
+		}
+	}
+}
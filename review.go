@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// reviewer は --interactive のとき、生成されたメソッドを書き込む前に1つずつ確認させます。nil なら確認せずにすべて採用します。
+var reviewer *Reviewer
+
+// Reviewer は生成されたメソッドと現在のファイルとの差分を見せ、採用・却下・編集・再生成を尋ねます。
+type Reviewer struct {
+	in     *bufio.Reader
+	out    io.Writer
+	editor func(path string) error // path をエディタで開き、閉じられるまで待つ
+}
+
+// NewReviewer は in から回答を読み、out に差分と質問を書く Reviewer を作ります。
+func NewReviewer(in io.Reader, out io.Writer) *Reviewer {
+	return &Reviewer{in: bufio.NewReader(in), out: out, editor: openEditor}
+}
+
+// openEditor は $EDITOR（なければ vi）で path を開きます。
+func openEditor(path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// ReviewItem は確認するメソッド1つ分です。
+type ReviewItem struct {
+	Method    string
+	Index     int    // 1 から数えた位置
+	Total     int    // 確認するメソッドの数
+	Ext       string // エディタで開くファイルの拡張子（.sql, .go）
+	Current   string // 現在のファイルにある内容（なければ空）
+	Generated string
+	// Check は内容に対するチェックの指摘を返します（nil なら表示しない）。
+	Check func(content string) []string
+}
+
+// errReviewAborted は回答が得られない（入力が閉じられた）ために確認を続けられないことを表します。
+var errReviewAborted = errors.New("review aborted")
+
+// Review は item の差分を表示し、どうするかを尋ねます。
+// 採用（編集したものを含む）なら内容と true を、却下なら Current と false を返します。
+// regenerate は追加の指示を受け取り、生成し直した内容を返します。
+func (r *Reviewer) Review(ctx context.Context, item ReviewItem, regenerate func(ctx context.Context, instruction string) (string, error)) (string, bool, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", false, err
+		}
+		fmt.Fprintf(r.out, "\n=== %s (%d/%d) ===\n", item.Method, item.Index, item.Total)
+		fmt.Fprint(r.out, unifiedDiff(item.Current, item.Generated))
+		if item.Check != nil {
+			for _, note := range item.Check(item.Generated) {
+				fmt.Fprintf(r.out, "! %s\n", note)
+			}
+		}
+		answer, err := r.ask("[a]ccept, [r]eject, [e]dit, re[g]enerate? ")
+		if err != nil {
+			return "", false, err
+		}
+		switch strings.ToLower(answer) {
+		case "a", "accept":
+			return item.Generated, true, nil
+		case "r", "reject":
+			return item.Current, false, nil
+		case "e", "edit":
+			edited, err := r.edit(item.Generated, item.Ext)
+			if err != nil {
+				fmt.Fprintf(r.out, "could not edit: %v\n", err)
+				continue
+			}
+			item.Generated = edited
+		case "g", "regenerate":
+			instruction, err := r.ask("instruction (empty to regenerate as is): ")
+			if err != nil {
+				return "", false, err
+			}
+			generated, err := regenerate(ctx, instruction)
+			if err != nil {
+				return "", false, err
+			}
+			item.Generated = generated
+		default:
+			fmt.Fprintln(r.out, "please answer a, r, e or g")
+		}
+	}
+}
+
+// ask は質問を表示し、1行の回答を返します。
+func (r *Reviewer) ask(question string) (string, error) {
+	fmt.Fprint(r.out, question)
+	line, err := r.in.ReadString('\n')
+	if err != nil && (line == "" || err != io.EOF) {
+		return "", errReviewAborted
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// edit は content を一時ファイルに書き、エディタで編集された内容を返します。
+func (r *Reviewer) edit(content string, ext string) (string, error) {
+	f, err := os.CreateTemp("", "llm-sqlc-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := r.editor(f.Name()); err != nil {
+		return "", err
+	}
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(edited), "\n"), nil
+}
+
+// diffContext は差分で変更行の前後に表示する行数です。
+const diffContext = 3
+
+// unifiedDiff は current から generated への行単位の差分を unified 形式で返します。
+func unifiedDiff(current, generated string) string {
+	if current == generated {
+		return "(no changes)\n"
+	}
+	a, b := splitLines(current), splitLines(generated)
+	// lcs[i][j] は a[i:] と b[j:] の最長共通部分列の長さ
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			// 同じ長さなら削除を先に出す
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	// 変更行の前後 diffContext 行だけを残し、離れた箇所は @@ で区切る
+	keep := make([]bool, len(lines))
+	for k, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		for c := max(0, k-diffContext); c <= min(len(lines)-1, k+diffContext); c++ {
+			keep[c] = true
+		}
+	}
+	var out strings.Builder
+	out.WriteString("--- current\n+++ generated\n")
+	for k, line := range lines {
+		if !keep[k] {
+			continue
+		}
+		if k == 0 || !keep[k-1] {
+			out.WriteString("@@\n")
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
+
+// currentQueries は現在のクエリファイルにある、メソッドごとのクエリ（既存のクエリを使う reuseMarker を含む）を返します。
+// ファイルがなければ空です。
+func currentQueries(queryFile string) map[string]string {
+	data, err := os.ReadFile(queryFile)
+	if err != nil {
+		return make(map[string]string)
+	}
+	return methodBlocks(string(data))
+}
+
+// source はドキュメントコメントを含めた関数のソースを返します。
+func (r *GenerationResponse) source() string {
+	if r == nil {
+		return ""
+	}
+	if doc := strings.TrimSpace(r.DocComment); doc != "" {
+		return doc + "\n" + strings.TrimSpace(r.Code)
+	}
+	return strings.TrimSpace(r.Code)
+}
+
+// editedResponse は、エディタで編集されたメソッドのソースを生成結果の形に戻します。
+// 編集で先頭に import 宣言が書き足されていれば、その import を base の import に加えてコードから取り除きます。
+// そうしないと、組み立てたファイルに import がなくビルドできません。
+func editedResponse(code string, base *GenerationResponse) *GenerationResponse {
+	baseImport := ""
+	if base != nil {
+		baseImport = base.Import
+	}
+	src := "package p\n\n" + code
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil || len(f.Imports) == 0 {
+		return &GenerationResponse{Code: code, Import: baseImport}
+	}
+	text := func(from, to token.Pos) string {
+		return src[fset.Position(from).Offset:fset.Position(to).Offset]
+	}
+
+	var merged strings.Builder
+	merged.WriteString("import (\n")
+	inner := strings.TrimSpace(baseImport)
+	inner = strings.TrimSuffix(strings.TrimPrefix(inner, "import ("), ")")
+	for _, line := range strings.Split(inner, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			merged.WriteString("\t" + line + "\n")
+		}
+	}
+	for _, spec := range f.Imports {
+		merged.WriteString("\t" + text(spec.Pos(), spec.End()) + "\n")
+	}
+	merged.WriteString(")")
+
+	// 最後の import 宣言より後ろがメソッドのコード（ドキュメントコメントを含む）
+	var last token.Pos
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			last = gen.End()
+		}
+	}
+	return &GenerationResponse{Code: strings.TrimSpace(src[fset.Position(last).Offset:]), Import: merged.String()}
+}
+
+// currentMethods は現在の infra ファイルにあるメソッドの実装を、生成結果と同じ形で返します。
+// Import にはファイルの import 宣言をまとめて入れるので、却下したメソッドもそのまま組み立て直せます。
+func currentMethods(infraFile string) map[string]*GenerationResponse {
+	result := make(map[string]*GenerationResponse)
+	src, err := os.ReadFile(infraFile)
+	if err != nil {
+		return result
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, infraFile, src, parser.ParseComments)
+	if err != nil {
+		return result
+	}
+	text := func(from, to token.Pos) string {
+		return string(src[fset.Position(from).Offset:fset.Position(to).Offset])
+	}
+	var imports strings.Builder
+	imports.WriteString("import (\n")
+	for _, spec := range f.Imports {
+		imports.WriteString("\t" + text(spec.Pos(), spec.End()) + "\n")
+	}
+	imports.WriteString(")")
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil {
+			continue
+		}
+		m := &GenerationResponse{Code: text(fn.Pos(), fn.End()), Import: imports.String()}
+		if fn.Doc != nil {
+			m.DocComment = text(fn.Doc.Pos(), fn.Doc.End())
+		}
+		result[fn.Name.Name] = m
+	}
+	return result
+}
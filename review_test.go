@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	current := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	generated := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n12\n13"
+	want := `--- current
++++ generated
+@@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+@@
+ 10
+ 11
+ 12
++13
+`
+	if got := unifiedDiff(current, generated); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("x", "x"); got != "(no changes)\n" {
+		t.Errorf("unexpected diff for identical content: %q", got)
+	}
+	if got := unifiedDiff("", "-- name: GetUser :one"); !strings.HasSuffix(got, "@@\n+-- name: GetUser :one\n") {
+		t.Errorf("unexpected diff for new content:\n%s", got)
+	}
+}
+
+func TestReviewerReview(t *testing.T) {
+	var out strings.Builder
+	r := NewReviewer(strings.NewReader("x\ng\nuse a CTE\ne\na\n"), &out)
+	r.editor = func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(path) != ".sql" {
+			t.Errorf("expected a .sql file, got %s", path)
+		}
+		return os.WriteFile(path, append(data, []byte("\n-- edited\n")...), 0644)
+	}
+	var instructions []string
+	item := ReviewItem{
+		Method: "FindByID", Index: 1, Total: 2, Ext: ".sql",
+		Current:   "SELECT 1;",
+		Generated: "SELECT 2;",
+		Check: func(content string) []string {
+			return []string{"checked " + content}
+		},
+	}
+	content, accepted, err := r.Review(context.Background(), item, func(ctx context.Context, instruction string) (string, error) {
+		instructions = append(instructions, instruction)
+		return "SELECT 3;", nil
+	})
+	if err != nil {
+		t.Fatalf("Review error: %v", err)
+	}
+	if !accepted || content != "SELECT 3;\n-- edited" {
+		t.Errorf("Review = %q, %v", content, accepted)
+	}
+	if strings.Join(instructions, ",") != "use a CTE" {
+		t.Errorf("instructions = %v", instructions)
+	}
+	for _, want := range []string{"=== FindByID (1/2) ===", "-SELECT 1;\n+SELECT 2;", "please answer a, r, e or g", "! checked SELECT 3;"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	r = NewReviewer(strings.NewReader("r\n"), &out)
+	if content, accepted, err := r.Review(context.Background(), item, nil); err != nil || accepted || content != "SELECT 1;" {
+		t.Errorf("reject = %q, %v, %v", content, accepted, err)
+	}
+	r = NewReviewer(strings.NewReader(""), &out)
+	if _, _, err := r.Review(context.Background(), item, nil); !errors.Is(err, errReviewAborted) {
+		t.Errorf("expected errReviewAborted at EOF, got %v", err)
+	}
+}
+
+func TestCurrentMethods(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.go")
+	src := `package infra
+
+import (
+	"context"
+
+	db "example.com/app/pkg/infra/db"
+)
+
+type UserRepositoryImpl struct{}
+
+// FindByID はユーザーを取得します。
+func (repo *UserRepositoryImpl) FindByID(ctx context.Context) error {
+	_ = db.New
+	return nil
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	methods := currentMethods(path)
+	m := methods["FindByID"]
+	if m == nil {
+		t.Fatalf("FindByID not found: %v", methods)
+	}
+	if m.Import != "import (\n\t\"context\"\n\tdb \"example.com/app/pkg/infra/db\"\n)" {
+		t.Errorf("unexpected imports: %q", m.Import)
+	}
+	if !strings.HasPrefix(m.source(), "// FindByID はユーザーを取得します。\nfunc (repo *UserRepositoryImpl) FindByID(") {
+		t.Errorf("unexpected source: %q", m.source())
+	}
+}
+
+func TestEditedResponse(t *testing.T) {
+	base := &GenerationResponse{Code: "func (repo *UserRepositoryImpl) FindByID() {}", Import: "import (\n\t\"context\"\n)"}
+
+	edited := editedResponse(`import "strings"
+
+// FindByID はユーザーを取得します。
+func (repo *UserRepositoryImpl) FindByID() { _ = strings.ToLower }`, base)
+	if edited.Import != "import (\n\t\"context\"\n\t\"strings\"\n)" {
+		t.Errorf("expected the added import to be merged, got %q", edited.Import)
+	}
+	if !strings.HasPrefix(edited.Code, "// FindByID はユーザーを取得します。\nfunc (repo *UserRepositoryImpl) FindByID()") {
+		t.Errorf("expected the import to be removed from the code, got %q", edited.Code)
+	}
+
+	// import を書き足していなければ、元の import をそのまま使う
+	code := "func (repo *UserRepositoryImpl) FindByID() {}"
+	if edited := editedResponse(code, base); edited.Code != code || edited.Import != base.Import {
+		t.Errorf("unexpected response: %+v", edited)
+	}
+}
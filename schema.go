@@ -0,0 +1,512 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Schema は schema.sql を解析した結果です。テーブル・enum・ビューと外部キーを持ち、
+// メソッドに関係するテーブルだけをプロンプトに載せるために使います。
+type Schema struct {
+	Tables     []*SchemaTable
+	Enums      []*SchemaEnum
+	Views      []*SchemaView
+	statements []schemaStatement // 元の順序の文（コメントを含む）
+}
+
+// SchemaTable はテーブル1つ分です。
+type SchemaTable struct {
+	Name        string
+	Columns     []SchemaColumn
+	ForeignKeys []SchemaForeignKey
+}
+
+// SchemaColumn はテーブルの列1つ分です。
+type SchemaColumn struct {
+	Name    string
+	Type    string // 小文字にした型名（例: bigint, text, user_status[]）
+	NotNull bool
+}
+
+// SchemaForeignKey は外部キー1つ分です。
+type SchemaForeignKey struct {
+	Columns  []string
+	RefTable string
+}
+
+// SchemaEnum は CREATE TYPE ... AS ENUM で定義された型です。
+type SchemaEnum struct {
+	Name   string
+	Values []string
+}
+
+// SchemaView はビュー（マテリアライズドビューを含む）です。
+type SchemaView struct {
+	Name   string
+	Tables []string // FROM / JOIN で参照しているテーブル・ビュー
+}
+
+// schemaStatement はスキーマの文1つ分と、その文がどのオブジェクトに属するかです。
+// インデックスや ALTER TABLE はそのテーブルに属し、属するものがない文は kind が空です。
+type schemaStatement struct {
+	sql    string
+	kind   string // table, enum, view または空
+	object string
+	index  string // CREATE INDEX の場合はインデックス名
+}
+
+var (
+	schemaCreateTablePattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)\s*\((.*)\)`)
+	schemaCreateEnumPattern  = regexp.MustCompile(`(?is)^\s*CREATE\s+TYPE\s+([\w."]+)\s+AS\s+ENUM\s*\((.*)\)`)
+	schemaCreateViewPattern  = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:MATERIALIZED\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`)
+	schemaIndexPattern       = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?INDEX\b.*?\bON\s+(?:ONLY\s+)?([\w."]+)`)
+	schemaAlterTablePattern  = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)`)
+	schemaCommentOnPattern   = regexp.MustCompile(`(?is)^\s*COMMENT\s+ON\s+(TABLE|COLUMN)\s+([\w."]+)`)
+	schemaReferencesPattern  = regexp.MustCompile(`(?is)\bREFERENCES\s+([\w."]+)`)
+	schemaForeignKeyPattern  = regexp.MustCompile(`(?is)FOREIGN\s+KEY\s*\(([^)]*)\)\s*REFERENCES\s+([\w."]+)`)
+	schemaFromJoinPattern    = regexp.MustCompile(`(?is)\b(?:FROM|JOIN)\s+([\w."]+)`)
+	schemaEnumValuePattern   = regexp.MustCompile(`'((?:[^']|'')*)'`)
+	schemaIndexNamePattern   = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)\s+ON\b`)
+	schemaDropPattern        = regexp.MustCompile(`(?is)^\s*DROP\s+(TABLE|MATERIALIZED\s+VIEW|VIEW|TYPE|INDEX)\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?([\w.",\s]+?)\s*(?:CASCADE|RESTRICT)?\s*;?\s*$`)
+	schemaRenameTablePattern = regexp.MustCompile(`(?is)^RENAME\s+TO\s+([\w."]+)$`)
+	schemaRenameColPattern   = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?([\w"]+)\s+TO\s+([\w"]+)$`)
+	schemaAddColumnPattern   = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(.+)$`)
+	schemaDropColumnPattern  = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?([\w"]+)`)
+	schemaAlterColumnPattern = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?([\w"]+)\s+(SET|DROP)\s+NOT\s+NULL`)
+)
+
+// schemaObjectName は "public"."Users" のような名前を、比較に使う users の形にします。
+func schemaObjectName(name string) string {
+	name = strings.ReplaceAll(name, `"`, "")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// singularForms は、小文字にして _ を取り除いた name と、複数形の語尾を取り除いた単数形の候補です。
+// Address のように s で終わる単数形もあるので、1つに決めずに候補をすべて返します。
+func singularForms(name string) []string {
+	n := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	forms := []string{n}
+	if strings.HasSuffix(n, "ies") {
+		forms = append(forms, strings.TrimSuffix(n, "ies")+"y")
+	}
+	if strings.HasSuffix(n, "es") {
+		forms = append(forms, strings.TrimSuffix(n, "es"))
+	}
+	if strings.HasSuffix(n, "s") {
+		forms = append(forms, strings.TrimSuffix(n, "s"))
+	}
+	return forms
+}
+
+// tableMatchesType は、テーブル名と型名の一方が、もう一方そのものか、その単数形であるかを返します。
+// 例: addresses と Address、statuses と Status、users と User、categories と Category
+func tableMatchesType(table, typeName string) bool {
+	t := singularForms(table)
+	n := singularForms(typeName)
+	return containsString(t, n[0]) || containsString(n, t[0])
+}
+
+// ParseSchema はスキーマの SQL を文ごとに分けて解析します。解釈できない文はそのまま残します。
+func ParseSchema(sql string) *Schema {
+	s := &Schema{}
+	s.Apply(sql)
+	return s
+}
+
+// Apply は sql の文を順に適用します。マイグレーションを古い順に適用して、最終的なスキーマを得るのに使います。
+// DROP したオブジェクトはその定義ごと取り除き、ALTER TABLE による列の追加・削除・名前の変更はテーブルの列に反映します。
+func (s *Schema) Apply(sql string) {
+	for _, stmt := range splitSQLStatements(sql) {
+		s.addStatement(stmt)
+	}
+}
+
+func (s *Schema) addStatement(stmt string) {
+	code := stripSQLComments(stmt)
+	st := schemaStatement{sql: stmt}
+	switch {
+	case schemaCreateTablePattern.MatchString(code):
+		m := schemaCreateTablePattern.FindStringSubmatch(code)
+		table := &SchemaTable{Name: schemaObjectName(m[1])}
+		for _, item := range splitTopLevel(m[2]) {
+			table.addDefinition(item)
+		}
+		s.Tables = append(s.Tables, table)
+		st.kind, st.object = "table", table.Name
+	case schemaCreateEnumPattern.MatchString(code):
+		// 値は文字列リテラルなので、コメントだけを取り除いたものから読む
+		raw := sqlLineCommentPattern.ReplaceAllString(sqlBlockCommentPattern.ReplaceAllString(stmt, " "), " ")
+		m := schemaCreateEnumPattern.FindStringSubmatch(raw)
+		enum := &SchemaEnum{Name: schemaObjectName(m[1])}
+		for _, v := range schemaEnumValuePattern.FindAllStringSubmatch(m[2], -1) {
+			enum.Values = append(enum.Values, strings.ReplaceAll(v[1], "''", "'"))
+		}
+		s.Enums = append(s.Enums, enum)
+		st.kind, st.object = "enum", enum.Name
+	case schemaCreateViewPattern.MatchString(code):
+		view := &SchemaView{Name: schemaObjectName(schemaCreateViewPattern.FindStringSubmatch(code)[1])}
+		for _, m := range schemaFromJoinPattern.FindAllStringSubmatch(code, -1) {
+			if name := schemaObjectName(m[1]); !containsString(view.Tables, name) {
+				view.Tables = append(view.Tables, name)
+			}
+		}
+		s.Views = append(s.Views, view)
+		st.kind, st.object = "view", view.Name
+	case schemaIndexPattern.MatchString(code):
+		st.kind, st.object = "table", schemaObjectName(schemaIndexPattern.FindStringSubmatch(code)[1])
+		if m := schemaIndexNamePattern.FindStringSubmatch(code); m != nil {
+			st.index = schemaObjectName(m[1])
+		}
+	case schemaDropPattern.MatchString(code):
+		m := schemaDropPattern.FindStringSubmatch(code)
+		for _, name := range strings.Split(m[2], ",") {
+			s.drop(strings.ToUpper(strings.Fields(m[1])[0]), schemaObjectName(strings.TrimSpace(name)))
+		}
+		return
+	case schemaAlterTablePattern.MatchString(code):
+		m := schemaAlterTablePattern.FindStringSubmatch(code)
+		name := schemaObjectName(m[1])
+		st.kind, st.object = "table", name
+		if table := s.Table(name); table != nil {
+			actions := strings.TrimSuffix(strings.TrimSpace(code[len(m[0]):]), ";")
+			for _, action := range splitTopLevel(actions) {
+				if newName := table.alter(action); newName != "" {
+					s.renameTable(table, newName)
+					st.object = newName
+				}
+			}
+		}
+	case schemaCommentOnPattern.MatchString(code):
+		m := schemaCommentOnPattern.FindStringSubmatch(code)
+		name := m[2]
+		if strings.EqualFold(m[1], "COLUMN") {
+			// COMMENT ON COLUMN table.column
+			name = name[:strings.LastIndex(name, ".")]
+		}
+		st.kind, st.object = "table", schemaObjectName(name)
+	}
+	s.statements = append(s.statements, st)
+}
+
+// drop は DROP 文で指定されたオブジェクトを、その定義と付随する文ごと取り除きます。
+func (s *Schema) drop(kind string, name string) {
+	var statements []schemaStatement
+	for _, st := range s.statements {
+		switch {
+		case kind == "INDEX" && st.index == name:
+			continue
+		case kind == "TABLE" && st.kind == "table" && st.object == name,
+			kind == "TYPE" && st.kind == "enum" && st.object == name,
+			(kind == "VIEW" || kind == "MATERIALIZED") && st.kind == "view" && st.object == name:
+			continue
+		}
+		statements = append(statements, st)
+	}
+	s.statements = statements
+
+	switch kind {
+	case "TABLE":
+		var tables []*SchemaTable
+		for _, t := range s.Tables {
+			if t.Name != name {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+	case "TYPE":
+		var enums []*SchemaEnum
+		for _, e := range s.Enums {
+			if e.Name != name {
+				enums = append(enums, e)
+			}
+		}
+		s.Enums = enums
+	case "VIEW", "MATERIALIZED":
+		var views []*SchemaView
+		for _, v := range s.Views {
+			if v.Name != name {
+				views = append(views, v)
+			}
+		}
+		s.Views = views
+	}
+}
+
+// renameTable はテーブルの名前を変え、そのテーブルに属する文と外部キーの参照先を付け替えます。
+func (s *Schema) renameTable(table *SchemaTable, newName string) {
+	for i := range s.statements {
+		if s.statements[i].kind == "table" && s.statements[i].object == table.Name {
+			s.statements[i].object = newName
+		}
+	}
+	for _, t := range s.Tables {
+		for i := range t.ForeignKeys {
+			if t.ForeignKeys[i].RefTable == table.Name {
+				t.ForeignKeys[i].RefTable = newName
+			}
+		}
+	}
+	table.Name = newName
+}
+
+// alter は ALTER TABLE の操作1つをテーブルに反映します。RENAME TO の場合は新しい名前を返します。
+func (t *SchemaTable) alter(action string) (newName string) {
+	switch {
+	case schemaRenameTablePattern.MatchString(action):
+		return schemaObjectName(schemaRenameTablePattern.FindStringSubmatch(action)[1])
+	case schemaRenameColPattern.MatchString(action):
+		m := schemaRenameColPattern.FindStringSubmatch(action)
+		for i := range t.Columns {
+			if t.Columns[i].Name == schemaObjectName(m[1]) {
+				t.Columns[i].Name = schemaObjectName(m[2])
+			}
+		}
+	case schemaAddColumnPattern.MatchString(action):
+		// ADD CONSTRAINT ... FOREIGN KEY も ADD COLUMN も、CREATE TABLE の項目と同じ形をしている
+		t.addDefinition(schemaAddColumnPattern.FindStringSubmatch(action)[1])
+	case schemaDropColumnPattern.MatchString(action):
+		name := schemaObjectName(schemaDropColumnPattern.FindStringSubmatch(action)[1])
+		if name == "constraint" {
+			return ""
+		}
+		var columns []SchemaColumn
+		for _, c := range t.Columns {
+			if c.Name != name {
+				columns = append(columns, c)
+			}
+		}
+		t.Columns = columns
+	case schemaAlterColumnPattern.MatchString(action):
+		m := schemaAlterColumnPattern.FindStringSubmatch(action)
+		for i := range t.Columns {
+			if t.Columns[i].Name == schemaObjectName(m[1]) {
+				t.Columns[i].NotNull = strings.EqualFold(m[2], "SET")
+			}
+		}
+	}
+	return ""
+}
+
+// addDefinition は CREATE TABLE の括弧内の項目（列定義または表制約）を1つ取り込みます。
+func (t *SchemaTable) addDefinition(item string) {
+	fields := strings.Fields(item)
+	if len(fields) == 0 {
+		return
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "CONSTRAINT", "PRIMARY", "FOREIGN", "UNIQUE", "CHECK", "EXCLUDE", "LIKE":
+		for _, m := range schemaForeignKeyPattern.FindAllStringSubmatch(item, -1) {
+			fk := SchemaForeignKey{RefTable: schemaObjectName(m[2])}
+			for _, col := range strings.Split(m[1], ",") {
+				fk.Columns = append(fk.Columns, schemaObjectName(strings.TrimSpace(col)))
+			}
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+		}
+		return
+	}
+	col := SchemaColumn{Name: schemaObjectName(fields[0])}
+	if len(fields) > 1 {
+		col.Type = strings.ToLower(fields[1])
+	}
+	upper := strings.ToUpper(item)
+	col.NotNull = strings.Contains(upper, "NOT NULL") || strings.Contains(upper, "PRIMARY KEY")
+	t.Columns = append(t.Columns, col)
+	if m := schemaReferencesPattern.FindStringSubmatch(item); m != nil {
+		t.ForeignKeys = append(t.ForeignKeys, SchemaForeignKey{Columns: []string{col.Name}, RefTable: schemaObjectName(m[1])})
+	}
+}
+
+// Table は name のテーブルを返します。なければ nil です。
+func (s *Schema) Table(name string) *SchemaTable {
+	name = schemaObjectName(name)
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// String はスキーマを元の文の順に組み立て直します。
+func (s *Schema) String() string {
+	var b strings.Builder
+	for _, st := range s.statements {
+		b.WriteString(st.sql)
+	}
+	return b.String()
+}
+
+// RelatedTables は Slice が残すテーブルの名前を返します。対応するテーブルが1つも見つからなければ空です。
+func (s *Schema) RelatedTables(typeNames []string, neighbours bool) map[string]bool {
+	kept := make(map[string]bool)
+	for _, t := range s.Tables {
+		for _, name := range typeNames {
+			if tableMatchesType(t.Name, name) {
+				kept[t.Name] = true
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return kept
+	}
+
+	if neighbours {
+		var add []string
+		for _, t := range s.Tables {
+			for _, fk := range t.ForeignKeys {
+				if kept[t.Name] {
+					add = append(add, fk.RefTable)
+				}
+				if kept[fk.RefTable] {
+					add = append(add, t.Name)
+				}
+			}
+		}
+		for _, name := range add {
+			kept[name] = true
+		}
+	}
+	return kept
+}
+
+// Slice はエンティティの型名（User など）に対応するテーブルだけを残したスキーマを返します。
+// neighbours が true なら、それらと外部キーで直接つながるテーブル（参照先と、中間テーブルのような参照元）も残します。
+// ビューは残したテーブルを参照していれば、enum は残したテーブルの列で使われていれば残します。
+// 対応するテーブルが1つも見つからない場合は関係を判断できないので、スキーマ全体を返します。
+func (s *Schema) Slice(typeNames []string, neighbours bool) (sql string, removed []string) {
+	kept := s.RelatedTables(typeNames, neighbours)
+	if len(kept) == 0 {
+		return s.String(), nil
+	}
+
+	keptViews := make(map[string]bool)
+	for _, v := range s.Views {
+		for _, table := range v.Tables {
+			if kept[table] {
+				keptViews[v.Name] = true
+				break
+			}
+		}
+	}
+	keptEnums := make(map[string]bool)
+	for _, e := range s.Enums {
+		for _, t := range s.Tables {
+			if kept[t.Name] && t.usesType(e.Name) {
+				keptEnums[e.Name] = true
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, st := range s.statements {
+		keep := true
+		switch st.kind {
+		case "table":
+			keep = kept[st.object]
+		case "view":
+			keep = keptViews[st.object]
+		case "enum":
+			keep = keptEnums[st.object]
+		}
+		if keep {
+			b.WriteString(st.sql)
+			continue
+		}
+		// インデックスなどテーブルに付随する文は、テーブル本体とまとめて1件として報告する
+		if !containsString(removed, st.kind+" "+st.object) {
+			removed = append(removed, st.kind+" "+st.object)
+		}
+	}
+	return b.String(), removed
+}
+
+// usesType は列の型として typeName（配列を含む）を使っているかを返します。
+func (t *SchemaTable) usesType(typeName string) bool {
+	for _, col := range t.Columns {
+		if schemaObjectName(strings.TrimSuffix(col.Type, "[]")) == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSQLStatements は SQL を ; で文に分けます。文字列リテラル、引用符付きの識別子、
+// コメント、$$ で囲まれた関数本体の中の ; では分けません。各文には直前のコメントや空白も含まれます。
+func splitSQLStatements(sql string) []string {
+	var statements []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+		case sql[i] == '\'' || sql[i] == '"':
+			quote := sql[i]
+			for i++; i < len(sql); i++ {
+				if sql[i] == quote {
+					if i+1 < len(sql) && sql[i+1] == quote {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case sql[i] == '$':
+			if m := dollarQuotePattern.FindString(sql[i:]); m != "" {
+				if end := strings.Index(sql[i+len(m):], m); end >= 0 {
+					i += len(m) + end + len(m) - 1
+				} else {
+					i = len(sql)
+				}
+			}
+		case sql[i] == ';':
+			statements = append(statements, sql[start:i+1])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(sql[start:]) != "" {
+		statements = append(statements, sql[start:])
+	} else if start < len(sql) && len(statements) > 0 {
+		// 末尾の改行などは最後の文に付ける
+		statements[len(statements)-1] += sql[start:]
+	}
+	return statements
+}
+
+var dollarQuotePattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// splitTopLevel は括弧の外にある , で項目を分けます。
+func splitTopLevel(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		items = append(items, rest)
+	}
+	return items
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleSchema = `CREATE TYPE user_status AS ENUM ('active', 'it''s banned');
+
+CREATE TYPE invoice_state AS ENUM ('open', 'paid');
+
+CREATE TABLE users (
+  id BIGINT PRIMARY KEY,
+  name TEXT NOT NULL, -- 表示名; 空文字は許可しない
+  status user_status NOT NULL,
+  bio TEXT
+);
+
+-- 投稿
+CREATE TABLE posts (
+  id BIGINT PRIMARY KEY,
+  author_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  title TEXT NOT NULL DEFAULT 'untitled;'
+);
+
+CREATE INDEX posts_author_idx ON posts (author_id);
+
+CREATE TABLE tags (
+  id BIGINT PRIMARY KEY
+);
+
+CREATE TABLE post_tags (
+  post_id BIGINT NOT NULL,
+  tag_id BIGINT NOT NULL,
+  CONSTRAINT post_tags_post_fk FOREIGN KEY (post_id) REFERENCES posts (id),
+  CONSTRAINT post_tags_tag_fk FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE TABLE invoices (
+  id BIGINT PRIMARY KEY,
+  state invoice_state NOT NULL
+);
+
+CREATE INDEX invoices_idx ON invoices (id);
+
+CREATE VIEW active_users AS SELECT * FROM users WHERE status = 'active';
+
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+`
+
+func TestParseSchema(t *testing.T) {
+	s := ParseSchema(sampleSchema)
+	if s.String() != sampleSchema {
+		t.Errorf("String() does not reproduce the original schema:\n%s", s.String())
+	}
+
+	var tables []string
+	for _, table := range s.Tables {
+		tables = append(tables, table.Name)
+	}
+	if got := strings.Join(tables, ","); got != "users,posts,tags,post_tags,invoices" {
+		t.Errorf("tables = %s", got)
+	}
+
+	users := s.Table("Users")
+	if users == nil || len(users.Columns) != 4 {
+		t.Fatalf("users columns = %+v", users)
+	}
+	if c := users.Columns[2]; c.Name != "status" || c.Type != "user_status" || !c.NotNull {
+		t.Errorf("status column = %+v", c)
+	}
+	if c := users.Columns[3]; c.Name != "bio" || c.NotNull {
+		t.Errorf("bio column = %+v", c)
+	}
+
+	if fks := s.Table("post_tags").ForeignKeys; len(fks) != 2 || fks[0].RefTable != "posts" || fks[0].Columns[0] != "post_id" || fks[1].RefTable != "tags" {
+		t.Errorf("post_tags foreign keys = %+v", fks)
+	}
+	if fks := s.Table("posts").ForeignKeys; len(fks) != 1 || fks[0].RefTable != "users" || fks[0].Columns[0] != "author_id" {
+		t.Errorf("posts foreign keys = %+v", fks)
+	}
+
+	if len(s.Enums) != 2 || strings.Join(s.Enums[0].Values, ",") != "active,it's banned" {
+		t.Errorf("enums = %+v", s.Enums[0])
+	}
+	if len(s.Views) != 1 || s.Views[0].Name != "active_users" || strings.Join(s.Views[0].Tables, ",") != "users" {
+		t.Errorf("views = %+v", s.Views)
+	}
+}
+
+func TestSchemaSlice(t *testing.T) {
+	s := ParseSchema(sampleSchema)
+
+	sliced, removed := s.Slice([]string{"UserID", "User"}, true)
+	for _, want := range []string{"CREATE TYPE user_status", "CREATE TABLE users", "-- 投稿\nCREATE TABLE posts", "posts_author_idx", "CREATE VIEW active_users", "CREATE FUNCTION touch()"} {
+		if !strings.Contains(sliced, want) {
+			t.Errorf("expected sliced schema to contain %q:\n%s", want, sliced)
+		}
+	}
+	for _, unwanted := range []string{"invoice", "CREATE TABLE tags", "post_tags"} {
+		if strings.Contains(sliced, unwanted) {
+			t.Errorf("expected sliced schema not to contain %q:\n%s", unwanted, sliced)
+		}
+	}
+	if got := strings.Join(removed, ","); got != "enum invoice_state,table tags,table post_tags,table invoices" {
+		t.Errorf("removed = %s", got)
+	}
+
+	// 中間テーブルは参照元の近傍として残る
+	sliced, _ = s.Slice([]string{"Post"}, true)
+	for _, want := range []string{"CREATE TABLE users", "CREATE TABLE post_tags"} {
+		if !strings.Contains(sliced, want) {
+			t.Errorf("expected posts neighbours to contain %q:\n%s", want, sliced)
+		}
+	}
+	sliced, _ = s.Slice([]string{"Post"}, false)
+	if strings.Contains(sliced, "CREATE TABLE users") || !strings.Contains(sliced, "CREATE TABLE posts") {
+		t.Errorf("expected only posts without neighbours:\n%s", sliced)
+	}
+
+	// s で終わる単数形の型も、その複数形のテーブルに対応する
+	withAddresses := ParseSchema(sampleSchema + "\nCREATE TABLE addresses (id BIGINT PRIMARY KEY);\n\nCREATE TABLE statuses (id BIGINT PRIMARY KEY);\n")
+	for typeName, table := range map[string]string{"Address": "CREATE TABLE addresses", "Status": "CREATE TABLE statuses"} {
+		sliced, _ := withAddresses.Slice([]string{typeName}, false)
+		if !strings.Contains(sliced, table) || strings.Contains(sliced, "CREATE TABLE users") {
+			t.Errorf("expected only %q for %s:\n%s", table, typeName, sliced)
+		}
+	}
+
+	// 対応するテーブルがなければ何も削らない
+	if same, removed := s.Slice([]string{"Unknown"}, true); same != sampleSchema || removed != nil {
+		t.Errorf("expected the whole schema when no table matches, removed %v", removed)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQLCheckContext は SQL のチェックに渡す、生成先の情報です。
+type SQLCheckContext struct {
+	Engine      string             // postgresql, mysql, sqlite（分からなければ空）
+	Schema      *Schema            // DB スキーマ（nil ならスキーマを使うチェックはしない）
+	Conventions *ConventionsConfig // 列の規約（nil ならチェックしない）
+	Existing    map[string]string  // ほかのクエリファイルにあるクエリ名 → ファイル（nil なら名前の重複と再利用はチェックしない）
+}
+
+// SQLCheck は生成された SQL のクエリ1つに対するチェックです。
+type SQLCheck struct {
+	Name string
+	Run  func(q NamedQuery, c *SQLCheckContext) []string
+}
+
+// sqlChecks は GenerateSQL の最後に、生成されたクエリに対して実行されるチェックの一覧です。
+var sqlChecks = []SQLCheck{
+	{Name: "sql-unbounded-write", Run: checkUnboundedWrite},
+	{Name: "sql-select-star-join", Run: checkSelectStarJoin},
+	{Name: "sql-arg-misuse", Run: checkSQLArgs},
+	{Name: "convention-soft-delete", Run: checkSoftDelete},
+	{Name: "convention-tenant", Run: checkTenant},
+	{Name: "convention-audit", Run: checkAudit},
+	{Name: "sql-duplicate-name", Run: checkDuplicateName},
+}
+
+var (
+	sqlWherePattern       = regexp.MustCompile(`(?i)\bwhere\b`)
+	sqlSelectStarPattern  = regexp.MustCompile(`(?i)\bselect\s+(?:distinct\s+)?\*`)
+	sqlTableStarPattern   = regexp.MustCompile(`\b\w+\.\*`)
+	sqlJoinPattern        = regexp.MustCompile(`(?i)\bjoin\b`)
+	sqlPositionalPattern  = regexp.MustCompile(`\$[0-9]+`)
+	sqlNamedPattern       = regexp.MustCompile(`(?i)sqlc\.n?arg\(|@[A-Za-z_]`)
+	sqlMacroPattern       = regexp.MustCompile(`(?i)sqlc\.(n?arg|slice)\(([^)]*)\)`)
+	sqlIdentPattern       = regexp.MustCompile(`^'?[A-Za-z_][A-Za-z0-9_]*'?$`)
+	sqlLiteralArgPattern  = regexp.MustCompile(`(?i)['\s%(]@[A-Za-z_]|sqlc\.n?arg\(|\$[0-9]+`)
+	goSQLStatementPattern = regexp.MustCompile(`(?is)\b(select\b.+\bfrom|insert\s+into|update\b.+\bset|delete\s+from)\b`)
+)
+
+// checkUnboundedWrite は WHERE のない UPDATE と DELETE（テーブル全体を書き換えるもの）を報告します。
+func checkUnboundedWrite(q NamedQuery, c *SQLCheckContext) []string {
+	verb := sqlVerb(q.SQL)
+	if (verb == "UPDATE" || verb == "DELETE") && !sqlWherePattern.MatchString(stripSQLComments(q.SQL)) {
+		return []string{verb + " without WHERE changes every row of the table; add a WHERE clause that limits the rows"}
+	}
+	return nil
+}
+
+// checkSelectStarJoin は JOIN したクエリでの SELECT * と、複数のテーブルの u.*, p.* を報告します。列名が衝突し、sqlc の構造体に正しく対応付けられません。
+// SELECT u.*, p.title のように1つのテーブルだけを * にするのは衝突しないので報告しません。
+func checkSelectStarJoin(q NamedQuery, c *SQLCheckContext) []string {
+	sql := stripSQLComments(q.SQL)
+	stars := sqlSelectStarPattern.MatchString(sql) || len(sqlTableStarPattern.FindAllString(sql, 2)) > 1
+	if stars && sqlJoinPattern.MatchString(sql) {
+		return []string{"SELECT * across a JOIN produces columns with conflicting names; list the columns or use sqlc.embed(table)"}
+	}
+	return nil
+}
+
+// checkSQLArgs は sqlc のプレースホルダの誤用を報告します。
+func checkSQLArgs(q NamedQuery, c *SQLCheckContext) []string {
+	var messages []string
+	sql := stripSQLComments(q.SQL)
+	if sqlPositionalPattern.MatchString(sql) && sqlNamedPattern.MatchString(sql) {
+		messages = append(messages, "mixes positional ($1) and named (@name, sqlc.arg) parameters; sqlc cannot map them, use named parameters only")
+	}
+	for _, m := range sqlMacroPattern.FindAllStringSubmatch(sql, -1) {
+		arg := strings.TrimSpace(m[2])
+		switch {
+		case !sqlIdentPattern.MatchString(arg):
+			messages = append(messages, "sqlc."+m[1]+"("+arg+") must take a single parameter name, e.g. sqlc."+m[1]+"(user_id)")
+		case strings.EqualFold(m[1], "slice") && c.Engine == "postgresql":
+			messages = append(messages, "sqlc.slice is only supported for MySQL and SQLite; use = ANY(@"+strings.Trim(arg, "'")+"::type[]) with PostgreSQL")
+		}
+	}
+	// 文字列リテラルの中のプレースホルダは置き換えられない（'%' || @q || '%' と書く）
+	withoutComments := sqlBlockCommentPattern.ReplaceAllString(q.SQL, " ")
+	withoutComments = sqlQuotedArgPattern.ReplaceAllString(withoutComments, "$1($2)")
+	withoutComments = sqlLineCommentPattern.ReplaceAllString(withoutComments, " ")
+	for _, lit := range sqlStringPattern.FindAllString(withoutComments, -1) {
+		if sqlLiteralArgPattern.MatchString(lit) {
+			messages = append(messages, "the placeholder in the string literal "+lit+" is not replaced; concatenate it instead, e.g. '%' || @q || '%'")
+		}
+	}
+	return messages
+}
+
+// LintQueries は src（"-- name:" で区切られたクエリ群）に sqlChecks を実行し、指摘を返します。
+// 位置は path の line 行目から src が始まるものとして計算します。
+func LintQueries(path string, line int, method string, src string, c *SQLCheckContext) []Finding {
+	var findings []Finding
+	offset := 0
+	for _, q := range ParseQueries(src) {
+		first := strings.SplitN(q.SQL, "\n", 2)[0]
+		if i := strings.Index(src[offset:], first); i >= 0 {
+			offset += i
+		}
+		pos := token.Position{Filename: path, Line: line + strings.Count(src[:offset], "\n"), Column: 1}
+		for _, check := range sqlChecks {
+			for _, message := range check.Run(q, c) {
+				findings = append(findings, Finding{Rule: check.Name, Method: method, Pos: pos, Message: q.Name + ": " + message})
+			}
+		}
+	}
+	// 再利用するとしたクエリが、ほかのクエリファイルにあるかを確かめる
+	if c.Existing != nil {
+		for _, name := range reusedQueryNames(src) {
+			if _, ok := c.Existing[name]; !ok {
+				pos := token.Position{Filename: path, Line: line, Column: 1}
+				findings = append(findings, Finding{Rule: "sql-reuse", Method: method, Pos: pos,
+					Message: name + ": there is no existing query with this name in the other query files; write the query instead"})
+			}
+		}
+	}
+	return findings
+}
+
+// checkSQLConcat は、Go のコードで SQL を文字列の連結や fmt.Sprintf で組み立てている箇所を報告します。
+func checkSQLConcat(c *CheckContext) []Finding {
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		ast.Inspect(c.Methods[method].Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				if n.Op == token.ADD && (isSQLLiteral(n.X) || isSQLLiteral(n.Y)) {
+					findings = append(findings, c.newFinding("sql-concat", method, n,
+						"builds SQL by concatenating strings; use a sqlc query with parameters instead"))
+					return false
+				}
+			case *ast.CallExpr:
+				if sel, ok := n.Fun.(*ast.SelectorExpr); ok && isIdent(sel.X, "fmt") && strings.HasPrefix(sel.Sel.Name, "Sprint") &&
+					len(n.Args) > 0 && isSQLLiteral(n.Args[0]) {
+					findings = append(findings, c.newFinding("sql-concat", method, n,
+						"builds SQL with fmt.%s; use a sqlc query with parameters instead", sel.Sel.Name))
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return findings
+}
+
+// isSQLLiteral は expr が SQL 文に見える文字列リテラルかを返します。
+func isSQLLiteral(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return err == nil && goSQLStatementPattern.MatchString(s)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintQueries(t *testing.T) {
+	src := `-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: ListPostsWithAuthor :many
+SELECT * FROM posts
+JOIN users ON users.id = posts.author_id
+WHERE posts.id = $1 AND users.name = @name;
+
+-- name: SearchUsers :many
+SELECT id, name FROM users
+WHERE name LIKE '%@query%' AND id IN (sqlc.slice(ids));
+
+-- name: UpdateUser :exec
+UPDATE users SET name = sqlc.arg('name'), email = 'admin@example.com' -- no WHERE here
+WHERE id = sqlc.arg(id);
+`
+	var got []string
+	for _, f := range LintQueries("pkg/infra/sql/query/user.sql", 10, "Cleanup", src, &SQLCheckContext{Engine: "postgresql"}) {
+		got = append(got, f.Pos.String()+" ["+f.Rule+"] "+f.Message)
+	}
+	want := []string{
+		"pkg/infra/sql/query/user.sql:10:1 [sql-unbounded-write] DeleteUsers: DELETE without WHERE changes every row of the table; add a WHERE clause that limits the rows",
+		"pkg/infra/sql/query/user.sql:13:1 [sql-select-star-join] ListPostsWithAuthor: SELECT * across a JOIN produces columns with conflicting names; list the columns or use sqlc.embed(table)",
+		"pkg/infra/sql/query/user.sql:13:1 [sql-arg-misuse] ListPostsWithAuthor: mixes positional ($1) and named (@name, sqlc.arg) parameters; sqlc cannot map them, use named parameters only",
+		"pkg/infra/sql/query/user.sql:18:1 [sql-arg-misuse] SearchUsers: sqlc.slice is only supported for MySQL and SQLite; use = ANY(@ids::type[]) with PostgreSQL",
+		"pkg/infra/sql/query/user.sql:18:1 [sql-arg-misuse] SearchUsers: the placeholder in the string literal '%@query%' is not replaced; concatenate it instead, e.g. '%' || @q || '%'",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckSelectStarJoin(t *testing.T) {
+	for sql, flagged := range map[string]bool{
+		"SELECT * FROM posts JOIN users ON users.id = posts.author_id":                  true,
+		"SELECT DISTINCT * FROM posts p JOIN users u ON u.id = p.author_id":             true,
+		"SELECT u.*, p.* FROM users u JOIN posts p ON p.author_id = u.id":               true,
+		"SELECT u.*, p.title FROM users u JOIN posts p ON p.author_id = u.id":           false,
+		"SELECT * FROM users WHERE id = @id":                                            false,
+		"SELECT sqlc.embed(u), p.title FROM users u JOIN posts p ON p.author_id = u.id": false,
+	} {
+		got := checkSelectStarJoin(NamedQuery{Name: "Q", SQL: "-- name: Q :many\n" + sql}, &SQLCheckContext{})
+		if (len(got) > 0) != flagged {
+			t.Errorf("%s: flagged = %v, want %v", sql, len(got) > 0, flagged)
+		}
+	}
+}
+
+func TestCheckSQLConcat(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+	"fmt"
+)
+
+type UserRepositoryImpl struct{}
+
+func (repo *UserRepositoryImpl) FindByName(ctx context.Context, name string) error {
+	_ = "SELECT id FROM users WHERE name = '" + name + "'"
+	return nil
+}
+
+func (repo *UserRepositoryImpl) Sort(ctx context.Context, column string) error {
+	_ = fmt.Sprintf("SELECT id FROM users ORDER BY %s", column)
+	return nil
+}
+
+func (repo *UserRepositoryImpl) Greet(ctx context.Context, name string) error {
+	_ = "hello, " + name
+	return fmt.Errorf("select a user: %s", name)
+}
+`
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, []string{"FindByName", "Sort", "Greet"}, &DBPackage{})
+	var messages []string
+	for _, f := range checkSQLConcat(c) {
+		messages = append(messages, f.Method+": "+f.Message)
+	}
+	want := []string{
+		"FindByName: builds SQL by concatenating strings; use a sqlc query with parameters instead",
+		"Sort: builds SQL with fmt.Sprintf; use a sqlc query with parameters instead",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected findings:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestChecksConfigFixFeedback(t *testing.T) {
+	findings := []Finding{
+		{Rule: "sql-concat", Method: "FindByName", Message: "builds SQL by concatenating strings"},
+		{Rule: "bulk", Method: "FindByName", Message: "never read"},
+		{Rule: "sql-unbounded-write", Method: "Cleanup", Message: "DELETE without WHERE"},
+	}
+	if feedback := (&ChecksConfig{}).fixFeedback(findings); feedback != nil {
+		t.Errorf("expected no feedback when fix is disabled, got %v", feedback)
+	}
+	c := &ChecksConfig{Fix: true, Severity: map[string]string{"sql-unbounded-write": SeverityWarn}}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	feedback := c.fixFeedback(findings)
+	if len(feedback) != 1 || strings.Join(feedback["FindByName"], ",") != "builds SQL by concatenating strings" {
+		t.Errorf("unexpected feedback: %v", feedback)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceFile はプロンプトに埋め込むソースファイル（またはその一部）です。
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
+// sqlcDecl は sqlc が生成したパッケージのトップレベル宣言1つ分です。
+type sqlcDecl struct {
+	file      string
+	order     int      // ファイル内での出現順
+	names     []string // 定義する名前。メソッドは "Recv.Name" の形式
+	constType string   // 型付きの const グループであればその型名（enum の値など）
+	node      ast.Node
+	src       string
+}
+
+// SQLCCode は sqlc が生成した Go パッケージを宣言単位に分解したものです。
+type SQLCCode struct {
+	pkg    *DBPackage
+	files  map[string]string // パス → ファイル全体
+	decls  []*sqlcDecl
+	byName map[string]*sqlcDecl
+	kinds  map[string]string // クエリ名 → :one, :many などの種類
+	sqls   map[string]string // クエリ名 → "-- name:" コメントから始まる SQL
+}
+
+// sqlcNamePattern は sqlc が SQL 定数の先頭に残す "-- name: GetUser :one" を拾います。
+var sqlcNamePattern = regexp.MustCompile(`--\s*name:\s*(\w+)\s+(:\w+)`)
+
+// LoadSQLCCode は生成パッケージ内の Go ファイルをすべてパースします。
+func LoadSQLCCode(pkg *DBPackage) (*SQLCCode, error) {
+	entries, err := os.ReadDir(pkg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	code := &SQLCCode{
+		pkg:    pkg,
+		files:  make(map[string]string),
+		byName: make(map[string]*sqlcDecl),
+		kinds:  make(map[string]string),
+		sqls:   make(map[string]string),
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(pkg.Dir, name)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		code.files[path] = string(src)
+		code.addFile(fset, path, f, src)
+	}
+	return code, nil
+}
+
+func (c *SQLCCode) addFile(fset *token.FileSet, path string, f *ast.File, src []byte) {
+	text := func(node ast.Node, doc *ast.CommentGroup) string {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return string(src[fset.Position(start).Offset:fset.Position(node.End()).Offset])
+	}
+	add := func(d *sqlcDecl) {
+		d.file = path
+		d.order = len(c.decls)
+		c.decls = append(c.decls, d)
+		for _, name := range d.names {
+			c.byName[name] = d
+		}
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				name = receiverTypeName(decl.Recv.List[0].Type) + "." + name
+			}
+			add(&sqlcDecl{names: []string{name}, node: decl, src: text(decl, decl.Doc)})
+		case *ast.GenDecl:
+			switch decl.Tok {
+			case token.TYPE:
+				for _, spec := range decl.Specs {
+					ts := spec.(*ast.TypeSpec)
+					d := &sqlcDecl{names: []string{ts.Name.Name}, node: ts}
+					if decl.Lparen.IsValid() {
+						d.src = "type " + text(ts, ts.Doc)
+					} else {
+						d.src = text(decl, decl.Doc)
+					}
+					add(d)
+				}
+			case token.CONST, token.VAR:
+				d := &sqlcDecl{node: decl, src: text(decl, decl.Doc)}
+				for _, spec := range decl.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for _, n := range vs.Names {
+						d.names = append(d.names, n.Name)
+					}
+					if ident, ok := vs.Type.(*ast.Ident); ok && decl.Tok == token.CONST {
+						d.constType = ident.Name
+					}
+					for _, value := range vs.Values {
+						lit, ok := value.(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							continue
+						}
+						if sqlText, err := strconv.Unquote(lit.Value); err == nil {
+							if m := sqlcNamePattern.FindStringSubmatch(sqlText); m != nil {
+								c.kinds[m[1]] = m[2]
+								c.sqls[m[1]] = sqlText
+							}
+						}
+					}
+				}
+				add(d)
+			}
+		}
+	}
+}
+
+// receiverTypeName はレシーバの型名（ポインタを外したもの）を返します。
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	}
+	return ""
+}
+
+// HasQuery は *Queries に name という名前のメソッドが生成されているかを返します。
+func (c *SQLCCode) HasQuery(name string) bool {
+	_, ok := c.byName["Queries."+name]
+	return ok
+}
+
+// QueryKind は name のクエリの種類（:one, :many, :exec など）を返します。分からなければ空文字列です。
+func (c *SQLCCode) QueryKind(name string) string {
+	return c.kinds[name]
+}
+
+// Query は name のクエリを、生成されたコードに残る SQL から復元して返します。
+func (c *SQLCCode) Query(name string) (NamedQuery, bool) {
+	sqlText, ok := c.sqls[name]
+	if !ok {
+		return NamedQuery{}, false
+	}
+	queries := ParseQueries(sqlText)
+	if len(queries) == 0 {
+		return NamedQuery{}, false
+	}
+	return queries[0], true
+}
+
+// Slice は db.go 全体と、queryNames のクエリ関数、それらが参照する
+// パラメータ・戻り値の構造体、SQL 定数、モデル（enum の値を含む）だけをファイルごとに返します。
+func (c *SQLCCode) Slice(queryNames []string) ([]SourceFile, error) {
+	included, err := c.closure(queryNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var ordered []*sqlcDecl
+	for d := range included {
+		if d.file != c.pkg.DBFile {
+			ordered = append(ordered, d)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].order < ordered[j].order })
+
+	result := []SourceFile{{Path: c.pkg.DBFile, Content: c.files[c.pkg.DBFile]}}
+	for _, d := range ordered {
+		last := &result[len(result)-1]
+		if last.Path != d.file {
+			result = append(result, SourceFile{Path: d.file, Content: fmt.Sprintf("package %s\n\n// (excerpt)", c.pkg.Name)})
+			last = &result[len(result)-1]
+		}
+		if last.Content != "" {
+			last.Content += "\n\n"
+		}
+		last.Content += d.src
+	}
+	return result, nil
+}
+
+// QueriesIn は path に生成されたクエリ関数の名前を、ファイル内の順に返します。
+func (c *SQLCCode) QueriesIn(path string) []string {
+	var names []string
+	for _, d := range c.decls {
+		if d.file != path {
+			continue
+		}
+		for _, name := range d.names {
+			if strings.HasPrefix(name, "Queries.") {
+				names = append(names, strings.TrimPrefix(name, "Queries."))
+			}
+		}
+	}
+	return names
+}
+
+// OmittedModels は Slice(queryNames) で省かれる、models ファイルの型の名前を返します。
+func (c *SQLCCode) OmittedModels(queryNames []string) ([]string, error) {
+	included, err := c.closure(queryNames)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, d := range c.decls {
+		if d.file != c.pkg.ModelsFile || included[d] {
+			continue
+		}
+		if _, ok := d.node.(*ast.TypeSpec); ok {
+			names = append(names, d.names...)
+		}
+	}
+	return names, nil
+}
+
+// closure は queryNames のクエリ関数と、それらから辿れる宣言の集合を返します。
+func (c *SQLCCode) closure(queryNames []string) (map[*sqlcDecl]bool, error) {
+	included := make(map[*sqlcDecl]bool)
+	var queue []*sqlcDecl
+	include := func(d *sqlcDecl) {
+		if d == nil || included[d] {
+			return
+		}
+		included[d] = true
+		queue = append(queue, d)
+	}
+
+	for _, name := range queryNames {
+		d, ok := c.byName["Queries."+name]
+		if !ok {
+			return nil, fmt.Errorf("query function %s not found in %s", name, c.pkg.Dir)
+		}
+		include(d)
+	}
+
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		ast.Inspect(d.node, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				// db.go はそのまま含めるので、そこで定義されたものは辿らない
+				if ref, ok := c.byName[ident.Name]; ok && ref != d && ref.file != c.pkg.DBFile {
+					include(ref)
+				}
+			}
+			return true
+		})
+		// enum 型であればその値の const を、:batchexec の結果や :copyfrom のイテレータのような型であればそのメソッドも含める
+		if ts, ok := d.node.(*ast.TypeSpec); ok {
+			for _, other := range c.decls {
+				if other.constType == ts.Name.Name {
+					include(other)
+				}
+				if _, isFunc := other.node.(*ast.FuncDecl); isFunc && ts.Name.Name != "Queries" &&
+					len(other.names) == 1 && strings.HasPrefix(other.names[0], ts.Name.Name+".") {
+					include(other)
+				}
+			}
+		}
+	}
+
+	return included, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLCCodeSlice(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"db.go": `package db
+
+type DBTX interface{}
+
+func New(db DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+type Queries struct {
+	db DBTX
+}
+`,
+		"models.go": `package db
+
+type UserStatus string
+
+const (
+	UserStatusActive UserStatus = "active"
+	UserStatusBanned UserStatus = "banned"
+)
+
+type User struct {
+	ID     int64
+	Status UserStatus
+}
+
+type Post struct {
+	ID int64
+}
+`,
+		"user.sql.go": `package db
+
+import "context"
+
+const getUser = ` + "`" + `-- name: GetUser :one
+SELECT id, status FROM users WHERE id = $1
+` + "`" + `
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
+	var i User
+	_ = getUser
+	return i, nil
+}
+
+const listPosts = ` + "`" + `-- name: ListPosts :many
+SELECT id FROM posts
+` + "`" + `
+
+func (q *Queries) ListPosts(ctx context.Context) ([]Post, error) {
+	_ = listPosts
+	return nil, nil
+}
+`,
+	})
+
+	pkg := &DBPackage{Dir: dir, Name: "db", DBFile: filepath.Join(dir, "db.go")}
+	code, err := LoadSQLCCode(pkg)
+	if err != nil {
+		t.Fatalf("LoadSQLCCode() error: %v", err)
+	}
+	if !code.HasQuery("GetUser") || code.HasQuery("Missing") {
+		t.Fatalf("HasQuery() returned an unexpected result")
+	}
+
+	files, err := code.Slice([]string{"GetUser"})
+	if err != nil {
+		t.Fatalf("Slice() error: %v", err)
+	}
+	var all strings.Builder
+	for _, f := range files {
+		all.WriteString(f.Content)
+	}
+	got := all.String()
+	for _, want := range []string{"func New(", "func (q *Queries) GetUser(", "const getUser", "type User struct", "type UserStatus string", "UserStatusBanned"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected slice to contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"ListPosts", "listPosts", "type Post struct"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("slice must not contain %q", unwanted)
+		}
+	}
+
+	if _, err := code.Slice([]string{"Missing"}); err == nil {
+		t.Errorf("expected an error for an unknown query")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sqlcConfigPath は sqlc の設定ファイルの位置です。設定内のパスはこのファイルのディレクトリからの相対パスです。
+var sqlcConfigPath = filepath.Join("pkg", "infra", "sqlc.yml")
+
+// stringList は sqlc の設定で単一の文字列とリストのどちらでも書ける項目（schema, queries）を表します。
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*l = stringList{value.Value}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a string or a list of strings", value.Line)
+}
+
+// SQLCConfig は sqlc.yml（version 2）のうち、llm-sqlc が参照する項目だけを保持します。
+type SQLCConfig struct {
+	Version string        `yaml:"version"`
+	SQL     []SQLCPackage `yaml:"sql"`
+}
+
+// SQLCPackage は sqlc.yml の sql ブロック1つ分です。
+type SQLCPackage struct {
+	Name    string     `yaml:"name"`
+	Engine  string     `yaml:"engine"`
+	Schema  stringList `yaml:"schema"`
+	Queries stringList `yaml:"queries"`
+	Gen     struct {
+		Go *SQLCGoGen `yaml:"go"`
+	} `yaml:"gen"`
+}
+
+// SQLCGoGen は sql ブロックの gen.go 設定です。
+type SQLCGoGen struct {
+	Package                string `yaml:"package"`
+	Out                    string `yaml:"out"`
+	SQLPackage             string `yaml:"sql_package"`
+	EmitInterface          bool   `yaml:"emit_interface"`
+	OutputFilesSuffix      string `yaml:"output_files_suffix"`
+	OutputDBFileName       string `yaml:"output_db_file_name"`
+	OutputModelsFileName   string `yaml:"output_models_file_name"`
+	OutputQuerierFileName  string `yaml:"output_querier_file_name"`
+	OutputBatchFileName    string `yaml:"output_batch_file_name"`
+	OutputCopyFromFileName string `yaml:"output_copyfrom_file_name"`
+}
+
+// goOut は gen.go.out を返します。Go の生成設定がなければ空文字を返します。
+func (p *SQLCPackage) goOut() string {
+	if p.Gen.Go == nil {
+		return ""
+	}
+	return p.Gen.Go.Out
+}
+
+// goPackage は生成される Go パッケージ名を返します。package の指定がなければ out の末尾が使われます。
+func (p *SQLCPackage) goPackage() string {
+	if p.Gen.Go == nil {
+		return ""
+	}
+	if p.Gen.Go.Package != "" {
+		return p.Gen.Go.Package
+	}
+	return filepath.Base(p.Gen.Go.Out)
+}
+
+// selectSQLCBlock は infraFile に対応する sql ブロックの添字を返します。
+// llm-sqlc.yml の対応付けを最優先し、次にブロックが1つだけならそれを、
+// それ以外は gen.go.out や schema、queries のパスが infraFile の位置とどれだけ一致するかで選びます。
+// configDir は sqlc.yml のあるディレクトリで、sqlc 設定内のパスはここからの相対パスです。
+func selectSQLCBlock(cfg *SQLCConfig, configDir string, infraFile string, mappings []SQLCPackageMapping) (int, error) {
+	if len(cfg.SQL) == 0 {
+		return -1, fmt.Errorf("no sql blocks in sqlc configuration")
+	}
+
+	infraDir := filepath.Clean(filepath.Dir(infraFile))
+
+	// 明示的な対応付け（最も深いディレクトリの指定を優先）
+	var matched *SQLCPackageMapping
+	for i := range mappings {
+		m := &mappings[i]
+		if !pathWithin(infraDir, filepath.Clean(m.Infra)) {
+			continue
+		}
+		if matched == nil || len(filepath.Clean(m.Infra)) > len(filepath.Clean(matched.Infra)) {
+			matched = m
+		}
+	}
+	if matched != nil {
+		for i := range cfg.SQL {
+			if cfg.SQL[i].Name == matched.Package || cfg.SQL[i].goPackage() == matched.Package {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("sqlc package %q mapped to %s not found in sqlc configuration", matched.Package, matched.Infra)
+	}
+
+	if len(cfg.SQL) == 1 {
+		return 0, nil
+	}
+
+	relDir, err := filepath.Rel(configDir, infraDir)
+	if err != nil {
+		relDir = infraDir
+	}
+	var segments []string
+	for _, s := range strings.Split(filepath.ToSlash(relDir), "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+
+	best, bestScore, tie := -1, 0, false
+	for i := range cfg.SQL {
+		score := sqlcBlockScore(&cfg.SQL[i], relDir, segments)
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = i, score, false
+		case score == bestScore && score > 0:
+			tie = true
+		}
+	}
+	if best < 0 || tie {
+		return -1, fmt.Errorf("cannot determine which sqlc package %s belongs to; add a sqlc_packages mapping to %s", infraFile, configPath)
+	}
+	return best, nil
+}
+
+// sqlcBlockScore は sql ブロックと infra ファイルのディレクトリ（configDir からの相対パス）の一致度を返します。
+// 生成先パッケージが infra ファイルと同じディレクトリの直下にある場合を最も強い一致とし、
+// それ以外はディレクトリ名が out、schema、queries のパスに現れる数を数えます。
+func sqlcBlockScore(block *SQLCPackage, relDir string, segments []string) int {
+	out := block.goOut()
+	if out != "" && filepath.Clean(filepath.Dir(out)) == filepath.Clean(relDir) {
+		return 1000
+	}
+
+	paths := append([]string{out}, block.Schema...)
+	paths = append(paths, block.Queries...)
+	score := 0
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		parts := strings.Split(filepath.ToSlash(filepath.Clean(p)), "/")
+		for _, seg := range segments {
+			for _, part := range parts {
+				if part == seg || strings.TrimSuffix(part, filepath.Ext(part)) == seg {
+					score++
+					break
+				}
+			}
+		}
+	}
+	return score
+}
+
+// pathWithin は path が dir そのもの、または dir 以下にあるかを返します。
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// queriesCover は queries のいずれかが queryPath そのものか、queryPath を直下に含むディレクトリであるかを返します。
+// sqlc はディレクトリ直下の .sql ファイルしか読まないので、サブディレクトリのファイルは含まれていないものとします。
+// queries と queryPath はどちらも configDir からの相対パスです。
+func queriesCover(configDir string, queries []string, queryPath string) bool {
+	queryPath = filepath.Clean(queryPath)
+	for _, q := range queries {
+		q = filepath.Clean(q)
+		if q == queryPath {
+			return true
+		}
+		isDir := filepath.Ext(q) != ".sql"
+		if info, err := os.Stat(filepath.Join(configDir, q)); err == nil {
+			isDir = info.IsDir()
+		}
+		if isDir && filepath.Dir(queryPath) == q {
+			return true
+		}
+	}
+	return false
+}
+
+// registerQueryFile は infraFile に対応する sql ブロックの queries に queryFile を追加し、sqlc.yml を更新します。
+// queries が単一の文字列の場合はリストに変換し、既にディレクトリ指定で含まれている場合は何もしません。
+func registerQueryFile(cfg *Config, infraFile string, queryFile string) {
+	configData, err := os.ReadFile(sqlcConfigPath)
+	if err != nil {
+		slog.Warn("could not read sqlc configuration file", "path", sqlcConfigPath, "error", err)
+		return
+	}
+
+	var sqlcConfig map[string]interface{}
+	if err := yaml.Unmarshal(configData, &sqlcConfig); err != nil {
+		slog.Warn("failed to parse sqlc configuration file", "path", sqlcConfigPath, "error", err)
+		return
+	}
+	var typed SQLCConfig
+	if err := yaml.Unmarshal(configData, &typed); err != nil {
+		slog.Warn("failed to parse sqlc configuration file", "path", sqlcConfigPath, "error", err)
+		return
+	}
+
+	configDir := filepath.Dir(sqlcConfigPath)
+	relativeQueryPath, err := filepath.Rel(configDir, queryFile)
+	if err != nil {
+		relativeQueryPath = queryFile
+	}
+	relativeQueryPath = filepath.ToSlash(relativeQueryPath)
+
+	index, err := selectSQLCBlock(&typed, configDir, infraFile, cfg.SQLCPackages)
+	if err != nil {
+		slog.Warn("query file was not registered in the sqlc configuration", "query", relativeQueryPath, "path", sqlcConfigPath, "error", err)
+		return
+	}
+	sqlBlocks, ok := sqlcConfig["sql"].([]interface{})
+	if !ok || index >= len(sqlBlocks) {
+		slog.Warn("unexpected structure of sql blocks", "path", sqlcConfigPath)
+		return
+	}
+	blockMap, ok := sqlBlocks[index].(map[string]interface{})
+	if !ok {
+		slog.Warn("unexpected structure of sql block", "index", index, "path", sqlcConfigPath)
+		return
+	}
+
+	if queriesCover(configDir, typed.SQL[index].Queries, relativeQueryPath) {
+		return
+	}
+	switch queries := blockMap["queries"].(type) {
+	case string:
+		blockMap["queries"] = []interface{}{queries, relativeQueryPath}
+	case []interface{}:
+		blockMap["queries"] = append(queries, relativeQueryPath)
+	default:
+		blockMap["queries"] = []interface{}{relativeQueryPath}
+	}
+
+	newConfigData, err := yaml.Marshal(sqlcConfig)
+	if err != nil {
+		slog.Warn("failed to marshal updated sqlc configuration", "error", err)
+	} else if err := os.WriteFile(sqlcConfigPath, newConfigData, 0644); err != nil {
+		slog.Warn("failed to update sqlc configuration file", "path", sqlcConfigPath, "error", err)
+	} else {
+		slog.Info("registered query file in the sqlc configuration", "path", sqlcConfigPath, "query", relativeQueryPath)
+	}
+}
+
+// loadSQLCConfig は sqlc.yml を読み込みます。
+func loadSQLCConfig() (*SQLCConfig, error) {
+	data, err := os.ReadFile(sqlcConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	var cfg SQLCConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse sqlc configuration file %s: %w", sqlcConfigPath, err)
+	}
+	return &cfg, nil
+}
+
+// DBPackage は sqlc が生成する Go パッケージの位置と、その中のファイル名です。
+// パスはすべてプロジェクトルートからの相対パスです。
+type DBPackage struct {
+	Dir           string // 生成先ディレクトリ（例: pkg/infra/db）
+	Name          string // パッケージ名
+	ImportPath    string // インポートパス
+	DBFile        string // db.go
+	ModelsFile    string // models.go
+	QuerierFile   string // querier.go（emit_interface が有効なときのみ）
+	BatchFile     string // batch.go（:batchexec などのクエリがあるときだけ生成される）
+	CopyFromFile  string // copyfrom.go（:copyfrom のクエリがあるときだけ生成される）
+	FilesSuffix   string // output_files_suffix
+	EmitInterface bool
+	Engine        string     // sqlc.yml の engine（postgresql, mysql, sqlite）
+	Driver        *SQLDriver // sql_package に対応するドライバー
+}
+
+// QueryFile は、クエリファイル名（例: user.sql）から sqlc が生成する Go ファイルのパスを返します。
+func (p *DBPackage) QueryFile(queryFileName string) string {
+	name := queryFileName + p.FilesSuffix
+	if !strings.HasSuffix(name, ".go") {
+		name += ".go"
+	}
+	return filepath.Join(p.Dir, name)
+}
+
+// ResolveDBPackage は sqlc.yml から infraFile に対応する生成パッケージを求めます。
+// sqlc.yml が読めない場合は従来どおり pkg/infra/db に生成されているものとして扱います。
+func ResolveDBPackage(cfg *Config, infraFile string) (*DBPackage, error) {
+	pkg := &DBPackage{
+		Dir:    filepath.Join("pkg", "infra", "db"),
+		Name:   "db",
+		Engine: "postgresql",
+	}
+	gen := &SQLCGoGen{}
+
+	sqlcConfig, err := loadSQLCConfig()
+	if err != nil {
+		slog.Warn("could not read sqlc configuration, assuming the default package", "dir", pkg.Dir, "error", err)
+	} else {
+		configDir := filepath.Dir(sqlcConfigPath)
+		index, err := selectSQLCBlock(sqlcConfig, configDir, infraFile, cfg.SQLCPackages)
+		if err != nil {
+			return nil, err
+		}
+		block := &sqlcConfig.SQL[index]
+		if block.Gen.Go == nil {
+			return nil, fmt.Errorf("sqlc package #%d has no gen.go settings", index)
+		}
+		gen = block.Gen.Go
+		pkg.Dir = filepath.Join(configDir, gen.Out)
+		pkg.Name = block.goPackage()
+		pkg.Engine = defaultString(block.Engine, pkg.Engine)
+	}
+
+	pkg.DBFile = filepath.Join(pkg.Dir, defaultString(gen.OutputDBFileName, "db.go"))
+	pkg.ModelsFile = filepath.Join(pkg.Dir, defaultString(gen.OutputModelsFileName, "models.go"))
+	pkg.BatchFile = filepath.Join(pkg.Dir, defaultString(gen.OutputBatchFileName, "batch.go"))
+	pkg.CopyFromFile = filepath.Join(pkg.Dir, defaultString(gen.OutputCopyFromFileName, "copyfrom.go"))
+	pkg.FilesSuffix = gen.OutputFilesSuffix
+	if pkg.Driver, err = driverFor(gen.SQLPackage); err != nil {
+		return nil, err
+	}
+	pkg.EmitInterface = gen.EmitInterface
+	if gen.EmitInterface {
+		pkg.QuerierFile = filepath.Join(pkg.Dir, defaultString(gen.OutputQuerierFileName, "querier.go"))
+	}
+
+	// 生成済みのファイルがあれば、そのpackage句を正とする
+	if f, err := parser.ParseFile(token.NewFileSet(), pkg.DBFile, nil, parser.PackageClauseOnly); err == nil {
+		pkg.Name = f.Name.Name
+	}
+
+	modulePath, err := goModulePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module path from go.mod: %w", err)
+	}
+	pkg.ImportPath = modulePath + "/" + filepath.ToSlash(filepath.Clean(pkg.Dir))
+	return pkg, nil
+}
+
+// goModulePath はプロジェクトルートの go.mod から module パスを返します。
+func goModulePath() (string, error) {
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "module ")), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("module declaration not found")
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
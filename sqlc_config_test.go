@@ -0,0 +1,217 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSelectSQLCBlock(t *testing.T) {
+	source := `version: "2"
+sql:
+  - engine: postgresql
+    schema: sql/schema/write
+    queries: sql/query/write
+    gen:
+      go:
+        package: writedb
+        out: write/db
+  - name: read
+    engine: postgresql
+    schema: sql/schema/read
+    queries:
+      - sql/query/read/user.sql
+    gen:
+      go:
+        out: read/db
+`
+	var cfg SQLCConfig
+	if err := yaml.Unmarshal([]byte(source), &cfg); err != nil {
+		t.Fatalf("failed to parse sqlc config: %v", err)
+	}
+	if len(cfg.SQL[0].Queries) != 1 || cfg.SQL[0].Queries[0] != "sql/query/write" {
+		t.Fatalf("expected single-string queries to be parsed as a list, got %v", cfg.SQL[0].Queries)
+	}
+
+	configDir := filepath.Join("pkg", "infra")
+	tests := []struct {
+		name      string
+		infraFile string
+		mappings  []SQLCPackageMapping
+		want      int
+		wantErr   bool
+	}{
+		{name: "out directory", infraFile: "pkg/infra/write/user.go", want: 0},
+		{name: "second block", infraFile: "pkg/infra/read/user.go", want: 1},
+		{name: "ambiguous", infraFile: "pkg/infra/user.go", wantErr: true},
+		{
+			name:      "mapping by gen.go.package",
+			infraFile: "pkg/infra/user.go",
+			mappings:  []SQLCPackageMapping{{Infra: "pkg/infra", Package: "writedb"}},
+			want:      0,
+		},
+		{
+			name:      "deepest mapping wins",
+			infraFile: "pkg/infra/read/user.go",
+			mappings: []SQLCPackageMapping{
+				{Infra: "pkg/infra", Package: "writedb"},
+				{Infra: "pkg/infra/read", Package: "read"},
+			},
+			want: 1,
+		},
+		{
+			name:      "unknown mapping",
+			infraFile: "pkg/infra/user.go",
+			mappings:  []SQLCPackageMapping{{Infra: "pkg/infra", Package: "missing"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectSQLCBlock(&cfg, configDir, filepath.FromSlash(tt.infraFile), tt.mappings)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got block %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectSQLCBlock() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected block %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQueriesCover(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configDir, "sql", "queries"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if !queriesCover(configDir, []string{"sql/queries"}, "sql/queries/user.sql") {
+		t.Errorf("expected a query directory to cover files inside it")
+	}
+	if !queriesCover(configDir, []string{"sql/other/user.sql"}, "sql/other/user.sql") {
+		t.Errorf("expected an exact query file to be covered")
+	}
+	if queriesCover(configDir, []string{"sql/queries/post.sql"}, "sql/queries/user.sql") {
+		t.Errorf("a different query file must not cover user.sql")
+	}
+	if queriesCover(configDir, []string{"sql/queries"}, "sql/queries2/user.sql") {
+		t.Errorf("a sibling directory with a common prefix must not be covered")
+	}
+	if queriesCover(configDir, []string{"sql/queries"}, "sql/queries/admin/user.sql") {
+		t.Errorf("sqlc does not read subdirectories, so a nested file must not be covered")
+	}
+}
+
+func TestRegisterQueryFileNested(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"pkg/infra/sqlc.yml": `version: "2"
+sql:
+  - engine: postgresql
+    schema: sql/schema
+    queries: sql/query
+    gen:
+      go:
+        package: db
+        out: db
+`,
+	})
+	chdir(t, dir)
+
+	for _, infraFile := range []string{filepath.Join("pkg", "infra", "user.go"), filepath.Join("pkg", "infra", "admin", "user.go")} {
+		registerQueryFile(&Config{}, infraFile, queryFilePath(infraFile))
+	}
+	cfg, err := loadSQLCConfig()
+	if err != nil {
+		t.Fatalf("loadSQLCConfig() error: %v", err)
+	}
+	if got := cfg.SQL[0].Queries; len(got) != 2 || got[0] != "sql/query" || got[1] != "sql/query/admin/user.sql" {
+		t.Errorf("expected only the nested query file to be registered, got %v", got)
+	}
+}
+
+// chdir はテスト中だけカレントディレクトリを dir に変更します。
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("failed to restore working directory: %v", err)
+		}
+	})
+}
+
+// writeProjectFiles は dir 以下に相対パスとその内容のファイルを作成します。
+func writeProjectFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for relPath, content := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", relPath, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", relPath, err)
+		}
+	}
+}
+
+func TestResolveDBPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.22\n",
+		"pkg/infra/sqlc.yml": `version: "2"
+sql:
+  - engine: postgresql
+    schema: sql/schema
+    queries: sql/query
+    gen:
+      go:
+        package: store
+        out: gen/store
+        sql_package: pgx/v5
+        emit_interface: true
+        output_files_suffix: _gen
+        output_models_file_name: entities.go
+`,
+		"pkg/infra/gen/store/db.go": "package sqlstore\n",
+	})
+	chdir(t, dir)
+
+	pkg, err := ResolveDBPackage(&Config{}, filepath.Join("pkg", "infra", "user.go"))
+	if err != nil {
+		t.Fatalf("ResolveDBPackage() error: %v", err)
+	}
+	if pkg.Driver != pgxV5Driver {
+		t.Errorf("expected pgx/v5 driver, got %v", pkg.Driver.Name)
+	}
+	checks := map[string][2]string{
+		"Dir":         {pkg.Dir, filepath.Join("pkg", "infra", "gen", "store")},
+		"Name":        {pkg.Name, "sqlstore"},
+		"ImportPath":  {pkg.ImportPath, "example.com/app/pkg/infra/gen/store"},
+		"DBFile":      {pkg.DBFile, filepath.Join("pkg", "infra", "gen", "store", "db.go")},
+		"ModelsFile":  {pkg.ModelsFile, filepath.Join("pkg", "infra", "gen", "store", "entities.go")},
+		"QuerierFile": {pkg.QuerierFile, filepath.Join("pkg", "infra", "gen", "store", "querier.go")},
+		"QueryFile":   {pkg.QueryFile("user.sql"), filepath.Join("pkg", "infra", "gen", "store", "user.sql_gen.go")},
+		"BatchFile":   {pkg.BatchFile, filepath.Join("pkg", "infra", "gen", "store", "batch.go")},
+		"Engine":      {pkg.Engine, "postgresql"},
+	}
+	for field, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s: expected %q, got %q", field, c[1], c[0])
+		}
+	}
+}
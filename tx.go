@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TransactionsConfig は llm-sqlc.yml の transactions セクションです。
+type TransactionsConfig struct {
+	File string `yaml:"file"` // トランザクションを扱う関数を定義したファイル（既定 pkg/infra/txProvider.go）
+}
+
+func (c *TransactionsConfig) file() string {
+	return defaultString(c.File, "pkg/infra/txProvider.go")
+}
+
+// TxFunc はトランザクションプロバイダーの関数（またはメソッド）1つ分です。
+type TxFunc struct {
+	Name      string // 例: RunInTx
+	Recv      string // メソッドならレシーバーの型名（* は除く）
+	Signature string // 例: func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxAPI はトランザクションプロバイダーのファイルから読み取った使い方です。
+type TxAPI struct {
+	File   string
+	Source string
+	// Runners はトランザクションを開始してコールバックを実行する関数です（WithTx, RunInTx など）。
+	Runners []TxFunc
+	// Getters は ctx からトランザクション（または DBTX）を取り出す関数です（GetTx など）。
+	Getters []TxFunc
+	// ContextKeys は context.WithValue や ctx.Value でトランザクションの格納に使われるキーです（txKey{} など）。
+	ContextKeys []string
+}
+
+// LoadTxAPI は path のトランザクションプロバイダーを読み込み、その関数を分類します。
+func LoadTxAPI(path string) (*TxAPI, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTxAPI(path, normalizeNewlines(string(src)))
+}
+
+// ParseTxAPI は src を解析し、トランザクションを開始する関数と取り出す関数、context のキーを集めます。
+func ParseTxAPI(path string, src string) (*TxAPI, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transaction provider %s: %w", path, err)
+	}
+	api := &TxAPI{File: path, Source: src}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !fn.Name.IsExported() {
+			continue
+		}
+		txFunc := TxFunc{Name: fn.Name.Name, Signature: nodeString(fset, fn.Type)}
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			txFunc.Recv = strings.TrimPrefix(nodeString(fset, fn.Recv.List[0].Type), "*")
+		}
+		switch {
+		case isTxRunner(fn.Type):
+			api.Runners = append(api.Runners, txFunc)
+		case isTxGetter(fn.Type):
+			api.Getters = append(api.Getters, txFunc)
+		}
+	}
+
+	seen := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		var key ast.Expr
+		switch {
+		case sel.Sel.Name == "WithValue" && isIdent(sel.X, "context") && len(call.Args) == 3:
+			key = call.Args[1]
+		case sel.Sel.Name == "Value" && len(call.Args) == 1:
+			key = call.Args[0]
+		default:
+			return true
+		}
+		if k := nodeString(fset, key); !seen[k] {
+			seen[k] = true
+			api.ContextKeys = append(api.ContextKeys, k)
+		}
+		return true
+	})
+	return api, nil
+}
+
+// isTxRunner は、コールバック（context か tx を受け取り error を返す関数）を引数に取り、error を返す関数かを返します。
+func isTxRunner(ft *ast.FuncType) bool {
+	if !returnsError(ft) {
+		return false
+	}
+	for _, p := range ft.Params.List {
+		callback, ok := p.Type.(*ast.FuncType)
+		if ok && returnsError(callback) && len(callback.Params.List) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isTxGetter は、context を受け取り、context 以外の値（トランザクションや DBTX）を返す関数かを返します。
+func isTxGetter(ft *ast.FuncType) bool {
+	if len(ft.Params.List) == 0 || !isContextType(ft.Params.List[0].Type) || ft.Results == nil {
+		return false
+	}
+	for _, r := range ft.Results.List {
+		if isContextType(r.Type) {
+			return false
+		}
+		if !isIdent(r.Type, "error") && !isIdent(r.Type, "bool") {
+			return true
+		}
+	}
+	return false
+}
+
+func returnsError(ft *ast.FuncType) bool {
+	if ft.Results == nil || len(ft.Results.List) == 0 {
+		return false
+	}
+	return isIdent(ft.Results.List[len(ft.Results.List)-1].Type, "error")
+}
+
+func isContextType(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Context" && isIdent(sel.X, "context")
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// funcNames は fns の名前の一覧です。
+func funcNames(fns []TxFunc) []string {
+	var names []string
+	for _, fn := range fns {
+		names = append(names, fn.Name)
+	}
+	return names
+}
+
+// callExpr は実装 struct のメソッドから fn を呼び出す式です。
+// メソッドであれば、そのレシーバー型のフィールドを implFields（フィールド名 → 型名）から探して repo.Field.Name とします。
+func (fn TxFunc) callExpr(implFields map[string]string) string {
+	if fn.Recv == "" {
+		return fn.Name
+	}
+	var fields []string
+	for field := range implFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if strings.TrimPrefix(implFields[field], "*") == fn.Recv {
+			return "repo." + field + "." + fn.Name
+		}
+	}
+	return fmt.Sprintf("(*%s).%s", fn.Recv, fn.Name)
+}
+
+// structFields は struct の定義のソースから、フィールド名 → 型の対応を返します（埋め込みは型名をフィールド名とします）。
+func structFields(structSrc string) map[string]string {
+	fields := make(map[string]string)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p\n\n"+structSrc, 0)
+	if err != nil {
+		return fields
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, field := range st.Fields.List {
+			typ := nodeString(fset, field.Type)
+			if len(field.Names) == 0 {
+				name := strings.TrimPrefix(typ, "*")
+				if i := strings.LastIndex(name, "."); i >= 0 {
+					name = name[i+1:]
+				}
+				fields[name] = typ
+			}
+			for _, name := range field.Names {
+				fields[name.Name] = typ
+			}
+		}
+		return false
+	})
+	return fields
+}
+
+// txReason はメソッドをトランザクションの中で実行しなければならない理由を返します。不要なら空文字です。
+// 書き込みのクエリが2つ以上ある場合と、読み取ってから書き込む場合（read-modify-write）に必要とします。
+func txReason(m *MethodManifest) string {
+	if m == nil {
+		return ""
+	}
+	var reads, writes []string
+	for _, q := range m.Queries {
+		switch q.Verb {
+		case "INSERT", "UPDATE", "DELETE":
+			writes = append(writes, q.Name)
+		case "SELECT":
+			reads = append(reads, q.Name)
+		}
+	}
+	switch {
+	case len(writes) >= 2:
+		return fmt.Sprintf("it runs %d write queries (%s)", len(writes), strings.Join(writes, ", "))
+	case len(writes) == 1 && len(reads) > 0:
+		return fmt.Sprintf("it reads (%s) and then writes (%s)", strings.Join(reads, ", "), writes[0])
+	}
+	return ""
+}
+
+// TxGuidance はメソッド1つ分のトランザクションの使い方をプロンプト向けに組み立てます。
+// reason が空でなければ、そのメソッドはトランザクションの中で実行しなければなりません。
+func TxGuidance(api *TxAPI, reason string, implFields map[string]string, dbPkgName string) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("The transaction provider is defined in %s as follows:\n\n", filepath.ToSlash(api.File)))
+	b.WriteString(strings.TrimSpace(api.Source))
+	b.WriteString("\n\n")
+
+	var usage strings.Builder
+	for _, runner := range api.Runners {
+		usage.WriteString(fmt.Sprintf("- %s starts a transaction and runs the callback inside it: %s\n", runner.callExpr(implFields), runner.Signature))
+	}
+	for _, getter := range api.Getters {
+		usage.WriteString(fmt.Sprintf("- %s returns the transaction of the context: %s\n", getter.callExpr(implFields), getter.Signature))
+	}
+	if len(api.ContextKeys) > 0 {
+		usage.WriteString(fmt.Sprintf("- The transaction is stored in the context under %s. Never read or write it directly; use the functions above.\n", strings.Join(api.ContextKeys, ", ")))
+	}
+	if len(api.Getters) > 0 {
+		usage.WriteString(fmt.Sprintf("- Create the query with query := %s.New(tx), where tx is the value returned by %s.\n", dbPkgName, api.Getters[0].callExpr(implFields)))
+	}
+	if usage.Len() > 0 {
+		b.WriteString("## How to use it\n")
+		b.WriteString(usage.String())
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## This Method\n")
+	switch {
+	case reason == "":
+		b.WriteString("This method does not need to start its own transaction.")
+		if len(api.Getters) > 0 {
+			b.WriteString(fmt.Sprintf(" Use the transaction of the context through %s.", api.Getters[0].callExpr(implFields)))
+		}
+	case len(api.Runners) > 0:
+		runner := api.Runners[0].callExpr(implFields)
+		b.WriteString(fmt.Sprintf("This method must run inside a transaction because %s.\n", reason))
+		b.WriteString(fmt.Sprintf("Call %s and run every query inside its callback. Return the error from the callback so that the transaction is rolled back; do not run any query before or after it.", runner))
+	default:
+		b.WriteString(fmt.Sprintf("This method must run inside a transaction because %s.\n", reason))
+		b.WriteString("Run every query on the transaction of the context so that they are committed or rolled back together.")
+	}
+	return b.String()
+}
+
+// TxCheckInfo はトランザクションのチェックに使う情報です。
+type TxCheckInfo struct {
+	API     *TxAPI
+	Reasons map[string]string // メソッド名 → トランザクションが必要な理由（不要なメソッドは含まない）
+}
+
+// queryVars は fn の中で dbImport.New(...) の結果を代入した変数名を返します。
+func queryVars(fn *ast.FuncDecl, dbImport string) map[string]bool {
+	vars := make(map[string]bool)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for i, rhs := range assign.Rhs {
+			if isDBNewCall(rhs, dbImport) && i < len(assign.Lhs) {
+				if id, ok := assign.Lhs[i].(*ast.Ident); ok {
+					vars[id.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+func isDBNewCall(expr ast.Expr, dbImport string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "New" && isIdent(sel.X, dbImport)
+}
+
+// queryCalls は fn の中で sqlc のクエリを呼び出している箇所（query.GetUser(...) や db.New(tx).GetUser(...)）を返します。
+func queryCalls(c *CheckContext, fn *ast.FuncDecl) []*ast.CallExpr {
+	var dbImport string
+	for name, p := range c.importNames() {
+		if c.DBPackage != nil && p == c.DBPackage.ImportPath {
+			dbImport = name
+		}
+	}
+	if dbImport == "" {
+		return nil
+	}
+	vars := queryVars(fn, dbImport)
+	var calls []*ast.CallExpr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); (ok && vars[id.Name]) || isDBNewCall(sel.X, dbImport) {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	return calls
+}
+
+// calledName は呼び出される関数の名前（pkg.F や x.y.F の F）を返します。
+func calledName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// checkTransactions は、トランザクションが必要なメソッド（複数の書き込み、read-modify-write）が
+// プロバイダーでトランザクションを開始し、すべてのクエリをそのコールバックの中で実行していることを確認します。
+func checkTransactions(c *CheckContext) []Finding {
+	if c.Tx == nil || c.Tx.API == nil {
+		return nil
+	}
+	api := c.Tx.API
+	var findings []Finding
+	for _, method := range c.sortedMethods() {
+		reason := c.Tx.Reasons[method]
+		if reason == "" {
+			continue
+		}
+		fn := c.Methods[method]
+		if len(api.Runners) == 0 {
+			if len(api.Getters) > 0 && !callsAny(fn, funcNames(api.Getters)) {
+				findings = append(findings, c.newFinding("transaction", method, fn.Name,
+					"this method must run inside a transaction because %s, but it does not use %s", reason, strings.Join(funcNames(api.Getters), " or ")))
+			}
+			continue
+		}
+
+		// トランザクションを開始する呼び出しに渡したコールバックの範囲
+		var inside [][2]token.Pos
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || !containsString(funcNames(api.Runners), calledName(call)) {
+				return true
+			}
+			for _, arg := range call.Args {
+				if lit, ok := arg.(*ast.FuncLit); ok {
+					inside = append(inside, [2]token.Pos{lit.Pos(), lit.End()})
+				}
+			}
+			return true
+		})
+		if len(inside) == 0 {
+			findings = append(findings, c.newFinding("transaction", method, fn.Name,
+				"this method must run inside a transaction because %s, but it does not call %s", reason, strings.Join(funcNames(api.Runners), " or ")))
+			continue
+		}
+		for _, call := range queryCalls(c, fn) {
+			within := false
+			for _, r := range inside {
+				if call.Pos() >= r[0] && call.End() <= r[1] {
+					within = true
+				}
+			}
+			if !within {
+				findings = append(findings, c.newFinding("transaction", method, call,
+					"%s runs outside the transaction; call it inside the callback of %s", calledName(call), api.Runners[0].Name))
+			}
+		}
+	}
+	return findings
+}
+
+// callsAny は fn の中で names のいずれかの関数を呼び出しているかを返します。
+func callsAny(fn *ast.FuncDecl, names []string) bool {
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && containsString(names, calledName(call)) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleTxProvider = `package infra
+
+import (
+	"context"
+	"database/sql"
+)
+
+type txKey struct{}
+
+type TxProvider struct {
+	db *sql.DB
+}
+
+func NewTxProvider(db *sql.DB) *TxProvider {
+	return &TxProvider{db: db}
+}
+
+// RunInTx は fn をトランザクションの中で実行します。
+func (p *TxProvider) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTx は ctx のトランザクションを返します。
+func GetTx(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+func withTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+`
+
+func TestParseTxAPI(t *testing.T) {
+	api, err := ParseTxAPI("pkg/infra/txProvider.go", sampleTxProvider)
+	if err != nil {
+		t.Fatalf("ParseTxAPI error: %v", err)
+	}
+	if len(api.Runners) != 1 || api.Runners[0].Name != "RunInTx" || api.Runners[0].Recv != "TxProvider" {
+		t.Errorf("expected RunInTx on TxProvider as the only runner, got %+v", api.Runners)
+	}
+	if len(api.Getters) != 1 || api.Getters[0].Name != "GetTx" {
+		t.Errorf("expected GetTx as the only getter, got %+v", api.Getters)
+	}
+	if len(api.ContextKeys) != 1 || api.ContextKeys[0] != "txKey{}" {
+		t.Errorf("expected txKey{} as the context key, got %v", api.ContextKeys)
+	}
+
+	guidance := TxGuidance(api, "it runs 2 write queries (InsertUser, InsertProfile)", structFields("type UserRepositoryImpl struct {\n\tTx *TxProvider\n}"), "db")
+	for _, want := range []string{
+		"- repo.Tx.RunInTx starts a transaction and runs the callback inside it: func(ctx context.Context, fn func(ctx context.Context) error) error\n",
+		"- GetTx returns the transaction of the context",
+		"under txKey{}",
+		"query := db.New(tx)",
+		"must run inside a transaction because it runs 2 write queries (InsertUser, InsertProfile).\nCall repo.Tx.RunInTx",
+	} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("expected guidance to contain %q:\n%s", want, guidance)
+		}
+	}
+	if guidance := TxGuidance(api, "", nil, "db"); !strings.Contains(guidance, "does not need to start its own transaction") || !strings.Contains(guidance, "(*TxProvider).RunInTx") {
+		t.Errorf("unexpected guidance for a method without a transaction:\n%s", guidance)
+	}
+}
+
+func TestTxReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []QueryManifestEntry
+		want    string
+	}{
+		{name: "single read", queries: []QueryManifestEntry{{Name: "GetUser", Verb: "SELECT"}}},
+		{name: "single write", queries: []QueryManifestEntry{{Name: "InsertUser", Verb: "INSERT"}}},
+		{
+			name:    "multiple writes",
+			queries: []QueryManifestEntry{{Name: "InsertUser", Verb: "INSERT"}, {Name: "InsertProfile", Verb: "INSERT"}},
+			want:    "it runs 2 write queries (InsertUser, InsertProfile)",
+		},
+		{
+			name:    "read-modify-write",
+			queries: []QueryManifestEntry{{Name: "GetUser", Verb: "SELECT"}, {Name: "UpdateUser", Verb: "UPDATE"}},
+			want:    "it reads (GetUser) and then writes (UpdateUser)",
+		},
+	}
+	for _, tt := range tests {
+		if got := txReason(&MethodManifest{Queries: tt.queries}); got != tt.want {
+			t.Errorf("%s: txReason() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := txReason(nil); got != "" {
+		t.Errorf("expected no reason without a manifest, got %q", got)
+	}
+}
+
+func TestCheckTransactions(t *testing.T) {
+	src := `package infra
+
+import (
+	"context"
+
+	"example.com/app/pkg/infra/db"
+)
+
+type UserRepositoryImpl struct {
+	Tx *TxProvider
+}
+
+func (repo *UserRepositoryImpl) Create(ctx context.Context) error {
+	return repo.Tx.RunInTx(ctx, func(ctx context.Context) error {
+		tx, _ := GetTx(ctx)
+		query := db.New(tx)
+		if err := query.InsertUser(ctx); err != nil {
+			return err
+		}
+		return query.InsertProfile(ctx)
+	})
+}
+
+func (repo *UserRepositoryImpl) Rename(ctx context.Context) error {
+	tx, _ := GetTx(ctx)
+	query := db.New(tx)
+	if _, err := query.GetUser(ctx); err != nil {
+		return err
+	}
+	return repo.Tx.RunInTx(ctx, func(ctx context.Context) error {
+		return query.UpdateUser(ctx)
+	})
+}
+
+func (repo *UserRepositoryImpl) Delete(ctx context.Context) error {
+	tx, _ := GetTx(ctx)
+	if err := db.New(tx).DeleteProfile(ctx); err != nil {
+		return err
+	}
+	return db.New(tx).DeleteUser(ctx)
+}
+`
+	api, err := ParseTxAPI("pkg/infra/txProvider.go", sampleTxProvider)
+	if err != nil {
+		t.Fatalf("ParseTxAPI error: %v", err)
+	}
+	fset, file := parseSource(t, src)
+	c := NewCheckContext(fset, file, []string{"Create", "Rename", "Delete"}, &DBPackage{ImportPath: "example.com/app/pkg/infra/db"})
+	c.Tx = &TxCheckInfo{API: api, Reasons: map[string]string{
+		"Create": "it runs 2 write queries (InsertUser, InsertProfile)",
+		"Rename": "it reads (GetUser) and then writes (UpdateUser)",
+		"Delete": "it runs 2 write queries (DeleteProfile, DeleteUser)",
+	}}
+	findings := checkTransactions(c)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d: %v", len(findings), findings)
+	}
+	if findings[0].Method != "Rename" || !strings.Contains(findings[0].Message, "GetUser runs outside the transaction") {
+		t.Errorf("unexpected first finding: %v", findings[0])
+	}
+	if findings[1].Method != "Delete" || !strings.Contains(findings[1].Message, "does not call RunInTx") {
+		t.Errorf("unexpected second finding: %v", findings[1])
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// UsageConfig は llm-sqlc.yml の usage セクションです。
+type UsageConfig struct {
+	// Ledger を指定すると、モデル呼び出しごとの使用量をこのファイルに JSONL で追記します（例: .llm-sqlc/usage.jsonl）。
+	Ledger string `yaml:"ledger"`
+	// Pricing はモデルごとの料金（USD / 100万トークン）です。既定の料金表より優先されます。
+	Pricing map[string]ModelPrice `yaml:"pricing"`
+}
+
+// ModelPrice は 100万トークンあたりの料金（USD）です。
+type ModelPrice struct {
+	Input       float64 `yaml:"input" json:"input"`
+	CachedInput float64 `yaml:"cached_input" json:"cached_input"`
+	Output      float64 `yaml:"output" json:"output"`
+}
+
+// defaultModelPrices はモデル名の接頭辞ごとの既定の料金です。最も長く一致する接頭辞を使います。
+var defaultModelPrices = map[string]ModelPrice{
+	"gpt-4.1":      {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"gpt-4.1-mini": {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gpt-4o":       {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+	"gpt-4o-mini":  {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+	"o3":           {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"o4-mini":      {Input: 1.10, CachedInput: 0.275, Output: 4.40},
+}
+
+// priceFor は model の料金を返します。分からなければ ok が false です。
+func (c *UsageConfig) priceFor(model string) (price ModelPrice, ok bool) {
+	if p, found := c.Pricing[model]; found {
+		return p, true
+	}
+	matched := ""
+	for prefix, p := range defaultModelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			price, matched = p, prefix
+		}
+	}
+	return price, matched != ""
+}
+
+// Usage はモデル呼び出し1回分の使用量です。
+type Usage struct {
+	RunID            string        `json:"run_id"`
+	Time             time.Time     `json:"time"`
+	Command          string        `json:"command"`
+	InfraFile        string        `json:"infra_file,omitempty"`
+	Method           string        `json:"method,omitempty"`
+	Model            string        `json:"model"`
+	PromptTokens     int64         `json:"prompt_tokens"`
+	CachedTokens     int64         `json:"cached_tokens"`
+	CompletionTokens int64         `json:"completion_tokens"`
+	Latency          time.Duration `json:"latency_ns"`
+	Cost             float64       `json:"cost_usd"`
+	CostKnown        bool          `json:"cost_known"`
+}
+
+// usageScope はモデル呼び出しがどのコマンド・メソッドのためのものかを context で運びます。
+type usageScope struct {
+	Command   string
+	InfraFile string
+	Method    string
+}
+
+type usageScopeKey struct{}
+
+// withUsageScope は ctx に、以降のモデル呼び出しの使用量をまとめるためのコマンドとメソッドを付けます。
+func withUsageScope(ctx context.Context, command, infraFile, method string) context.Context {
+	return context.WithValue(ctx, usageScopeKey{}, usageScope{Command: command, InfraFile: infraFile, Method: method})
+}
+
+// UsageRecorder は1回の実行で行ったモデル呼び出しの使用量を集めます。
+type UsageRecorder struct {
+	mu      sync.Mutex
+	runID   string
+	config  *UsageConfig
+	records []Usage
+}
+
+// runUsage はこの実行全体の使用量です。
+var runUsage = &UsageRecorder{runID: time.Now().UTC().Format("20060102T150405.000Z")}
+
+// Configure は料金表と JSONL の追記先を設定します。
+func (r *UsageRecorder) Configure(c *UsageConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = c
+}
+
+// Record は ctx のスコープと料金表から使用量を補って記録し、設定されていれば JSONL に追記します。
+func (r *UsageRecorder) Record(ctx context.Context, u Usage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	u.RunID = r.runID
+	u.Command, u.InfraFile, u.Method = scope.Command, scope.InfraFile, scope.Method
+	config := r.config
+	if config == nil {
+		config = &UsageConfig{}
+	}
+	if price, ok := config.priceFor(u.Model); ok {
+		u.Cost = (float64(u.PromptTokens-u.CachedTokens)*price.Input +
+			float64(u.CachedTokens)*price.CachedInput +
+			float64(u.CompletionTokens)*price.Output) / 1e6
+		u.CostKnown = true
+	}
+	r.records = append(r.records, u)
+
+	if config.Ledger == "" {
+		return nil
+	}
+	return appendLedger(config.Ledger, u)
+}
+
+// appendLedger は u を JSONL の1行として path に追記します。
+func appendLedger(path string, u Usage) error {
+	line, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage ledger directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage ledger %s: %w", path, err)
+	}
+	return nil
+}
+
+// usageTotal は使用量の集計です。
+type usageTotal struct {
+	Calls            int
+	PromptTokens     int64
+	CompletionTokens int64
+	Latency          time.Duration
+	Cost             float64
+	CostUnknown      bool
+}
+
+func (t *usageTotal) add(u Usage) {
+	t.Calls++
+	t.PromptTokens += u.PromptTokens
+	t.CompletionTokens += u.CompletionTokens
+	t.Latency += u.Latency
+	t.Cost += u.Cost
+	if !u.CostKnown {
+		t.CostUnknown = true
+	}
+}
+
+func (t *usageTotal) costString() string {
+	if t.CostUnknown {
+		return fmt.Sprintf("$%.4f+", t.Cost)
+	}
+	return fmt.Sprintf("$%.4f", t.Cost)
+}
+
+// usageRow は集計表の1行です。Method が "(all)" の行はコマンド全体、Command が "(run)" の行は実行全体です。
+type usageRow struct {
+	Command string
+	Method  string
+	Total   *usageTotal
+}
+
+// summaryRows はメソッドごと、コマンドごと、実行全体の順に集計した行を返します。
+func (r *UsageRecorder) summaryRows() []usageRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return nil
+	}
+
+	type key struct{ command, method string }
+	byMethod := make(map[key]*usageTotal)
+	byCommand := make(map[string]*usageTotal)
+	var keys []key
+	var commands []string
+	total := &usageTotal{}
+	for _, u := range r.records {
+		k := key{u.Command, u.Method}
+		if byMethod[k] == nil {
+			byMethod[k] = &usageTotal{}
+			keys = append(keys, k)
+		}
+		byMethod[k].add(u)
+		if byCommand[u.Command] == nil {
+			byCommand[u.Command] = &usageTotal{}
+			commands = append(commands, u.Command)
+		}
+		byCommand[u.Command].add(u)
+		total.add(u)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].command < keys[j].command })
+
+	var rows []usageRow
+	for _, k := range keys {
+		rows = append(rows, usageRow{Command: k.command, Method: k.method, Total: byMethod[k]})
+	}
+	for _, c := range commands {
+		rows = append(rows, usageRow{Command: c, Method: "(all)", Total: byCommand[c]})
+	}
+	return append(rows, usageRow{Command: "(run)", Total: total})
+}
+
+// WriteSummary はメソッドごと、コマンドごと、実行全体の使用量を表にして w に書きます。呼び出しがなければ何も書きません。
+func (r *UsageRecorder) WriteSummary(w io.Writer) {
+	rows := r.summaryRows()
+	if len(rows) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "command\tmethod\tcalls\tprompt\tcompletion\tlatency\tcost\t")
+	for _, row := range rows {
+		t := row.Total
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t\n", row.Command, row.Method, t.Calls, t.PromptTokens, t.CompletionTokens,
+			t.Latency.Round(time.Millisecond), t.costString())
+	}
+	tw.Flush()
+	if rows[len(rows)-1].Total.CostUnknown {
+		fmt.Fprintln(w, "+ some models have no known price; add them to usage.pricing in llm-sqlc.yml")
+	}
+}
+
+// LogSummary は WriteSummary と同じ集計を、1行ずつ構造化ログとして出します（--json 用）。
+func (r *UsageRecorder) LogSummary(logger *slog.Logger) {
+	for _, row := range r.summaryRows() {
+		t := row.Total
+		logger.Info("usage", "command", row.Command, "method", row.Method, "calls", t.Calls,
+			"prompt_tokens", t.PromptTokens, "completion_tokens", t.CompletionTokens,
+			"latency", t.Latency.Round(time.Millisecond), "cost_usd", t.Cost, "cost_known", !t.CostUnknown)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "usage", "usage.jsonl")
+	r := &UsageRecorder{runID: "run-1"}
+	r.Configure(&UsageConfig{
+		Ledger:  ledger,
+		Pricing: map[string]ModelPrice{"custom-model": {Input: 1, Output: 2}},
+	})
+
+	sqlCtx := withUsageScope(context.Background(), "sql", "pkg/infra/user.go", "FindByID")
+	calls := []struct {
+		ctx   context.Context
+		usage Usage
+	}{
+		{sqlCtx, Usage{Model: "gpt-4.1-mini-2025-04-14", PromptTokens: 1000000, CachedTokens: 500000, CompletionTokens: 1000000, Latency: time.Second}},
+		{sqlCtx, Usage{Model: "custom-model", PromptTokens: 1000, CompletionTokens: 500, Latency: time.Second}},
+		{withUsageScope(context.Background(), "program", "pkg/infra/user.go", "Save"), Usage{Model: "unknown-model", PromptTokens: 10, CompletionTokens: 5}},
+	}
+	for _, c := range calls {
+		if err := r.Record(c.ctx, c.usage); err != nil {
+			t.Fatalf("Record error: %v", err)
+		}
+	}
+
+	// gpt-4.1-mini: 50万 × 0.40 + 50万 × 0.10 + 100万 × 1.60
+	if got := r.records[0].Cost; math.Abs(got-1.85) > 1e-9 || !r.records[0].CostKnown {
+		t.Errorf("gpt-4.1-mini cost = %v", got)
+	}
+	if got := r.records[1].Cost; math.Abs(got-0.002) > 1e-9 {
+		t.Errorf("custom-model cost = %v", got)
+	}
+	if r.records[2].CostKnown {
+		t.Errorf("expected unknown-model to have no price")
+	}
+
+	f, err := os.Open(ledger)
+	if err != nil {
+		t.Fatalf("ledger not written: %v", err)
+	}
+	defer f.Close()
+	var lines []Usage
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var u Usage
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			t.Fatalf("invalid ledger line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, u)
+	}
+	if len(lines) != 3 || lines[0].RunID != "run-1" || lines[0].Command != "sql" || lines[0].Method != "FindByID" || lines[2].Method != "Save" {
+		t.Errorf("ledger = %+v", lines)
+	}
+
+	var b strings.Builder
+	r.WriteSummary(&b)
+	summary := b.String()
+	for _, want := range []string{"FindByID", "Save", "(all)", "(run)", "$1.8520+", "usage.pricing"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected summary to contain %q:\n%s", want, summary)
+		}
+	}
+
+	var empty strings.Builder
+	(&UsageRecorder{}).WriteSummary(&empty)
+	if empty.Len() != 0 {
+		t.Errorf("expected no summary without calls, got %q", empty.String())
+	}
+}